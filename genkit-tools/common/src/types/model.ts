@@ -148,6 +148,8 @@ export const GenerationCommonConfigSchema = z.object({
   topK: z.number().optional(),
   topP: z.number().optional(),
   stopSequences: z.array(z.string()).optional(),
+  /** Seed for sampling, to make generation reproducible where the model supports it. */
+  seed: z.number().optional(),
 });
 export type GenerationCommonConfig = typeof GenerationCommonConfigSchema;
 
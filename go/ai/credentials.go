@@ -0,0 +1,99 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/firebase/genkit/go/internal/base"
+)
+
+// Credentials identify the account a plugin uses to call a model provider.
+// They let a single program call a provider on behalf of several tenants,
+// each with their own key or endpoint, instead of with the one client
+// configured when the plugin was initialized.
+//
+// Which fields are used depends on the plugin.
+type Credentials struct {
+	// APIKey is the key sent to the provider.
+	APIKey string
+	// AccessToken is an OAuth2 access token sent to the provider.
+	AccessToken string
+	// Endpoint replaces the provider's default service address.
+	Endpoint string
+	// ProjectID and Location select a Google Cloud project and region.
+	ProjectID string
+	Location  string
+}
+
+// Key returns a string that uniquely identifies c, suitable for use as a
+// cache key. It does not contain any secrets in the clear.
+func (c *Credentials) Key() string {
+	h := sha256.New()
+	for _, s := range []string{c.APIKey, c.AccessToken, c.Endpoint, c.ProjectID, c.Location} {
+		// Length-prefix each field so that different splits hash differently.
+		fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// A CredentialProvider returns the credentials to use for calls to the
+// named provider, such as "googleai", in the given context. It returns
+// nil credentials to use the plugin's defaults.
+//
+// A typical provider looks up the current tenant in ctx, for instance
+// from the flow's auth context, and returns that tenant's key.
+type CredentialProvider func(ctx context.Context, provider string) (*Credentials, error)
+
+var credentialProviderKey = base.NewContextKey[CredentialProvider]()
+
+// WithCredentialProvider returns a context whose model calls use
+// credentials from p.
+func WithCredentialProvider(ctx context.Context, p CredentialProvider) context.Context {
+	return credentialProviderKey.NewContext(ctx, p)
+}
+
+// CredentialsFor returns the credentials that a plugin should use for a call
+// to provider in ctx. It returns nil, nil if ctx has no credential provider
+// or the provider has no credentials for this call.
+//
+// Plugins that support per-request credentials call this in their model functions.
+func CredentialsFor(ctx context.Context, provider string) (*Credentials, error) {
+	p := credentialProviderKey.FromContext(ctx)
+	if p == nil {
+		return nil, nil
+	}
+	c, err := p(ctx, provider)
+	if err != nil {
+		return nil, fmt.Errorf("credentials for %s: %w", provider, err)
+	}
+	return c, nil
+}
+
+// WithCredentials sets the credential provider for the generate request,
+// overriding any provider in the context.
+func WithCredentials(p CredentialProvider) GenerateOption {
+	return func(req *generateParams) error {
+		if req.Credentials != nil {
+			return errors.New("cannot set credential provider (WithCredentials) more than once")
+		}
+		req.Credentials = p
+		return nil
+	}
+}
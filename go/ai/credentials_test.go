@@ -0,0 +1,80 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ai
+
+import (
+	"context"
+	"testing"
+)
+
+var keyModel = DefineModel(r, "test", "key", nil, func(ctx context.Context, req *ModelRequest, _ ModelStreamingCallback) (*ModelResponse, error) {
+	creds, err := CredentialsFor(ctx, "test")
+	if err != nil {
+		return nil, err
+	}
+	key := "default"
+	if creds != nil {
+		key = creds.APIKey
+	}
+	return &ModelResponse{Request: req, Message: NewModelTextMessage(key)}, nil
+})
+
+func TestCredentials(t *testing.T) {
+	tenantKeys := func(tenant string) CredentialProvider {
+		return func(_ context.Context, provider string) (*Credentials, error) {
+			if provider != "test" {
+				t.Errorf("got provider %q, want test", provider)
+			}
+			return &Credentials{APIKey: tenant + "-key"}, nil
+		}
+	}
+	ctx := context.Background()
+	for _, test := range []struct {
+		name string
+		ctx  context.Context
+		opts []GenerateOption
+		want string
+	}{
+		{"none", ctx, nil, "default"},
+		{"context", WithCredentialProvider(ctx, tenantKeys("a")), nil, "a-key"},
+		{"option", ctx, []GenerateOption{WithCredentials(tenantKeys("b"))}, "b-key"},
+		{"option overrides context", WithCredentialProvider(ctx, tenantKeys("a")), []GenerateOption{WithCredentials(tenantKeys("b"))}, "b-key"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			opts := append([]GenerateOption{WithModel(keyModel), WithTextPrompt("hi")}, test.opts...)
+			got, err := GenerateText(test.ctx, r, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	_, err := GenerateText(ctx, r, WithModel(keyModel), WithCredentials(tenantKeys("a")), WithCredentials(tenantKeys("b")))
+	errorContains(t, err, "more than once")
+}
+
+func TestCredentialsKey(t *testing.T) {
+	a := &Credentials{APIKey: "ab", Endpoint: "c"}
+	b := &Credentials{APIKey: "a", Endpoint: "bc"}
+	if a.Key() == b.Key() {
+		t.Error("different credentials have the same key")
+	}
+	if a.Key() != (&Credentials{APIKey: "ab", Endpoint: "c"}).Key() {
+		t.Error("equal credentials have different keys")
+	}
+}
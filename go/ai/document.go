@@ -17,6 +17,7 @@ package ai
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 // A Document is a piece of data that can be embedded, indexed, or retrieved.
@@ -32,10 +33,10 @@ type Document struct {
 // may be a URL (possibly a "data:" URL with embedded data).
 type Part struct {
 	Kind         PartKind      `json:"kind,omitempty"`
-	ContentType  string        `json:"contentType,omitempty"` // valid for kind==blob
-	Text         string        `json:"text,omitempty"`        // valid for kind∈{text,blob}
-	ToolRequest  *ToolRequest  `json:"toolreq,omitempty"`     // valid for kind==partToolRequest
-	ToolResponse *ToolResponse `json:"toolresp,omitempty"`    // valid for kind==partToolResponse
+	ContentType  string        `json:"contentType,omitempty"`  // valid for kind==blob
+	Text         string        `json:"text,omitempty"`         // valid for kind∈{text,blob}
+	ToolRequest  *ToolRequest  `json:"toolRequest,omitempty"`  // valid for kind==partToolRequest
+	ToolResponse *ToolResponse `json:"toolResponse,omitempty"` // valid for kind==partToolResponse
 }
 
 type PartKind int8
@@ -109,87 +110,94 @@ func (p *Part) IsToolResponse() bool {
 
 // MarshalJSON is called by the JSON marshaler to write out a Part.
 func (p *Part) MarshalJSON() ([]byte, error) {
-	// This is not handled by the schema generator because
-	// Part is defined in TypeScript as a union.
-
+	// Part is defined in TypeScript as a union, so it is marshaled
+	// as the generated part type, which matches it.
+	var v part
 	switch p.Kind {
 	case PartText:
-		v := textPart{
-			Text: p.Text,
-		}
-		return json.Marshal(v)
+		v.TextPart = &textPart{Text: p.Text}
 	case PartMedia:
-		v := mediaPart{
+		v.MediaPart = &mediaPart{
 			Media: &mediaPartMedia{
 				ContentType: p.ContentType,
 				Url:         p.Text,
 			},
 		}
-		return json.Marshal(v)
 	case PartData:
-		v := dataPart{
-			Data: p.Text,
-		}
-		return json.Marshal(v)
+		v.DataPart = &dataPart{Data: p.Text}
 	case PartToolRequest:
-		// TODO: make sure these types marshal/unmarshal nicely
-		// between Go and javascript. At the very least the
-		// field name needs to change (here and in UnmarshalJSON).
-		v := struct {
-			ToolReq *ToolRequest `json:"toolreq,omitempty"`
-		}{
-			ToolReq: p.ToolRequest,
-		}
-		return json.Marshal(v)
+		v.ToolRequestPart = &toolRequestPart{ToolRequest: p.ToolRequest}
 	case PartToolResponse:
-		v := struct {
-			ToolResp *ToolResponse `json:"toolresp,omitempty"`
-		}{
-			ToolResp: p.ToolResponse,
-		}
-		return json.Marshal(v)
+		v.ToolResponsePart = &toolResponsePart{ToolResponse: p.ToolResponse}
 	default:
 		return nil, fmt.Errorf("invalid part kind %v", p.Kind)
 	}
+	return json.Marshal(&v)
 }
 
+// partSchema describes the JSON form of a Part for schema reflection.
+// It is the union of the fields of the part types.
 type partSchema struct {
-	Text     string          `json:"text,omitempty"`
-	Media    *mediaPartMedia `json:"media,omitempty"`
-	Data     string          `json:"data,omitempty"`
-	ToolReq  *ToolRequest    `json:"toolreq,omitempty"`
-	ToolResp *ToolResponse   `json:"toolresp,omitempty"`
+	Text         string          `json:"text,omitempty"`
+	Media        *mediaPartMedia `json:"media,omitempty"`
+	Data         any             `json:"data,omitempty"`
+	ToolRequest  *ToolRequest    `json:"toolRequest,omitempty"`
+	ToolResponse *ToolResponse   `json:"toolResponse,omitempty"`
 }
 
 // UnmarshalJSON is called by the JSON unmarshaler to read a Part.
 func (p *Part) UnmarshalJSON(b []byte) error {
-	// This is not handled by the schema generator because
-	// Part is defined in TypeScript as a union.
-
-	var s partSchema
-	if err := json.Unmarshal(b, &s); err != nil {
+	var v part
+	if err := json.Unmarshal(b, &v); err != nil {
 		return err
 	}
-
+	*p = Part{}
 	switch {
-	case s.Media != nil:
+	case v.TextPart != nil:
+		p.Kind = PartText
+		p.Text = v.TextPart.Text
+	case v.MediaPart != nil:
 		p.Kind = PartMedia
-		p.Text = s.Media.Url
-		p.ContentType = s.Media.ContentType
-	case s.ToolReq != nil:
+		if m := v.MediaPart.Media; m != nil {
+			p.Text = m.Url
+			p.ContentType = m.ContentType
+		}
+	case v.ToolRequestPart != nil:
 		p.Kind = PartToolRequest
-		p.ToolRequest = s.ToolReq
-	case s.ToolResp != nil:
+		p.ToolRequest = v.ToolRequestPart.ToolRequest
+	case v.ToolResponsePart != nil:
 		p.Kind = PartToolResponse
-		p.ToolResponse = s.ToolResp
+		p.ToolResponse = v.ToolResponsePart.ToolResponse
+	case v.DataPart.Data != nil:
+		p.Kind = PartData
+		if s, ok := v.DataPart.Data.(string); ok {
+			p.Text = s
+		} else {
+			data, err := json.Marshal(v.DataPart.Data)
+			if err != nil {
+				return err
+			}
+			p.Text = string(data)
+		}
 	default:
-		p.Kind = PartText
-		p.Text = s.Text
-		p.ContentType = ""
-		if s.Data != "" {
-			// Note: if part is completely empty, we use text by default.
-			p.Kind = PartData
-			p.Text = s.Data
+		// Earlier versions of this package wrote tool parts with these keys.
+		var legacy struct {
+			ToolReq  *ToolRequest  `json:"toolreq"`
+			ToolResp *ToolResponse `json:"toolresp"`
+		}
+		if err := json.Unmarshal(b, &legacy); err != nil {
+			return err
+		}
+		switch {
+		case legacy.ToolReq != nil:
+			p.Kind = PartToolRequest
+			p.ToolRequest = legacy.ToolReq
+		case legacy.ToolResp != nil:
+			p.Kind = PartToolResponse
+			p.ToolResponse = legacy.ToolResp
+		default:
+			// An empty part is a text part.
+			p.Kind = PartText
 		}
 	}
 	return nil
@@ -217,3 +225,15 @@ func DocumentFromText(text string, metadata map[string]any) *Document {
 		Metadata: metadata,
 	}
 }
+
+// Text returns the text parts of the [Document], separated by newlines.
+// It returns an empty string if the document has no text parts.
+func (d *Document) Text() string {
+	var texts []string
+	for _, p := range d.Content {
+		if p.IsText() {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
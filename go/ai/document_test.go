@@ -37,6 +37,20 @@ func TestDocumentFromText(t *testing.T) {
 	}
 }
 
+func TestDocumentText(t *testing.T) {
+	d := &Document{Content: []*Part{
+		NewTextPart("a"),
+		NewMediaPart("image/png", "data:,"),
+		NewTextPart("b"),
+	}}
+	if got, want := d.Text(), "a\nb"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := (&Document{}).Text(); got != "" {
+		t.Errorf("empty document: got %q, want empty", got)
+	}
+}
+
 // TODO: verify that this works with the data that genkit passes.
 func TestDocumentJSON(t *testing.T) {
 	d := Document{
@@ -108,3 +122,34 @@ func TestDocumentJSON(t *testing.T) {
 		t.Errorf("mismatch (-want, +got)\n%s", diff)
 	}
 }
+
+func TestPartJSONWireFormat(t *testing.T) {
+	p := NewToolRequestPart(&ToolRequest{Name: "tool1", Input: map[string]any{"x": 1.0}})
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"toolRequest":{"input":{"x":1},"name":"tool1"}}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	for _, test := range []struct {
+		in   string
+		want *Part
+	}{
+		{`{}`, &Part{Kind: PartText}},
+		{`{"text":"hi","metadata":{"a":1}}`, &Part{Kind: PartText, Text: "hi"}},
+		{`{"data":{"a":1}}`, &Part{Kind: PartData, Text: `{"a":1}`}},
+		{`{"toolResponse":{"name":"t","output":{"r":2}}}`, &Part{Kind: PartToolResponse, ToolResponse: &ToolResponse{Name: "t", Output: map[string]any{"r": 2.0}}}},
+		{`{"toolreq":{"name":"t"}}`, &Part{Kind: PartToolRequest, ToolRequest: &ToolRequest{Name: "t"}}},
+		{`{"toolresp":{"name":"t"}}`, &Part{Kind: PartToolResponse, ToolResponse: &ToolResponse{Name: "t"}}},
+	} {
+		var got Part
+		if err := json.Unmarshal([]byte(test.in), &got); err != nil {
+			t.Fatalf("%s: %v", test.in, err)
+		}
+		if diff := cmp.Diff(test.want, &got); diff != "" {
+			t.Errorf("%s: mismatch (-want, +got)\n%s", test.in, diff)
+		}
+	}
+}
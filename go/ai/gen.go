@@ -16,6 +16,11 @@
 
 package ai
 
+import (
+	"encoding/json"
+	"errors"
+)
+
 type dataPart struct {
 	Data     any            `json:"data,omitempty"`
 	Metadata map[string]any `json:"metadata,omitempty"`
@@ -36,12 +41,14 @@ const (
 
 // GenerationCommonConfig holds configuration for generation.
 type GenerationCommonConfig struct {
-	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
-	StopSequences   []string `json:"stopSequences,omitempty"`
-	Temperature     float64  `json:"temperature,omitempty"`
-	TopK            int      `json:"topK,omitempty"`
-	TopP            float64  `json:"topP,omitempty"`
-	Version         string   `json:"version,omitempty"`
+	MaxOutputTokens int `json:"maxOutputTokens,omitempty"`
+	// Seed makes sampling reproducible, for models that support it.
+	Seed          int      `json:"seed,omitempty"`
+	StopSequences []string `json:"stopSequences,omitempty"`
+	Temperature   float64  `json:"temperature,omitempty"`
+	TopK          int      `json:"topK,omitempty"`
+	TopP          float64  `json:"topP,omitempty"`
+	Version       string   `json:"version,omitempty"`
 }
 
 // GenerationUsage provides information about the generation process.
@@ -136,6 +143,59 @@ const (
 	FinishReasonUnknown FinishReason = "unknown"
 )
 
+// A part is the JSON form of a [Part]: one of the part types
+// of the genkit schema.
+// Exactly one field of a part is set.
+type part struct {
+	TextPart         *textPart
+	MediaPart        *mediaPart
+	ToolRequestPart  *toolRequestPart
+	ToolResponsePart *toolResponsePart
+	DataPart         *dataPart
+}
+
+// MarshalJSON marshals the field of u that is set.
+func (u *part) MarshalJSON() ([]byte, error) {
+	switch {
+	case u.TextPart != nil:
+		return json.Marshal(u.TextPart)
+	case u.MediaPart != nil:
+		return json.Marshal(u.MediaPart)
+	case u.ToolRequestPart != nil:
+		return json.Marshal(u.ToolRequestPart)
+	case u.ToolResponsePart != nil:
+		return json.Marshal(u.ToolResponsePart)
+	case u.DataPart != nil:
+		return json.Marshal(u.DataPart)
+	}
+	return nil, errors.New("part: no field set")
+}
+
+// UnmarshalJSON unmarshals data into the field of u for its type.
+func (u *part) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*u = part{}
+	switch {
+	case fields["text"] != nil:
+		u.TextPart = new(textPart)
+		return json.Unmarshal(data, u.TextPart)
+	case fields["media"] != nil:
+		u.MediaPart = new(mediaPart)
+		return json.Unmarshal(data, u.MediaPart)
+	case fields["toolRequest"] != nil:
+		u.ToolRequestPart = new(toolRequestPart)
+		return json.Unmarshal(data, u.ToolRequestPart)
+	case fields["toolResponse"] != nil:
+		u.ToolResponsePart = new(toolResponsePart)
+		return json.Unmarshal(data, u.ToolResponsePart)
+	}
+	u.DataPart = new(dataPart)
+	return json.Unmarshal(data, u.DataPart)
+}
+
 // Role indicates which entity is responsible for the content of a message.
 type Role string
 
@@ -161,11 +221,18 @@ type ToolDefinition struct {
 	Description string `json:"description,omitempty"`
 	// Valid JSON Schema representing the input of the tool.
 	InputSchema map[string]any `json:"inputSchema,omitempty"`
-	Name        string         `json:"name,omitempty"`
+	// additional metadata for this tool definition
+	Metadata map[string]any `json:"metadata,omitempty"`
+	Name     string         `json:"name,omitempty"`
 	// Valid JSON Schema describing the output of the tool.
 	OutputSchema map[string]any `json:"outputSchema,omitempty"`
 }
 
+type toolRequestPart struct {
+	Metadata    map[string]any `json:"metadata,omitempty"`
+	ToolRequest *ToolRequest   `json:"toolRequest,omitempty"`
+}
+
 // A ToolRequest is a message from the model to the client that it should run a
 // specific tool and pass a [ToolResponse] to the model on the next chat request it makes.
 // Any ToolRequest will correspond to some [ToolDefinition] previously sent by the client.
@@ -176,6 +243,11 @@ type ToolRequest struct {
 	Name  string         `json:"name,omitempty"`
 }
 
+type toolResponsePart struct {
+	Metadata     map[string]any `json:"metadata,omitempty"`
+	ToolResponse *ToolResponse  `json:"toolResponse,omitempty"`
+}
+
 // A ToolResponse is a message from the client to the model containing
 // the results of running a specific tool on the arguments passed to the client
 // by the model in a [ToolRequest].
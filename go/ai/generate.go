@@ -22,6 +22,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/firebase/genkit/go/core"
 	"github.com/firebase/genkit/go/core/logger"
@@ -112,6 +113,10 @@ type generateParams struct {
 	Stream       ModelStreamingCallback
 	History      []*Message
 	SystemPrompt *Message
+	Credentials  CredentialProvider
+	Hedging      *hedging
+	ToolsSet     bool // WithTools was called
+	ReturnTools  bool
 }
 
 // GenerateOption configures params of the Generate call.
@@ -189,14 +194,33 @@ func WithContext(c ...any) GenerateOption {
 // WithTools adds provided tools to ModelRequest.
 func WithTools(tools ...Tool) GenerateOption {
 	return func(req *generateParams) error {
-		if req.Request.Tools != nil {
+		if req.ToolsSet {
 			return errors.New("cannot set Request.Tools (WithTools) more than once")
 		}
-		var toolDefs []*ToolDefinition
+		req.ToolsSet = true
 		for _, t := range tools {
-			toolDefs = append(toolDefs, t.Definition())
+			req.Request.Tools = append(req.Request.Tools, t.Definition())
 		}
-		req.Request.Tools = toolDefs
+		return nil
+	}
+}
+
+// WithToolDefinitions adds tools that are not defined in Genkit, such as
+// tools run by another framework, to the ModelRequest. Genkit cannot run
+// them, so use it together with [WithReturnToolRequests].
+func WithToolDefinitions(defs ...*ToolDefinition) GenerateOption {
+	return func(req *generateParams) error {
+		req.Request.Tools = append(req.Request.Tools, defs...)
+		return nil
+	}
+}
+
+// WithReturnToolRequests makes Generate return the model's tool requests to
+// the caller instead of running the tools and sending their results back
+// to the model. The caller can find the requests in the response message.
+func WithReturnToolRequests(ret bool) GenerateOption {
+	return func(req *generateParams) error {
+		req.ReturnTools = ret
 		return nil
 	}
 }
@@ -262,6 +286,19 @@ func Generate(ctx context.Context, r *registry.Registry, opts ...GenerateOption)
 		req.Request.Messages = []*Message{req.SystemPrompt}
 		req.Request.Messages = append(req.Request.Messages, prev...)
 	}
+	if req.Credentials != nil {
+		ctx = WithCredentialProvider(ctx, req.Credentials)
+	}
+	if req.Hedging != nil || req.ReturnTools {
+		m, ok := req.Model.(*modelActionDef)
+		if !ok {
+			if req.Hedging != nil {
+				return nil, errors.New("hedging requires a model defined with DefineModel")
+			}
+			return nil, errors.New("returning tool requests requires a model defined with DefineModel")
+		}
+		return m.generate(ctx, r, req.Request, req.Stream, req.Hedging, req.ReturnTools)
+	}
 
 	return req.Model.Generate(ctx, r, req.Request, req.Stream)
 }
@@ -293,6 +330,12 @@ func GenerateData(ctx context.Context, r *registry.Registry, value any, opts ...
 
 // Generate applies the [Action] to provided request, handling tool requests and handles streaming.
 func (m *modelActionDef) Generate(ctx context.Context, r *registry.Registry, req *ModelRequest, cb ModelStreamingCallback) (*ModelResponse, error) {
+	return m.generate(ctx, r, req, cb, nil, false)
+}
+
+// generate implements Generate. If h is non-nil, each call to the model is hedged.
+// If returnTools is true, tool requests are returned instead of run.
+func (m *modelActionDef) generate(ctx context.Context, r *registry.Registry, req *ModelRequest, cb ModelStreamingCallback, h *hedging, returnTools bool) (*ModelResponse, error) {
 	if m == nil {
 		return nil, errors.New("Generate called on a nil Model; check that all models are defined")
 	}
@@ -300,9 +343,14 @@ func (m *modelActionDef) Generate(ctx context.Context, r *registry.Registry, req
 		return nil, err
 	}
 
-	a := (*core.Action[*ModelRequest, *ModelResponse, *ModelResponseChunk])(m)
 	for {
-		resp, err := a.Run(ctx, req, cb)
+		var resp *ModelResponse
+		var err error
+		if h != nil {
+			resp, err = h.run(ctx, r, m, req, cb)
+		} else {
+			resp, err = m.run(ctx, req, cb)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -312,6 +360,9 @@ func (m *modelActionDef) Generate(ctx context.Context, r *registry.Registry, req
 			return nil, err
 		}
 		resp.Message = msg
+		if returnTools {
+			return resp, nil
+		}
 
 		newReq, err := handleToolRequest(ctx, r, req, resp)
 		if err != nil {
@@ -327,6 +378,11 @@ func (m *modelActionDef) Generate(ctx context.Context, r *registry.Registry, req
 
 func (i *modelActionDef) Name() string { return (*modelAction)(i).Name() }
 
+// run runs the model action once.
+func (m *modelActionDef) run(ctx context.Context, req *ModelRequest, cb ModelStreamingCallback) (*ModelResponse, error) {
+	return (*modelAction)(m).Run(ctx, req, cb)
+}
+
 // conformOutput appends a message to the request indicating conformance to the expected schema.
 func conformOutput(req *ModelRequest) error {
 	if req.Output != nil && req.Output.Format == OutputFormatJSON && len(req.Messages) > 0 {
@@ -398,7 +454,9 @@ func handleToolRequest(ctx context.Context, r *registry.Registry, req *ModelRequ
 	if tool == nil {
 		return nil, fmt.Errorf("tool %v not found", toolReq.Name)
 	}
+	start := time.Now()
 	to, err := tool.RunRaw(ctx, toolReq.Input)
+	core.ReportDone(ctx, core.ProgressToolInvoked, toolReq.Name, start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -487,3 +545,14 @@ func (m *Message) Text() string {
 	}
 	return sb.String()
 }
+
+// An UnsupportedConfigError is returned by a model that cannot honor
+// an option set in the request's [GenerationCommonConfig].
+type UnsupportedConfigError struct {
+	Model  string // the name of the model
+	Option string // the JSON name of the option, such as "seed"
+}
+
+func (e *UnsupportedConfigError) Error() string {
+	return fmt.Sprintf("model %s does not support the %q option", e.Model, e.Option)
+}
@@ -328,6 +328,29 @@ func TestGenerate(t *testing.T) {
 			t.Errorf("Request diff (+got -want):\n%s", diff)
 		}
 	})
+
+	t.Run("returns tool requests", func(t *testing.T) {
+		toolModel := DefineModel(r, "test", "toolRequester", nil, func(ctx context.Context, gr *ModelRequest, msc ModelStreamingCallback) (*ModelResponse, error) {
+			msg := &Message{Role: RoleModel}
+			for _, t := range gr.Tools {
+				msg.Content = append(msg.Content, NewToolRequestPart(&ToolRequest{Name: t.Name, Input: map[string]any{}}))
+			}
+			return &ModelResponse{Request: gr, Message: msg}, nil
+		})
+		res, err := Generate(context.Background(), r,
+			WithModel(toolModel),
+			WithTextPrompt("search"),
+			WithToolDefinitions(&ToolDefinition{Name: "search", InputSchema: map[string]any{"type": "object"}}),
+			WithReturnToolRequests(true),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts := res.Message.Content
+		if len(parts) != 1 || !parts[0].IsToolRequest() || parts[0].ToolRequest.Name != "search" {
+			t.Errorf("got %+v, want one tool request for search", parts)
+		}
+	})
 }
 
 func TestIsDefinedModel(t *testing.T) {
@@ -0,0 +1,172 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ai
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/firebase/genkit/go/core/tracing"
+	"github.com/firebase/genkit/go/internal/registry"
+)
+
+// hedging holds the parameters set by [WithHedging].
+type hedging struct {
+	delay     time.Duration
+	alternate *modelActionDef // nil for the same model
+}
+
+// WithHedging hedges the model calls of the generate request against slow
+// responses. If the model has not responded within delay, the request is
+// sent again, to alternate if it is non-nil or else to the same model.
+// The first successful response is used and the other call is cancelled.
+// Both calls appear in the trace.
+//
+// When streaming, the request commits to whichever call streams first.
+// Tools requested by the model are run once, not once per call.
+func WithHedging(delay time.Duration, alternate Model) GenerateOption {
+	return func(req *generateParams) error {
+		if req.Hedging != nil {
+			return errors.New("cannot set hedging (WithHedging) more than once")
+		}
+		if delay < 0 {
+			return errors.New("hedging delay must not be negative")
+		}
+		h := &hedging{delay: delay}
+		if alternate != nil {
+			m, ok := alternate.(*modelActionDef)
+			if !ok {
+				return errors.New("hedging requires a model defined with DefineModel")
+			}
+			h.alternate = m
+		}
+		req.Hedging = h
+		return nil
+	}
+}
+
+// errHedgeLost is returned to a streaming call after the request has
+// committed to the other call.
+var errHedgeLost = errors.New("hedged call lost to another call")
+
+// run calls m with req, and calls the alternate model too if m is slow.
+func (h *hedging) run(ctx context.Context, r *registry.Registry, m *modelActionDef, req *ModelRequest, cb ModelStreamingCallback) (*ModelResponse, error) {
+	models := [2]*modelActionDef{m, m}
+	if h.alternate != nil {
+		models[1] = h.alternate
+	}
+	type result struct {
+		i    int
+		resp *ModelResponse
+		err  error
+	}
+	results := make(chan result, len(models))
+
+	var (
+		mu      sync.Mutex
+		cancels [2]context.CancelFunc
+		winner  = -1
+	)
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, c := range cancels {
+			if c != nil {
+				c()
+			}
+		}
+	}()
+	// claim makes call i the winner, if there is none yet, and cancels the
+	// other call. It reports whether i is the winner.
+	claim := func(i int) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if winner < 0 {
+			winner = i
+			if c := cancels[1-i]; c != nil {
+				c()
+			}
+		}
+		return winner == i
+	}
+	start := func(i int) {
+		actx, cancel := context.WithCancel(ctx)
+		mu.Lock()
+		cancels[i] = cancel
+		mu.Unlock()
+		go func() {
+			name := "hedge/" + strconv.Itoa(i+1)
+			resp, err := tracing.RunInNewSpan(actx, r.TracingState(), name, "util", false, req,
+				func(ctx context.Context, req *ModelRequest) (*ModelResponse, error) {
+					tracing.SetCustomMetadataAttr(ctx, "hedge:model", models[i].Name())
+					var acb ModelStreamingCallback
+					if cb != nil {
+						acb = func(ctx context.Context, c *ModelResponseChunk) error {
+							if !claim(i) {
+								return errHedgeLost
+							}
+							return cb(ctx, c)
+						}
+					}
+					resp, err := models[i].run(ctx, req, acb)
+					won := err == nil && claim(i)
+					tracing.SetCustomMetadataAttr(ctx, "hedge:won", strconv.FormatBool(won))
+					return resp, err
+				})
+			results <- result{i, resp, err}
+		}()
+	}
+
+	start(0)
+	timer := time.NewTimer(h.delay)
+	defer timer.Stop()
+	var errs [2]error
+	started, pending := 1, 1
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			mu.Lock()
+			decided := winner >= 0
+			mu.Unlock()
+			if !decided && ctx.Err() == nil {
+				start(1)
+				started++
+				pending++
+			}
+		case res := <-results:
+			pending--
+			mu.Lock()
+			won := winner == res.i
+			mu.Unlock()
+			if won && res.err == nil {
+				return res.resp, nil
+			}
+			errs[res.i] = res.err
+			if started == 1 {
+				// The first call failed before the hedge was sent.
+				return nil, res.err
+			}
+		}
+	}
+	// Both calls failed. Prefer the error of the call that streamed, if any,
+	// since the other was cancelled; otherwise that of the first call.
+	if winner >= 0 {
+		return nil, errs[winner]
+	}
+	return nil, errs[0]
+}
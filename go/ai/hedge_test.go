@@ -0,0 +1,159 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ai
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/core/tracing"
+	"github.com/firebase/genkit/go/internal/registry"
+)
+
+func TestHedging(t *testing.T) {
+	hr, err := registry.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu    sync.Mutex
+		spans = map[string]map[string]string{} // hedge span name to metadata
+	)
+	hr.TracingState().RegisterSpanObserver(func(_ context.Context, sr *tracing.SpanRecord) {
+		if sr.Type == "util" {
+			mu.Lock()
+			spans[sr.Name] = sr.Metadata
+			mu.Unlock()
+		}
+	})
+
+	textResponse := func(req *ModelRequest, text string) *ModelResponse {
+		return &ModelResponse{Request: req, Message: NewModelTextMessage(text)}
+	}
+	slowCanceled := make(chan struct{}, 10)
+	// slow responds after 300ms, streaming a chunk first.
+	slow := DefineModel(hr, "test", "slow", nil, func(ctx context.Context, req *ModelRequest, cb ModelStreamingCallback) (*ModelResponse, error) {
+		if cb != nil {
+			if err := cb(ctx, &ModelResponseChunk{Content: []*Part{NewTextPart("s")}}); err != nil {
+				return nil, err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			slowCanceled <- struct{}{}
+			return nil, ctx.Err()
+		case <-time.After(300 * time.Millisecond):
+			return textResponse(req, "slow"), nil
+		}
+	})
+	fast := DefineModel(hr, "test", "fast", nil, func(ctx context.Context, req *ModelRequest, _ ModelStreamingCallback) (*ModelResponse, error) {
+		return textResponse(req, "fast"), nil
+	})
+	errFailed := errors.New("failed")
+	failing := DefineModel(hr, "test", "failing", nil, func(context.Context, *ModelRequest, ModelStreamingCallback) (*ModelResponse, error) {
+		return nil, errFailed
+	})
+
+	ctx := context.Background()
+	generate := func(opts ...GenerateOption) (string, error) {
+		mu.Lock()
+		clear(spans)
+		mu.Unlock()
+		return GenerateText(ctx, hr, append(opts, WithTextPrompt("hi"))...)
+	}
+
+	t.Run("hedge wins", func(t *testing.T) {
+		got, err := generate(WithModel(slow), WithHedging(10*time.Millisecond, fast))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "fast" {
+			t.Errorf("got %q, want fast", got)
+		}
+		select {
+		case <-slowCanceled:
+		case <-time.After(500 * time.Millisecond):
+			t.Error("slow call was not cancelled")
+		}
+		// Wait for the losing span to end.
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		if got := spans["hedge/1"]; got["hedge:model"] != "test/slow" || got["hedge:won"] != "false" {
+			t.Errorf("got first call metadata %v", got)
+		}
+		if got := spans["hedge/2"]; got["hedge:model"] != "test/fast" || got["hedge:won"] != "true" {
+			t.Errorf("got second call metadata %v", got)
+		}
+	})
+
+	t.Run("no hedge needed", func(t *testing.T) {
+		got, err := generate(WithModel(fast), WithHedging(time.Second, slow))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "fast" {
+			t.Errorf("got %q, want fast", got)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := spans["hedge/2"]; ok {
+			t.Error("hedged call was made")
+		}
+	})
+
+	t.Run("error before delay", func(t *testing.T) {
+		if _, err := generate(WithModel(failing), WithHedging(time.Second, fast)); !errors.Is(err, errFailed) {
+			t.Errorf("got %v, want %v", err, errFailed)
+		}
+	})
+
+	t.Run("error after hedge", func(t *testing.T) {
+		got, err := generate(WithModel(slow), WithHedging(10*time.Millisecond, failing))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "slow" {
+			t.Errorf("got %q, want slow", got)
+		}
+	})
+
+	t.Run("streaming commits", func(t *testing.T) {
+		var chunks []string
+		got, err := generate(WithModel(slow), WithHedging(10*time.Millisecond, fast),
+			WithStreaming(func(_ context.Context, c *ModelResponseChunk) error {
+				chunks = append(chunks, c.Text())
+				return nil
+			}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "slow" || len(chunks) != 1 {
+			t.Errorf("got %q with chunks %q, want slow with one chunk", got, chunks)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := spans["hedge/2"]; ok {
+			t.Error("hedged call was made after streaming started")
+		}
+	})
+
+	if _, err := generate(WithModel(fast), WithHedging(time.Second, nil), WithHedging(time.Second, nil)); err == nil {
+		t.Error("got nil error for hedging set twice")
+	}
+}
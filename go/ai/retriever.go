@@ -17,6 +17,7 @@ package ai
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/firebase/genkit/go/core"
 	"github.com/firebase/genkit/go/internal/atype"
@@ -117,7 +118,10 @@ func (r *retrieverActionDef) Retrieve(ctx context.Context, req *RetrieverRequest
 	if r == nil {
 		return nil, errors.New("Retriever called on a nil Retriever; check that all retrievers are defined")
 	}
-	return (*retrieverAction)(r).Run(ctx, req, nil)
+	start := time.Now()
+	resp, err := (*retrieverAction)(r).Run(ctx, req, nil)
+	core.ReportDone(ctx, core.ProgressRetrievalDone, r.Name(), start, err)
+	return resp, err
 }
 
 // RetrieveOption configures params of the Retrieve call.
@@ -0,0 +1,187 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/firebase/genkit/go/core"
+	"github.com/firebase/genkit/go/internal/atype"
+	"github.com/firebase/genkit/go/internal/registry"
+)
+
+// Transcriber represents a speech-to-text service.
+type Transcriber interface {
+	// Name returns the registry name of the transcriber.
+	Name() string
+	// Transcribe converts the audio in the [TranscribeRequest] to text.
+	Transcribe(ctx context.Context, req *TranscribeRequest) (*TranscribeResponse, error)
+}
+
+// A transcriberActionDef is used to convert speech to text.
+type transcriberActionDef core.Action[*TranscribeRequest, *TranscribeResponse, struct{}]
+
+type transcriberAction = core.Action[*TranscribeRequest, *TranscribeResponse, struct{}]
+
+// TranscribeRequest is the input to a [Transcriber].
+type TranscribeRequest struct {
+	// The audio to transcribe, as a media part.
+	Audio *Part `json:"audio"`
+	// The BCP-47 code of the spoken language, such as "en-US".
+	// If empty, the transcriber detects the language if it can.
+	Language string `json:"language,omitempty"`
+	// Whether to return the timed segments of the transcript.
+	Timestamps bool `json:"timestamps,omitempty"`
+	// Options specific to the transcriber.
+	Options any `json:"options,omitempty"`
+}
+
+// TranscribeResponse is the output of a [Transcriber].
+type TranscribeResponse struct {
+	// The full transcript.
+	Text string `json:"text"`
+	// The BCP-47 code of the spoken language, if known.
+	Language string `json:"language,omitempty"`
+	// The timed segments of the transcript, in order.
+	// Set only if timestamps were requested.
+	Segments []*TranscriptSegment `json:"segments,omitempty"`
+}
+
+// A TranscriptSegment is a part of a transcript along with the
+// time at which it was spoken.
+type TranscriptSegment struct {
+	// Offsets from the start of the audio, in seconds.
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// DefineTranscriber registers the given transcribe function as an action,
+// and returns a [Transcriber] that runs it.
+func DefineTranscriber(
+	r *registry.Registry,
+	provider, name string,
+	transcribe func(context.Context, *TranscribeRequest) (*TranscribeResponse, error),
+) Transcriber {
+	return (*transcriberActionDef)(core.DefineAction(r, provider, name, atype.Transcriber, nil, transcribe))
+}
+
+// IsDefinedTranscriber reports whether a transcriber is defined.
+func IsDefinedTranscriber(r *registry.Registry, provider, name string) bool {
+	return LookupTranscriber(r, provider, name) != nil
+}
+
+// LookupTranscriber looks up a [Transcriber] registered by [DefineTranscriber].
+// It returns nil if the transcriber was not defined.
+func LookupTranscriber(r *registry.Registry, provider, name string) Transcriber {
+	action := core.LookupActionFor[*TranscribeRequest, *TranscribeResponse, struct{}](r, atype.Transcriber, provider, name)
+	if action == nil {
+		return nil
+	}
+	return (*transcriberActionDef)(action)
+}
+
+// Transcribe runs the given [Transcriber].
+func (t *transcriberActionDef) Transcribe(ctx context.Context, req *TranscribeRequest) (*TranscribeResponse, error) {
+	if t == nil {
+		return nil, errors.New("Transcribe called on a nil Transcriber; check that all transcribers are defined")
+	}
+	if req.Audio == nil || !req.Audio.IsMedia() || !strings.HasPrefix(req.Audio.ContentType, "audio/") {
+		return nil, errors.New("Transcribe: the request audio must be a media part with an audio content type")
+	}
+	return (*transcriberAction)(t).Run(ctx, req, nil)
+}
+
+func (t *transcriberActionDef) Name() string {
+	return (*transcriberAction)(t).Name()
+}
+
+// SpeechSynthesizer represents a text-to-speech service.
+type SpeechSynthesizer interface {
+	// Name returns the registry name of the speech synthesizer.
+	Name() string
+	// SynthesizeSpeech converts the text in the [SynthesizeSpeechRequest] to audio.
+	SynthesizeSpeech(ctx context.Context, req *SynthesizeSpeechRequest) (*SynthesizeSpeechResponse, error)
+}
+
+// A speechSynthesizerActionDef is used to convert text to speech.
+type speechSynthesizerActionDef core.Action[*SynthesizeSpeechRequest, *SynthesizeSpeechResponse, struct{}]
+
+type speechSynthesizerAction = core.Action[*SynthesizeSpeechRequest, *SynthesizeSpeechResponse, struct{}]
+
+// SynthesizeSpeechRequest is the input to a [SpeechSynthesizer].
+type SynthesizeSpeechRequest struct {
+	// The text to speak.
+	Text string `json:"text"`
+	// The name of the voice, as understood by the synthesizer.
+	// If empty, the synthesizer's default voice is used.
+	Voice string `json:"voice,omitempty"`
+	// The BCP-47 code of the language to speak, such as "en-US".
+	Language string `json:"language,omitempty"`
+	// The content type of the audio to return, such as "audio/mpeg"
+	// or "audio/wav". If empty, the synthesizer chooses.
+	Format string `json:"format,omitempty"`
+	// Options specific to the synthesizer.
+	Options any `json:"options,omitempty"`
+}
+
+// SynthesizeSpeechResponse is the output of a [SpeechSynthesizer].
+type SynthesizeSpeechResponse struct {
+	// The spoken text, as a media part whose content type is
+	// the format of the audio.
+	Audio *Part `json:"audio"`
+}
+
+// DefineSpeechSynthesizer registers the given synthesize function as an action,
+// and returns a [SpeechSynthesizer] that runs it.
+func DefineSpeechSynthesizer(
+	r *registry.Registry,
+	provider, name string,
+	synthesize func(context.Context, *SynthesizeSpeechRequest) (*SynthesizeSpeechResponse, error),
+) SpeechSynthesizer {
+	return (*speechSynthesizerActionDef)(core.DefineAction(r, provider, name, atype.SpeechSynthesizer, nil, synthesize))
+}
+
+// IsDefinedSpeechSynthesizer reports whether a speech synthesizer is defined.
+func IsDefinedSpeechSynthesizer(r *registry.Registry, provider, name string) bool {
+	return LookupSpeechSynthesizer(r, provider, name) != nil
+}
+
+// LookupSpeechSynthesizer looks up a [SpeechSynthesizer] registered by [DefineSpeechSynthesizer].
+// It returns nil if the speech synthesizer was not defined.
+func LookupSpeechSynthesizer(r *registry.Registry, provider, name string) SpeechSynthesizer {
+	action := core.LookupActionFor[*SynthesizeSpeechRequest, *SynthesizeSpeechResponse, struct{}](r, atype.SpeechSynthesizer, provider, name)
+	if action == nil {
+		return nil
+	}
+	return (*speechSynthesizerActionDef)(action)
+}
+
+// SynthesizeSpeech runs the given [SpeechSynthesizer].
+func (s *speechSynthesizerActionDef) SynthesizeSpeech(ctx context.Context, req *SynthesizeSpeechRequest) (*SynthesizeSpeechResponse, error) {
+	if s == nil {
+		return nil, errors.New("SynthesizeSpeech called on a nil SpeechSynthesizer; check that all speech synthesizers are defined")
+	}
+	if req.Text == "" {
+		return nil, errors.New("SynthesizeSpeech: the request text is empty")
+	}
+	return (*speechSynthesizerAction)(s).Run(ctx, req, nil)
+}
+
+func (s *speechSynthesizerActionDef) Name() string {
+	return (*speechSynthesizerAction)(s).Name()
+}
@@ -0,0 +1,205 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ai
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/firebase/genkit/go/core/logger"
+	"github.com/firebase/genkit/go/core/tracing"
+	"github.com/firebase/genkit/go/internal/registry"
+)
+
+// A ToolCacheStore stores the results of tools defined with [DefineCachedTool].
+// Implementations must be safe for concurrent use.
+type ToolCacheStore interface {
+	// Get returns the result stored under key, and whether there is one.
+	// It must not return a result whose time to live has passed.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Put stores result under key for ttl, replacing any existing result.
+	// A zero ttl means the result does not expire.
+	Put(ctx context.Context, key string, result []byte, ttl time.Duration) error
+}
+
+// ToolCacheConfig configures the caching of a tool's results.
+type ToolCacheConfig struct {
+	// How long a result is cached. Zero means results do not expire.
+	TTL time.Duration
+	// Where results are cached. The default is a new [MemoryToolCacheStore]
+	// for the tool.
+	Store ToolCacheStore
+}
+
+// DefineCachedTool defines a tool function whose results are cached.
+// Calls with the same input, compared by its canonical JSON form, return
+// the cached result instead of calling fn until it expires. Errors are not
+// cached. Use it for deterministic tools, like lookups, whose results are
+// expensive to compute.
+//
+// The span of each call has the attribute "tool:cacheHit", which is "true"
+// if the result came from the cache. Failures of the store are logged, and
+// the tool runs as if the result were not cached.
+func DefineCachedTool[In, Out any](r *registry.Registry, name, description string, cfg ToolCacheConfig, fn func(ctx context.Context, input In) (Out, error)) *ToolDef[In, Out] {
+	store := cfg.Store
+	if store == nil {
+		store = &MemoryToolCacheStore{}
+	}
+	cached := func(ctx context.Context, input In) (Out, error) {
+		key, err := toolCacheKey(name, input)
+		if err != nil {
+			var zero Out
+			return zero, err
+		}
+		data, ok, err := store.Get(ctx, key)
+		if err != nil {
+			logger.FromContext(ctx).Warn("tool cache lookup failed", "tool", name, "error", err)
+		}
+		if ok {
+			var out Out
+			if err := json.Unmarshal(data, &out); err == nil {
+				tracing.SetCustomMetadataAttr(ctx, "tool:cacheHit", "true")
+				return out, nil
+			}
+			// The stored result is not an Out; replace it.
+		}
+		tracing.SetCustomMetadataAttr(ctx, "tool:cacheHit", "false")
+		out, err := fn(ctx, input)
+		if err != nil {
+			return out, err
+		}
+		data, err = json.Marshal(out)
+		if err == nil {
+			err = store.Put(ctx, key, data, cfg.TTL)
+		}
+		if err != nil {
+			logger.FromContext(ctx).Warn("tool cache store failed", "tool", name, "error", err)
+		}
+		return out, nil
+	}
+	return DefineTool(r, name, description, cached)
+}
+
+// toolCacheKey returns the cache key for a call of the named tool with input.
+// The key depends on the canonical JSON form of input, in which object keys
+// are sorted, so inputs that differ only in key order have the same key.
+func toolCacheKey(name string, input any) (string, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return "", fmt.Errorf("tool %s: marshaling input for cache key: %w", name, err)
+	}
+	// Decode numbers as json.Number, not float64, so that integers too
+	// large for a float64 keep all their digits.
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	// Marshaling maps sorts their keys.
+	if data, err = json.Marshal(v); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return strconv.Quote(name) + ":" + hex.EncodeToString(sum[:]), nil
+}
+
+// DefaultToolCacheSize is the number of results a [MemoryToolCacheStore]
+// keeps when its MaxEntries is not set.
+const DefaultToolCacheSize = 1000
+
+// MemoryToolCacheStore is a [ToolCacheStore] that keeps results in memory.
+// It holds at most MaxEntries results, evicting the least recently used one
+// when it is full. Expired results are removed when they are looked up or
+// evicted.
+// The zero value is ready to use.
+type MemoryToolCacheStore struct {
+	// MaxEntries is the number of results kept.
+	// If it is not positive, DefaultToolCacheSize is used.
+	MaxEntries int
+
+	mu      sync.Mutex
+	lru     *list.List // of *toolCacheEntry; front is most recently used
+	entries map[string]*list.Element
+	now     func() time.Time // for testing
+}
+
+type toolCacheEntry struct {
+	key     string
+	result  []byte
+	expires time.Time // zero for no expiry
+}
+
+// Get implements [ToolCacheStore.Get].
+func (s *MemoryToolCacheStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*toolCacheEntry)
+	if !e.expires.IsZero() && !s.timeNow().Before(e.expires) {
+		s.lru.Remove(el)
+		delete(s.entries, key)
+		return nil, false, nil
+	}
+	s.lru.MoveToFront(el)
+	return e.result, true, nil
+}
+
+// Put implements [ToolCacheStore.Put].
+func (s *MemoryToolCacheStore) Put(ctx context.Context, key string, result []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries == nil {
+		s.lru = list.New()
+		s.entries = map[string]*list.Element{}
+	}
+	e := &toolCacheEntry{key: key, result: result}
+	if ttl > 0 {
+		e.expires = s.timeNow().Add(ttl)
+	}
+	if el, ok := s.entries[key]; ok {
+		el.Value = e
+		s.lru.MoveToFront(el)
+		return nil
+	}
+	s.entries[key] = s.lru.PushFront(e)
+	size := s.MaxEntries
+	if size <= 0 {
+		size = DefaultToolCacheSize
+	}
+	for s.lru.Len() > size {
+		old := s.lru.Remove(s.lru.Back()).(*toolCacheEntry)
+		delete(s.entries, old.key)
+	}
+	return nil
+}
+
+func (s *MemoryToolCacheStore) timeNow() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ai
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/internal/registry"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestCachedTool(t *testing.T) {
+	r, err := registry.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.NewSpanRecorder()
+	r.RegisterSpanProcessor(spans)
+
+	now := time.Unix(0, 0)
+	store := &MemoryToolCacheStore{now: func() time.Time { return now }}
+	calls := 0
+	tool := DefineCachedTool(r, "lookup", "looks up a value", ToolCacheConfig{TTL: time.Minute, Store: store},
+		func(ctx context.Context, input map[string]any) (int, error) {
+			calls++
+			return calls, nil
+		})
+
+	ctx := context.Background()
+	run := func(input map[string]any, want float64) {
+		t.Helper()
+		got, err := LookupTool(r, "lookup").RunRaw(ctx, input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	run(map[string]any{"a": 1, "b": 2}, 1)
+	// Same input, in a different order in the JSON: cached.
+	if got, err := tool.Action().RunJSON(ctx, []byte(`{"b": 2, "a": 1}`), nil); err != nil || string(got) != "1" {
+		t.Errorf("got %s, %v, want 1", got, err)
+	}
+	// Different input: not cached.
+	run(map[string]any{"a": 2}, 2)
+	// After the TTL, the result is computed again.
+	now = now.Add(time.Minute)
+	run(map[string]any{"a": 1, "b": 2}, 3)
+
+	var hits []string
+	for _, s := range spans.Ended() {
+		for _, a := range s.Attributes() {
+			if a.Key == "genkit:metadata:tool:cacheHit" {
+				hits = append(hits, a.Value.AsString())
+			}
+		}
+	}
+	want := []string{"false", "true", "false", "false"}
+	if len(hits) != len(want) {
+		t.Fatalf("got cache hit attributes %v, want %v", hits, want)
+	}
+	for i := range want {
+		if hits[i] != want[i] {
+			t.Errorf("got cache hit attributes %v, want %v", hits, want)
+			break
+		}
+	}
+}
+
+func TestToolCacheKeyLargeIntegers(t *testing.T) {
+	type input struct {
+		ID int64 `json:"id"`
+	}
+	// These differ, but are the same as float64s.
+	k1, err := toolCacheKey("lookup", input{ID: 9007199254740993})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := toolCacheKey("lookup", input{ID: 9007199254740992})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k1 == k2 {
+		t.Errorf("inputs with IDs 9007199254740993 and 9007199254740992 have the same key %s", k1)
+	}
+}
+
+func TestMemoryToolCacheStoreEviction(t *testing.T) {
+	ctx := context.Background()
+	s := &MemoryToolCacheStore{MaxEntries: 2}
+	put := func(key string) {
+		t.Helper()
+		if err := s.Put(ctx, key, []byte(key), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	has := func(key string) bool {
+		t.Helper()
+		_, ok, err := s.Get(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+	put("a")
+	put("b")
+	// Looking up a makes b the least recently used.
+	if !has("a") {
+		t.Fatal("a is missing")
+	}
+	put("c")
+	if has("b") {
+		t.Error("b was not evicted")
+	}
+	if !has("a") || !has("c") {
+		t.Error("a or c was evicted")
+	}
+	// Replacing a result does not grow the store.
+	put("c")
+	if got := len(s.entries); got != 2 {
+		t.Errorf("got %d entries, want 2", got)
+	}
+}
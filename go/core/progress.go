@@ -0,0 +1,82 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/firebase/genkit/go/internal/base"
+)
+
+// ProgressKind is the kind of a [ProgressEvent].
+type ProgressKind string
+
+const (
+	ProgressStepStarted   ProgressKind = "stepStarted"   // a flow step (genkit.Run) started
+	ProgressStepCompleted ProgressKind = "stepCompleted" // a flow step finished
+	ProgressToolInvoked   ProgressKind = "toolInvoked"   // a tool requested by a model finished
+	ProgressRetrievalDone ProgressKind = "retrievalDone" // a retriever finished
+)
+
+// A ProgressEvent describes the progress of a running flow, so a client
+// can show what the flow is doing while it waits for results.
+type ProgressEvent struct {
+	Kind ProgressKind `json:"kind"`
+	// The name of the step, tool or retriever.
+	Name string `json:"name"`
+	// How long the step, tool or retriever ran, in milliseconds.
+	// Zero for ProgressStepStarted.
+	DurationMs float64 `json:"durationMs,omitempty"`
+	// The error message, if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+// A ProgressReporter receives progress events. It may be called
+// concurrently, and should return quickly.
+type ProgressReporter func(context.Context, *ProgressEvent)
+
+var progressKey = base.NewContextKey[ProgressReporter]()
+
+// WithProgressReporter returns a context in which [ReportProgress]
+// calls report.
+func WithProgressReporter(ctx context.Context, report ProgressReporter) context.Context {
+	return progressKey.NewContext(ctx, report)
+}
+
+// ReportProgress reports an event to the [ProgressReporter] of ctx.
+// It does nothing if there is none.
+func ReportProgress(ctx context.Context, ev *ProgressEvent) {
+	if report := progressKey.FromContext(ctx); report != nil {
+		report(ctx, ev)
+	}
+}
+
+// ReportDone reports an event of the given kind for name, which
+// started at start and ended now with err.
+func ReportDone(ctx context.Context, kind ProgressKind, name string, start time.Time, err error) {
+	if progressKey.FromContext(ctx) == nil {
+		return
+	}
+	ev := &ProgressEvent{
+		Kind:       kind,
+		Name:       name,
+		DurationMs: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	ReportProgress(ctx, ev)
+}
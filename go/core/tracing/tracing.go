@@ -18,6 +18,7 @@ package tracing
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/firebase/genkit/go/core/logger"
 	"github.com/firebase/genkit/go/internal/base"
@@ -31,6 +32,9 @@ import (
 type State struct {
 	tp     *sdktrace.TracerProvider // references Stores
 	tracer trace.Tracer             // returned from tp.Tracer(), cached
+
+	mu        sync.Mutex
+	observers []SpanObserver
 }
 
 func NewState() *State {
@@ -45,6 +49,55 @@ func (ts *State) RegisterSpanProcessor(sp sdktrace.SpanProcessor) {
 	ts.tp.RegisterSpanProcessor(sp)
 }
 
+// Shutdown flushes the spans held by the registered span processors, such
+// as batch processors, and shuts the processors down. It should be called
+// once, when the program is exiting.
+func (ts *State) Shutdown(ctx context.Context) error {
+	return ts.tp.Shutdown(ctx)
+}
+
+// A SpanObserver is called at the end of every span created by [RunInNewSpan].
+// Unlike a span processor, it sees every span whether or not the span
+// is sampled, along with the span's Go input and output values.
+// The context is the one passed to the span's function, so it holds
+// any values the function's caller added, such as an auth context.
+// Observers are called synchronously, and must be safe for concurrent use.
+type SpanObserver func(ctx context.Context, sr *SpanRecord)
+
+// A SpanRecord describes a finished span.
+type SpanRecord struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string // empty for a root span
+	Name         string
+	Type         string // the span type, such as "action" or "flow"
+	Path         string // slash-separated list of names from the root span to this one
+	IsRoot       bool
+	Input        any
+	Output       any   // nil if Err is non-nil
+	Err          error // the error returned by the span's function
+	Metadata     map[string]string
+	StartTime    time.Time
+	EndTime      time.Time
+}
+
+// RegisterSpanObserver adds o to the observers called at the end of each span.
+func (ts *State) RegisterSpanObserver(o SpanObserver) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.observers = append(ts.observers, o)
+}
+
+// notify calls each registered observer with sr.
+func (ts *State) notify(ctx context.Context, sr *SpanRecord) {
+	ts.mu.Lock()
+	obs := ts.observers
+	ts.mu.Unlock()
+	for _, o := range obs {
+		o(ctx, sr)
+	}
+}
+
 // WriteTelemetryImmediate adds a telemetry server to the tracingState.
 // Traces are saved immediately as they are finshed.
 // Use this for a gtrace.Store with a fast Save method,
@@ -107,6 +160,7 @@ func RunInNewSpan[I, O any](
 	if spanType != "" {
 		opts = append(opts, trace.WithAttributes(attribute.String(spanTypeAttr, spanType)))
 	}
+	parentSpanContext := trace.SpanContextFromContext(ctx)
 	ctx, span := tstate.tracer.Start(ctx, name, opts...)
 	defer span.End()
 	// At the end, copy some of the spanMetadata to the OpenTelemetry span.
@@ -114,7 +168,28 @@ func RunInNewSpan[I, O any](
 	// Add the spanMetadata to the context, so the function can access it.
 	ctx = spanMetaKey.NewContext(ctx, sm)
 	// Run the function.
+	start := time.Now()
 	output, err := f(ctx, input)
+	sr := &SpanRecord{
+		TraceID:   span.SpanContext().TraceID().String(),
+		SpanID:    span.SpanContext().SpanID().String(),
+		Name:      name,
+		Type:      spanType,
+		Path:      sm.Path,
+		IsRoot:    isRoot,
+		Input:     input,
+		Err:       err,
+		Metadata:  sm.attrsCopy(),
+		StartTime: start,
+		EndTime:   time.Now(),
+	}
+	if parentSpanContext.IsValid() {
+		sr.ParentSpanID = parentSpanContext.SpanID().String()
+	}
+	if err == nil {
+		sr.Output = output
+	}
+	tstate.notify(ctx, sr)
 
 	if err != nil {
 		sm.State = spanStateError
@@ -163,6 +238,17 @@ func (sm *spanMetadata) SetAttr(k, v string) {
 	sm.attrs[k] = v
 }
 
+// attrsCopy returns a copy of the additional attributes.
+func (sm *spanMetadata) attrsCopy() map[string]string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	m := make(map[string]string, len(sm.attrs))
+	for k, v := range sm.attrs {
+		m[k] = v
+	}
+	return m
+}
+
 // attributes returns some information about the spanMetadata
 // as a slice of OpenTelemetry attributes.
 func (sm *spanMetadata) attributes() []attribute.KeyValue {
@@ -15,11 +15,14 @@
 package tracing
 
 import (
+	"context"
+	"errors"
 	"slices"
 	"strconv"
 	"testing"
 
 	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 // TODO: add tests that compare tracing data saved to disk with goldens.
@@ -51,3 +54,36 @@ func TestSpanMetadata(t *testing.T) {
 		t.Errorf("\ngot  %v\nwant %v", got, want)
 	}
 }
+
+func TestSpanObserver(t *testing.T) {
+	// Observers should see spans even when they are not sampled.
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample()))
+	ts := &State{tp: tp, tracer: tp.Tracer("test")}
+	var records []*SpanRecord
+	ts.RegisterSpanObserver(func(_ context.Context, sr *SpanRecord) {
+		records = append(records, sr)
+	})
+	errBoom := errors.New("boom")
+	_, err := RunInNewSpan(context.Background(), ts, "outer", "flow", true, 1,
+		func(ctx context.Context, in int) (int, error) {
+			SetCustomMetadataAttr(ctx, "k", "v")
+			return RunInNewSpan(ctx, ts, "inner", "action", false, in+1,
+				func(ctx context.Context, in int) (int, error) { return in, errBoom })
+		})
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("got error %v, want %v", err, errBoom)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	inner, outer := records[0], records[1]
+	if inner.Name != "inner" || inner.Path != "/outer/inner" || inner.Type != "action" || inner.Input != 2 || inner.Output != nil || inner.Err != errBoom {
+		t.Errorf("bad inner record: %+v", inner)
+	}
+	if outer.Name != "outer" || !outer.IsRoot || outer.Metadata["k"] != "v" || outer.ParentSpanID != "" {
+		t.Errorf("bad outer record: %+v", outer)
+	}
+	if inner.TraceID != outer.TraceID || inner.ParentSpanID != outer.SpanID || inner.TraceID == "00000000000000000000000000000000" {
+		t.Errorf("bad span IDs: inner %+v, outer %+v", inner, outer)
+	}
+}
@@ -97,6 +97,7 @@ type Flow[In, Out, Stream any] struct {
 	fn           core.Func[In, Out, Stream] // The function to run.
 	stateStore   core.FlowStateStore        // Where FlowStates are stored, to support resumption.
 	tstate       *tracing.State             // set from the action when the flow is defined
+	reg          *registry.Registry         // the registry the flow is defined in, to track running flows
 	inputSchema  *jsonschema.Schema         // Schema of the input to the flow
 	outputSchema *jsonschema.Schema         // Schema of the output out of the flow
 	auth         FlowAuth                   // Auth provider and policy checker for the flow.
@@ -108,6 +109,7 @@ type Flow[In, Out, Stream any] struct {
 // runOptions configures a single flow run.
 type runOptions struct {
 	authContext AuthContext // Auth context to pass to auth policy checker when calling a flow directly.
+	progress    bool        // Deliver progress events from Stream.
 }
 
 // flowOptions configures a flow.
@@ -166,6 +168,16 @@ func WithLocalAuth(authContext AuthContext) FlowRunOption {
 	}
 }
 
+// WithProgress configures an option to deliver progress events from [Flow.Stream],
+// in the Progress field of a [StreamFlowValue]. Events are delivered when flow
+// steps (see [Run]) start and complete, when a model's tool request is run, and
+// when a retriever returns.
+func WithProgress() FlowRunOption {
+	return func(opts *runOptions) {
+		opts.progress = true
+	}
+}
+
 // DefineFlow creates a Flow that runs fn, and registers it as an action.
 //
 // fn takes an input of type In and returns an output of type Out.
@@ -237,6 +249,7 @@ func defineFlow[In, Out, Stream any](r *registry.Registry, name string, fn core.
 	}
 	core.DefineStreamingAction(r, "", f.name, atype.Flow, metadata, afunc)
 	f.tstate = r.TracingState()
+	f.reg = r
 	r.RegisterFlow(f)
 	return f
 }
@@ -359,7 +372,7 @@ func (f *Flow[In, Out, Stream]) runJSON(ctx context.Context, authHeader string,
 			return cb(ctx, json.RawMessage(bytes))
 		}
 	}
-	fstate, err := f.start(ctx, in, callback)
+	fstate, err := f.start(newCtx, in, callback)
 	if err != nil {
 		return nil, err
 	}
@@ -404,6 +417,11 @@ func (f *Flow[In, Out, Stream]) start(ctx context.Context, input In, cb streamin
 	if err != nil {
 		return nil, err
 	}
+	end, err := f.reg.BeginFlow()
+	if err != nil {
+		return nil, err
+	}
+	defer end()
 	state := newFlowState[In, Out](flowID, f.name, input)
 	f.execute(ctx, state, "start", cb)
 	return state, nil
@@ -561,6 +579,8 @@ var flowContextKey = base.NewContextKey[flowContexter]()
 // Each call to Run results in a new step in the flow.
 // A step has its own span in the trace, and its result is cached so that if the flow
 // is restarted, f will not be called a second time.
+// If progress is being reported (see [WithProgress]), the step reports
+// when it starts and completes.
 func Run[Out any](ctx context.Context, name string, f func() (Out, error)) (Out, error) {
 	// from js/flow/src/steps.ts
 	fc := flowContextKey.FromContext(ctx)
@@ -590,7 +610,10 @@ func Run[Out any](ctx context.Context, name string, f func() (Out, error)) (Out,
 			tracing.SetCustomMetadataAttr(ctx, "flow:state", "cached")
 			return t, nil
 		}
+		core.ReportProgress(ctx, &core.ProgressEvent{Kind: core.ProgressStepStarted, Name: uName})
+		start := time.Now()
 		t, err := f()
+		core.ReportDone(ctx, core.ProgressStepCompleted, uName, start, err)
 		if err != nil {
 			return base.Zero[Out](), err
 		}
@@ -628,11 +651,12 @@ func (f *Flow[In, Out, Stream]) run(ctx context.Context, input In, cb func(conte
 	return finishedOpResponse(state.Operation)
 }
 
-// StreamFlowValue is either a streamed value or a final output of a flow.
+// StreamFlowValue is either a streamed value, a progress event or a final output of a flow.
 type StreamFlowValue[Out, Stream any] struct {
-	Done   bool
-	Output Out    // valid if Done is true
-	Stream Stream // valid if Done is false
+	Done     bool
+	Output   Out                 // valid if Done is true
+	Stream   Stream              // valid if Done is false and Progress is nil
+	Progress *core.ProgressEvent // a progress event, if non-nil; see [WithProgress]
 }
 
 // Stream runs the flow on input and delivers both the streamed values and the final output.
@@ -648,19 +672,53 @@ type StreamFlowValue[Out, Stream any] struct {
 // Output field contains the final output; the yield function will not be called
 // again.
 //
+// If the value's Progress field is non-nil, it holds a progress event. Progress
+// events are delivered only with the [WithProgress] option.
+//
 // Otherwise the Stream field of the passed [StreamFlowValue] holds a streamed result.
 func (f *Flow[In, Out, Stream]) Stream(ctx context.Context, input In, opts ...FlowRunOption) func(func(*StreamFlowValue[Out, Stream], error) bool) {
 	return func(yield func(*StreamFlowValue[Out, Stream], error) bool) {
+		// Progress events may come from other goroutines, so calls
+		// to yield are serialized.
+		var (
+			mu      sync.Mutex
+			stopped bool
+		)
+		send := func(v *StreamFlowValue[Out, Stream]) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			if !stopped && !yield(v, nil) {
+				stopped = true
+			}
+			return !stopped
+		}
 		cb := func(ctx context.Context, s Stream) error {
 			if ctx.Err() != nil {
 				return ctx.Err()
 			}
-			if !yield(&StreamFlowValue[Out, Stream]{Stream: s}, nil) {
+			if !send(&StreamFlowValue[Out, Stream]{Stream: s}) {
 				return errStop
 			}
 			return nil
 		}
+		runOpts := &runOptions{}
+		for _, opt := range opts {
+			opt(runOpts)
+		}
+		if runOpts.progress {
+			ctx = core.WithProgressReporter(ctx, func(_ context.Context, ev *core.ProgressEvent) {
+				send(&StreamFlowValue[Out, Stream]{Progress: ev})
+			})
+		}
 		output, err := f.run(ctx, input, cb, opts...)
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			return
+		}
+		// Keep later progress events, from goroutines the flow left
+		// running, from reaching yield.
+		stopped = true
 		if err != nil {
 			yield(nil, err)
 		} else {
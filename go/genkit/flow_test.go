@@ -154,3 +154,54 @@ func TestFlowState(t *testing.T) {
 		t.Errorf("mismatch (-want, +got):\n%s", diff)
 	}
 }
+
+func TestFlowStreamProgress(t *testing.T) {
+	g, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := DefineStreamingFlow(g, "steps", func(ctx context.Context, n int, cb func(context.Context, string) error) (int, error) {
+		for i := range n {
+			if _, err := Run(ctx, "step", func() (int, error) { return i, nil }); err != nil {
+				return 0, err
+			}
+			if cb != nil {
+				if err := cb(ctx, "chunk"); err != nil {
+					return 0, err
+				}
+			}
+		}
+		return n, nil
+	})
+
+	collect := func(opts ...FlowRunOption) []string {
+		var got []string
+		f.Stream(context.Background(), 2, opts...)(func(v *StreamFlowValue[int, string], err error) bool {
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case v.Done:
+				got = append(got, "done")
+			case v.Progress != nil:
+				got = append(got, string(v.Progress.Kind)+" "+v.Progress.Name)
+			default:
+				got = append(got, v.Stream)
+			}
+			return true
+		})
+		return got
+	}
+	want := []string{
+		"stepStarted step", "stepCompleted step", "chunk",
+		"stepStarted step-1", "stepCompleted step-1", "chunk",
+		"done",
+	}
+	if diff := cmp.Diff(want, collect(WithProgress())); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+	// Without WithProgress, there are no progress events.
+	if diff := cmp.Diff([]string{"chunk", "chunk", "done"}, collect()); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
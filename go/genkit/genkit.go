@@ -25,8 +25,10 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core/tracing"
 	"github.com/firebase/genkit/go/internal/registry"
 	"github.com/invopop/jsonschema"
 
@@ -39,6 +41,15 @@ type Genkit struct {
 	reg *registry.Registry
 	// Options to configure the instance.
 	Opts *Options
+
+	mu       sync.Mutex
+	servers  []*http.Server // servers started by Start
+	hooks    []shutdownHook
+	stopping chan struct{} // closed when Shutdown is first called
+
+	shutdownOnce sync.Once
+	shutdownDone chan struct{} // closed when Shutdown has finished
+	shutdownErr  error
 }
 
 type Options struct {
@@ -57,6 +68,15 @@ type StartOptions struct {
 	// The names of flows to serve.
 	// If empty, all registered flows are served.
 	Flows []string
+	// How long to wait for [Genkit.Shutdown] when Start receives
+	// an interrupt signal or its context is cancelled.
+	// If zero, the default of 5 seconds is used.
+	ShutdownTimeout time.Duration
+	// Serve the playground, a web UI for running actions and browsing
+	// their traces, at "/playground/" on the development server.
+	// It needs neither Node nor the genkit CLI.
+	// It is only served in the "dev" environment.
+	Playground bool
 }
 
 // New creates a new Genkit instance.
@@ -75,8 +95,10 @@ func New(opts *Options) (*Genkit, error) {
 		}
 	}
 	return &Genkit{
-		reg:  r,
-		Opts: opts,
+		reg:          r,
+		Opts:         opts,
+		stopping:     make(chan struct{}),
+		shutdownDone: make(chan struct{}),
 	}, nil
 }
 
@@ -96,14 +118,16 @@ func New(opts *Options) (*Genkit, error) {
 //
 // Thus Start(nil) will start a dev server in the "dev" environment, will always start
 // a flow server, and will pause execution until the flow server terminates.
+//
+// When Start receives an interrupt signal or ctx is cancelled, it calls
+// [Genkit.Shutdown] and returns its result. If Shutdown is called by some
+// other goroutine, Start returns once it has finished.
 func (g *Genkit) Start(ctx context.Context, opts *StartOptions) error {
 	if opts == nil {
 		opts = &StartOptions{}
 	}
 	g.reg.Freeze()
 
-	var mu sync.Mutex
-	var servers []*http.Server
 	var wg sync.WaitGroup
 	errCh := make(chan error, 2)
 
@@ -111,10 +135,8 @@ func (g *Genkit) Start(ctx context.Context, opts *StartOptions) error {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			s := startReflectionServer(ctx, g.reg, errCh)
-			mu.Lock()
-			servers = append(servers, s)
-			mu.Unlock()
+			s := startReflectionServer(ctx, g.reg, opts.Playground, errCh)
+			g.addServer(s)
 		}()
 	}
 
@@ -123,9 +145,7 @@ func (g *Genkit) Start(ctx context.Context, opts *StartOptions) error {
 		go func() {
 			defer wg.Done()
 			s := startFlowServer(g, opts.FlowAddr, opts.Flows, errCh)
-			mu.Lock()
-			servers = append(servers, s)
-			mu.Unlock()
+			g.addServer(s)
 		}()
 	}
 
@@ -157,9 +177,18 @@ func (g *Genkit) Start(ctx context.Context, opts *StartOptions) error {
 		return err
 	case <-ctx.Done():
 		slog.Info("context cancelled, initiating shutdown")
+	case <-g.stopping:
+		<-g.shutdownDone
+		return g.shutdownErr
 	}
 
-	return shutdownServers(servers)
+	timeout := opts.ShutdownTimeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	sctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+	return g.Shutdown(sctx)
 }
 
 // DefineModel registers the given generate function as an action, and returns a
@@ -189,6 +218,12 @@ func DefineTool[In, Out any](g *Genkit, name, description string, fn func(ctx co
 	return ai.DefineTool(g.reg, name, description, fn)
 }
 
+// DefineCachedTool defines a tool function whose results are cached.
+// See [ai.DefineCachedTool].
+func DefineCachedTool[In, Out any](g *Genkit, name, description string, cfg ai.ToolCacheConfig, fn func(ctx context.Context, input In) (Out, error)) *ai.ToolDef[In, Out] {
+	return ai.DefineCachedTool(g.reg, name, description, cfg, fn)
+}
+
 // LookupTool looks up the tool in the registry by provided name and returns it.
 func LookupTool(g *Genkit, name string) ai.Tool {
 	return ai.LookupTool(g.reg, name)
@@ -303,11 +338,51 @@ func LookupEmbedder(g *Genkit, provider, name string) ai.Embedder {
 	return ai.LookupEmbedder(g.reg, provider, name)
 }
 
+// DefineTranscriber registers the given transcribe function as an action, and
+// returns a [Transcriber] that runs it.
+func DefineTranscriber(g *Genkit, provider, name string, transcribe func(context.Context, *ai.TranscribeRequest) (*ai.TranscribeResponse, error)) ai.Transcriber {
+	return ai.DefineTranscriber(g.reg, provider, name, transcribe)
+}
+
+// IsDefinedTranscriber reports whether a transcriber is defined.
+func IsDefinedTranscriber(g *Genkit, provider, name string) bool {
+	return ai.IsDefinedTranscriber(g.reg, provider, name)
+}
+
+// LookupTranscriber looks up a [Transcriber] registered by [DefineTranscriber].
+// It returns nil if the transcriber was not defined.
+func LookupTranscriber(g *Genkit, provider, name string) ai.Transcriber {
+	return ai.LookupTranscriber(g.reg, provider, name)
+}
+
+// DefineSpeechSynthesizer registers the given synthesize function as an action, and
+// returns a [SpeechSynthesizer] that runs it.
+func DefineSpeechSynthesizer(g *Genkit, provider, name string, synthesize func(context.Context, *ai.SynthesizeSpeechRequest) (*ai.SynthesizeSpeechResponse, error)) ai.SpeechSynthesizer {
+	return ai.DefineSpeechSynthesizer(g.reg, provider, name, synthesize)
+}
+
+// IsDefinedSpeechSynthesizer reports whether a speech synthesizer is defined.
+func IsDefinedSpeechSynthesizer(g *Genkit, provider, name string) bool {
+	return ai.IsDefinedSpeechSynthesizer(g.reg, provider, name)
+}
+
+// LookupSpeechSynthesizer looks up a [SpeechSynthesizer] registered by [DefineSpeechSynthesizer].
+// It returns nil if the speech synthesizer was not defined.
+func LookupSpeechSynthesizer(g *Genkit, provider, name string) ai.SpeechSynthesizer {
+	return ai.LookupSpeechSynthesizer(g.reg, provider, name)
+}
+
 // RegisterSpanProcessor registers an OpenTelemetry SpanProcessor for tracing.
 func RegisterSpanProcessor(g *Genkit, sp sdktrace.SpanProcessor) {
 	g.reg.RegisterSpanProcessor(sp)
 }
 
+// RegisterSpanObserver registers a function to be called at the end of every span,
+// whether or not the span is sampled. See [tracing.SpanObserver].
+func RegisterSpanObserver(g *Genkit, o tracing.SpanObserver) {
+	g.reg.RegisterSpanObserver(o)
+}
+
 // optsWithDefaults prepends defaults to the options so that they can be overridden by the caller.
 func optsWithDefaults(g *Genkit, opts []ai.GenerateOption) ([]ai.GenerateOption, error) {
 	if g.Opts.DefaultModel != "" {
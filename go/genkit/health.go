@@ -0,0 +1,116 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genkit
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/firebase/genkit/go/internal/registry"
+)
+
+// A HealthChecker reports whether something the program depends on,
+// such as a model server or a vector database, is usable.
+//
+// Plugins register health checkers with [RegisterHealthChecker] so that
+// misconfigurations are found when the program is deployed, not on the
+// first user request. A check should be quick and have no side effects:
+// ping a server, or verify that a model or index exists.
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// HealthCheckerFunc adapts a function to a [HealthChecker].
+type HealthCheckerFunc func(ctx context.Context) error
+
+// CheckHealth calls f(ctx).
+func (f HealthCheckerFunc) CheckHealth(ctx context.Context) error { return f(ctx) }
+
+// RegisterHealthChecker registers hc under the given name, which is
+// conventionally "provider" or "provider/resource".
+// It panics if a checker with the same name is already registered.
+func RegisterHealthChecker(g *Genkit, name string, hc HealthChecker) {
+	g.reg.RegisterHealthCheck(name, hc.CheckHealth)
+}
+
+// HealthCheckTimeout is the time allowed for each health check.
+const HealthCheckTimeout = 10 * time.Second
+
+// A HealthReport holds the results of health checks.
+type HealthReport struct {
+	// Ready is true if all checks passed.
+	Ready bool `json:"ready"`
+	// Checks holds the result of each check, by name.
+	Checks map[string]*HealthCheckResult `json:"checks"`
+}
+
+// A HealthCheckResult is the result of a single health check.
+type HealthCheckResult struct {
+	OK       bool          `json:"ok"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"durationNanos"`
+}
+
+// CheckHealth runs all registered health checks concurrently and reports
+// their results. Each check is given [HealthCheckTimeout] to complete.
+func CheckHealth(ctx context.Context, g *Genkit) *HealthReport {
+	return checkHealth(ctx, g.reg)
+}
+
+func checkHealth(ctx context.Context, r *registry.Registry) *HealthReport {
+	checks := r.HealthChecks()
+	rep := &HealthReport{Ready: true, Checks: map[string]*HealthCheckResult{}}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+			defer cancel()
+			start := time.Now()
+			err := check(ctx)
+			res := &HealthCheckResult{OK: err == nil, Duration: time.Since(start)}
+			if err != nil {
+				res.Error = err.Error()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			rep.Checks[name] = res
+			if err != nil {
+				rep.Ready = false
+			}
+		}()
+	}
+	wg.Wait()
+	return rep
+}
+
+// handleReadyz runs the health checks and writes the report, with status
+// 200 if all passed and 503 otherwise.
+func handleReadyz(r *registry.Registry) func(http.ResponseWriter, *http.Request) error {
+	return func(w http.ResponseWriter, req *http.Request) error {
+		rep := checkHealth(req.Context(), r)
+		w.Header().Set("Content-Type", "application/json")
+		if !rep.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		return writeJSON(req.Context(), w, rep)
+	}
+}
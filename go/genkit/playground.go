@@ -0,0 +1,148 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genkit
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"io/fs"
+	"net/http"
+	"sync"
+
+	"github.com/firebase/genkit/go/core/tracing"
+	"github.com/firebase/genkit/go/internal/base"
+)
+
+// The playground is a single page, built only on the reflection API
+// and the trace routes below, so it needs no build step.
+//
+//go:embed playground
+var playgroundFS embed.FS
+
+// maxPlaygroundTraces is the number of traces the playground remembers.
+const maxPlaygroundTraces = 100
+
+// handlePlayground adds the playground routes to mux.
+func (s *devServer) handlePlayground(mux *http.ServeMux) {
+	sub, err := fs.Sub(playgroundFS, "playground")
+	if err != nil {
+		// The embedded directory always exists.
+		panic(err)
+	}
+	mux.Handle("GET /playground/", http.StripPrefix("/playground/", http.FileServerFS(sub)))
+	handle(mux, "GET /playground/api/traces", func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "application/json")
+		return writeJSON(r.Context(), w, s.traces.list())
+	})
+	handle(mux, "GET /playground/api/traces/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		td := s.traces.get(r.PathValue("id"))
+		if td == nil {
+			return &base.HTTPError{Code: http.StatusNotFound, Err: errors.New("trace not found")}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		return writeJSON(r.Context(), w, td)
+	})
+}
+
+// A traceStore is a tracing.TelemetryClient that keeps the most recent
+// traces in memory for the playground.
+// Spans are saved as they end, so a trace is assembled over several calls
+// to Save.
+type traceStore struct {
+	mu     sync.Mutex
+	max    int
+	order  []string // trace IDs, oldest first
+	traces map[string]*tracing.Data
+}
+
+func newTraceStore(max int) *traceStore {
+	return &traceStore{max: max, traces: map[string]*tracing.Data{}}
+}
+
+// Save implements tracing.TelemetryClient.
+func (s *traceStore) Save(_ context.Context, td *tracing.Data) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.traces[td.TraceID]
+	if !ok {
+		existing = &tracing.Data{TraceID: td.TraceID, Spans: map[string]*tracing.SpanData{}}
+		s.traces[td.TraceID] = existing
+		s.order = append(s.order, td.TraceID)
+		if len(s.order) > s.max {
+			delete(s.traces, s.order[0])
+			s.order = s.order[1:]
+		}
+	}
+	for id, sd := range td.Spans {
+		existing.Spans[id] = sd
+	}
+	// The root span ends last and carries the trace's name and times.
+	if td.DisplayName != "" {
+		existing.DisplayName = td.DisplayName
+	}
+	if td.StartTime != 0 {
+		existing.StartTime = td.StartTime
+	}
+	if td.EndTime != 0 {
+		existing.EndTime = td.EndTime
+	}
+	return nil
+}
+
+// traceSummary describes a trace in the playground's trace list.
+type traceSummary struct {
+	TraceID     string               `json:"traceId"`
+	DisplayName string               `json:"displayName"`
+	StartTime   tracing.Milliseconds `json:"startTime"`
+	EndTime     tracing.Milliseconds `json:"endTime"`
+	SpanCount   int                  `json:"spanCount"`
+}
+
+// list returns summaries of the stored traces, newest first.
+func (s *traceStore) list() []traceSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts := make([]traceSummary, 0, len(s.order))
+	for i := len(s.order) - 1; i >= 0; i-- {
+		id := s.order[i]
+		td := s.traces[id]
+		ts = append(ts, traceSummary{
+			TraceID:     id,
+			DisplayName: td.DisplayName,
+			StartTime:   td.StartTime,
+			EndTime:     td.EndTime,
+			SpanCount:   len(td.Spans),
+		})
+	}
+	return ts
+}
+
+// get returns the trace with the given ID, or nil if there is none.
+func (s *traceStore) get(id string) *tracing.Data {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	td, ok := s.traces[id]
+	if !ok {
+		return nil
+	}
+	// Copy so the caller can marshal it while spans are still arriving.
+	c := *td
+	c.Spans = make(map[string]*tracing.SpanData, len(td.Spans))
+	for k, v := range td.Spans {
+		c.Spans[k] = v
+	}
+	return &c
+}
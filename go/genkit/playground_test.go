@@ -0,0 +1,131 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genkit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/firebase/genkit/go/core"
+	"github.com/firebase/genkit/go/core/tracing"
+	"github.com/firebase/genkit/go/internal/atype"
+	"github.com/firebase/genkit/go/internal/registry"
+)
+
+func TestPlayground(t *testing.T) {
+	r, err := registry.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	traces := newTraceStore(maxPlaygroundTraces)
+	r.TracingState().WriteTelemetryImmediate(traces)
+	core.DefineAction(r, "devServer", "inc", atype.Custom, nil, inc)
+	srv := httptest.NewServer(newDevServeMux(&devServer{reg: r, traces: traces}))
+	defer srv.Close()
+
+	t.Run("page", func(t *testing.T) {
+		res, err := http.Get(srv.URL + "/playground/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != 200 {
+			t.Fatalf("got status %d, wanted 200", res.StatusCode)
+		}
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(body), "/api/runAction") {
+			t.Error("playground page does not use the reflection API")
+		}
+	})
+	t.Run("traces", func(t *testing.T) {
+		res, err := http.Post(srv.URL+"/api/runAction", "application/json",
+			strings.NewReader(`{"key": "/custom/devServer/inc", "input": 3}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		run, err := readJSON[runActionResponse](res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tid := run.Telemetry.TraceID
+
+		res, err = http.Get(srv.URL + "/playground/api/traces")
+		if err != nil {
+			t.Fatal(err)
+		}
+		list, err := readJSON[[]traceSummary](res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 || list[0].TraceID != tid {
+			t.Fatalf("got traces %+v, want one trace %q", list, tid)
+		}
+
+		res, err = http.Get(srv.URL + "/playground/api/traces/" + tid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		td, err := readJSON[tracing.Data](res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found bool
+		for _, sd := range td.Spans {
+			found = found || sd.DisplayName == "devServer/inc"
+		}
+		if !found {
+			t.Errorf("trace %+v has no span for the action", td)
+		}
+
+		res, err = http.Get(srv.URL + "/playground/api/traces/unknown")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("got status %d for an unknown trace, want 404", res.StatusCode)
+		}
+	})
+}
+
+func TestTraceStoreEvictsOldest(t *testing.T) {
+	s := newTraceStore(2)
+	ctx := context.Background()
+	for _, id := range []string{"a", "b", "a", "c"} {
+		if err := s.Save(ctx, &tracing.Data{TraceID: id, Spans: map[string]*tracing.SpanData{id: {SpanID: id}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	for _, ts := range s.list() {
+		got = append(got, ts.TraceID)
+	}
+	if want := "c,b"; strings.Join(got, ",") != want {
+		t.Errorf("got traces %v, want %s", got, want)
+	}
+	if s.get("a") != nil {
+		t.Error("oldest trace was not evicted")
+	}
+}
@@ -58,15 +58,22 @@ type runtimeFileData struct {
 type devServer struct {
 	reg             *registry.Registry
 	runtimeFilePath string
+	traces          *traceStore // local traces for the playground; nil if it is not served
 }
 
 // startReflectionServer starts the Reflection API server listening at the
 // value of the environment variable GENKIT_REFLECTION_PORT for the port,
-// or ":3100" if it is empty.
-func startReflectionServer(ctx context.Context, r *registry.Registry, errCh chan<- error) *http.Server {
+// or ":3100" if it is empty. If playground is true, the server also
+// serves the playground.
+func startReflectionServer(ctx context.Context, r *registry.Registry, playground bool, errCh chan<- error) *http.Server {
 	slog.Debug("starting reflection server")
 	addr := serverAddress("", "GENKIT_REFLECTION_PORT", "127.0.0.1:3100")
 	s := &devServer{reg: r}
+	if playground {
+		s.traces = newTraceStore(maxPlaygroundTraces)
+		r.TracingState().WriteTelemetryImmediate(s.traces)
+		slog.Info("playground available", "url", "http://"+addr+"/playground/")
+	}
 	if err := s.writeRuntimeFile(addr); err != nil {
 		slog.Error("failed to write runtime file", "error", err)
 	}
@@ -196,12 +203,9 @@ func startServer(addr string, handler http.Handler, errCh chan<- error) *http.Se
 	return server
 }
 
-// shutdownServers initiates shutdown of the servers and waits for the shutdown to complete.
-// After 5 seconds, it will timeout.
-func shutdownServers(servers []*http.Server) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
+// shutdownServers initiates shutdown of the servers and waits for the shutdown to complete,
+// or for ctx to be done.
+func shutdownServers(ctx context.Context, servers []*http.Server) error {
 	var wg sync.WaitGroup
 	for _, server := range servers {
 		wg.Add(1)
@@ -233,12 +237,22 @@ func shutdownServers(servers []*http.Server) error {
 
 func newDevServeMux(s *devServer) *http.ServeMux {
 	mux := http.NewServeMux()
-	handle(mux, "GET /api/__health", func(w http.ResponseWriter, _ *http.Request) error {
-		return nil
+	handle(mux, "GET /api/__health", func(w http.ResponseWriter, r *http.Request) error {
+		// The server itself is healthy; with ?details=true, also report
+		// the results of the registered health checks.
+		details, err := parseBoolQueryParam(r, "details")
+		if err != nil || !details {
+			return err
+		}
+		w.Header().Set("Content-Type", "application/json")
+		return writeJSON(r.Context(), w, checkHealth(r.Context(), s.reg))
 	})
 	handle(mux, "POST /api/runAction", s.handleRunAction)
 	handle(mux, "GET /api/actions", s.handleListActions)
 	handle(mux, "POST /api/notify", s.handleNotify)
+	if s.traces != nil {
+		s.handlePlayground(mux)
+	}
 	return mux
 }
 
@@ -358,8 +372,14 @@ func (s *devServer) handleListActions(w http.ResponseWriter, r *http.Request) er
 // If flows is non-empty, the each of the named flows is registered as a route.
 // Otherwise, all defined flows are registered.
 //
-// All routes take a single query parameter, "stream", which if true will stream the
+// All routes take a query parameter, "stream", which if true will stream the
 // flow's results back to the client. (Not all flows support streaming, however.)
+// When streaming, the query parameter "progress", if true, adds progress events
+// (see [WithProgress]) to the stream, labeled "progress" rather than "message".
+//
+// The ServeMux also has a "GET /readyz" route that runs the registered
+// health checks (see [HealthChecker]). It responds with status 200 if they all
+// pass and 503 otherwise, and a JSON [HealthReport].
 //
 // To use the returned ServeMux as part of a server with other routes, either add routes
 // to it, or install it as part of another ServeMux, like so:
@@ -372,6 +392,7 @@ func NewFlowServeMux(g *Genkit, flows []string) *http.ServeMux {
 
 func newFlowServeMux(r *registry.Registry, flows []string) *http.ServeMux {
 	mux := http.NewServeMux()
+	handle(mux, "GET /readyz", handleReadyz(r))
 	m := map[string]bool{}
 	for _, f := range flows {
 		m[f] = true
@@ -398,14 +419,28 @@ func nonDurableFlowHandler(f flow) func(http.ResponseWriter, *http.Request) erro
 		if err != nil {
 			return err
 		}
+		progress, err := parseBoolQueryParam(r, "progress")
+		if err != nil {
+			return err
+		}
+		ctx := r.Context()
+		// Progress events may be written from other goroutines,
+		// even after the flow returns.
+		var (
+			mu     sync.Mutex
+			closed bool
+		)
 		var callback streamingCallback[json.RawMessage]
 		if r.Header.Get("Accept") == "text/event-stream" || stream {
 			w.Header().Set("Content-Type", "text/plain")
 			w.Header().Set("Transfer-Encoding", "chunked")
-			// Event Stream results are in JSON format separated by two newline escape sequences
-			// including the `data` and `message` labels
-			callback = func(ctx context.Context, msg json.RawMessage) error {
-				_, err := fmt.Fprintf(w, "data: {\"message\": %s}\n\n", msg)
+			write := func(key string, msg json.RawMessage) error {
+				mu.Lock()
+				defer mu.Unlock()
+				if closed {
+					return errors.New("stream closed")
+				}
+				_, err := fmt.Fprintf(w, "data: {%q: %s}\n\n", key, msg)
 				if err != nil {
 					return err
 				}
@@ -414,9 +449,25 @@ func nonDurableFlowHandler(f flow) func(http.ResponseWriter, *http.Request) erro
 				}
 				return nil
 			}
+			// Event Stream results are in JSON format separated by two newline escape sequences
+			// including the `data` and `message` labels
+			callback = func(ctx context.Context, msg json.RawMessage) error {
+				return write("message", msg)
+			}
+			// Progress events have the `progress` label.
+			if progress {
+				ctx = core.WithProgressReporter(ctx, func(ctx context.Context, ev *core.ProgressEvent) {
+					if b, err := json.Marshal(ev); err == nil {
+						write("progress", b)
+					}
+				})
+			}
 		}
 		// TODO: telemetry
-		out, err := f.runJSON(r.Context(), r.Header.Get("Authorization"), body.Data, callback)
+		out, err := f.runJSON(ctx, r.Header.Get("Authorization"), body.Data, callback)
+		mu.Lock()
+		defer mu.Unlock()
+		closed = true
 		if err != nil {
 			if r.Header.Get("Accept") == "text/event-stream" || stream {
 				_, err = fmt.Fprintf(w, "data: {\"error\": {\"status\": \"INTERNAL\", \"message\": \"stream flow error\", \"details\": \"%v\"}}\n\n", err)
@@ -18,9 +18,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
 
@@ -129,6 +131,18 @@ func TestProdServer(t *testing.T) {
 	defineFlow(r, "inc", func(_ context.Context, i int, _ noStream) (int, error) {
 		return i + 1, nil
 	})
+	defineFlow(r, "incStep", func(ctx context.Context, i int, cb func(context.Context, int) error) (int, error) {
+		n, err := Run(ctx, "inc", func() (int, error) { return i + 1, nil })
+		if err != nil {
+			return 0, err
+		}
+		if cb != nil {
+			if err := cb(ctx, n); err != nil {
+				return 0, err
+			}
+		}
+		return n, nil
+	})
 	srv := httptest.NewServer(newFlowServeMux(r, nil))
 	defer srv.Close()
 
@@ -168,6 +182,88 @@ func TestProdServer(t *testing.T) {
 
 	t.Run("ok", func(t *testing.T) { check(t, "2", 200, 3) })
 	t.Run("bad", func(t *testing.T) { check(t, "true", 400, 0) })
+	t.Run("progress", func(t *testing.T) {
+		res, err := http.Post(srv.URL+"/incStep?stream=true&progress=true", "application/json", strings.NewReader(`{"data": 2}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		for _, event := range strings.Split(strings.TrimSpace(string(body)), "\n\n") {
+			var data map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(event, "data: ")), &data); err != nil {
+				t.Fatalf("%q: %v", event, err)
+			}
+			for k := range data {
+				keys = append(keys, k)
+			}
+		}
+		if want := []string{"progress", "progress", "message", "result"}; !slices.Equal(keys, want) {
+			t.Errorf("got events %v, want %v\n%s", keys, want, body)
+		}
+	})
+}
+
+func TestHealth(t *testing.T) {
+	r, err := registry.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &Genkit{reg: r}
+	var dbErr error
+	RegisterHealthChecker(g, "model", HealthCheckerFunc(func(context.Context) error { return nil }))
+	RegisterHealthChecker(g, "db", HealthCheckerFunc(func(context.Context) error { return dbErr }))
+
+	prod := httptest.NewServer(newFlowServeMux(r, nil))
+	defer prod.Close()
+	dev := httptest.NewServer(newDevServeMux(&devServer{reg: r}))
+	defer dev.Close()
+
+	get := func(t *testing.T, url string, wantStatus int) *HealthReport {
+		t.Helper()
+		res, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != wantStatus {
+			t.Fatalf("got status %d, want %d", res.StatusCode, wantStatus)
+		}
+		rep, err := readJSON[HealthReport](res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &rep
+	}
+
+	rep := get(t, prod.URL+"/readyz", 200)
+	if !rep.Ready || len(rep.Checks) != 2 || !rep.Checks["db"].OK {
+		t.Errorf("got %+v, want all ready", rep)
+	}
+
+	dbErr = errors.New("connection refused")
+	rep = get(t, prod.URL+"/readyz", 503)
+	if rep.Ready || !rep.Checks["model"].OK || rep.Checks["db"].Error != "connection refused" {
+		t.Errorf("got %+v, want db failure", rep)
+	}
+
+	// The reflection server is itself healthy, and reports details on request.
+	rep = get(t, dev.URL+"/api/__health?details=true", 200)
+	if rep.Ready || rep.Checks["db"].OK {
+		t.Errorf("got %+v, want db failure", rep)
+	}
+	res, err := http.Get(dev.URL + "/api/__health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Errorf("got status %d, want 200", res.StatusCode)
+	}
 }
 
 func checkActionTrace(t *testing.T, tc *tracing.TestOnlyTelemetryClient, tid, name string) {
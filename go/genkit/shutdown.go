@@ -0,0 +1,112 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/firebase/genkit/go/internal/registry"
+)
+
+// ErrShuttingDown is returned when a flow is run after [Genkit.Shutdown]
+// has been called.
+var ErrShuttingDown = registry.ErrShuttingDown
+
+type shutdownHook struct {
+	name string
+	f    func(context.Context) error
+}
+
+// RegisterShutdownHook registers a function to be called by [Genkit.Shutdown]
+// to release resources, such as clients, exporters or open files.
+// Hooks are called in the reverse order of their registration, after
+// running flows have finished and telemetry has been flushed, so a
+// hook can rely on anything registered before it still being open.
+// The name identifies the hook in errors.
+//
+// Plugins typically register hooks in their Init functions.
+func RegisterShutdownHook(g *Genkit, name string, hook func(context.Context) error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.hooks = append(g.hooks, shutdownHook{name, hook})
+}
+
+// Shutdown stops g gracefully. In order, it:
+//
+//   - stops the servers started by [Genkit.Start] from accepting
+//     requests, and waits for the requests they are serving;
+//   - rejects new flow runs with [ErrShuttingDown], and waits for the
+//     running ones to finish and save their state;
+//   - flushes and shuts down the span processors, such as those
+//     that export traces to telemetry backends;
+//   - calls the hooks registered with [RegisterShutdownHook].
+//
+// Each step is given until ctx is done. A failing step does not stop
+// the later ones; all errors are returned together.
+//
+// Only the first call does any work. Later calls wait for it and
+// return its result.
+func (g *Genkit) Shutdown(ctx context.Context) error {
+	g.shutdownOnce.Do(func() {
+		close(g.stopping)
+		g.shutdownErr = g.shutdown(ctx)
+		close(g.shutdownDone)
+	})
+	<-g.shutdownDone
+	return g.shutdownErr
+}
+
+func (g *Genkit) shutdown(ctx context.Context) error {
+	var errs []error
+	g.mu.Lock()
+	servers := g.servers
+	hooks := g.hooks
+	g.mu.Unlock()
+
+	if err := shutdownServers(ctx, servers); err != nil {
+		errs = append(errs, err)
+	}
+	if err := g.reg.Drain(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("waiting for running flows: %w", err))
+	}
+	if err := g.reg.TracingState().Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("flushing traces: %w", err))
+	}
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		if err := h.f(ctx); err != nil {
+			slog.Error("shutdown hook failed", "name", h.name, "err", err)
+			errs = append(errs, fmt.Errorf("shutdown hook %q: %w", h.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// addServer records a server started by Start so that Shutdown can stop it.
+// If Shutdown has already been called, the server is closed at once.
+func (g *Genkit) addServer(s *http.Server) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	select {
+	case <-g.stopping:
+		s.Close()
+	default:
+		g.servers = append(g.servers, s)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genkit
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	g, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	f := DefineFlow(g, "wait", func(ctx context.Context, n int) (int, error) {
+		close(started)
+		<-release
+		return n, nil
+	})
+	var mu sync.Mutex
+	var calls []string
+	hook := func(name string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, name)
+			return err
+		}
+	}
+	errHook := errors.New("hook failed")
+	RegisterShutdownHook(g, "a", hook("a", nil))
+	RegisterShutdownHook(g, "b", hook("b", errHook))
+	RegisterShutdownHook(g, "c", hook("c", nil))
+
+	ctx := context.Background()
+	runErr := make(chan error, 1)
+	go func() {
+		_, err := f.Run(ctx, 1)
+		runErr <- err
+	}()
+	<-started
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- g.Shutdown(ctx) }()
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown returned %v before the running flow finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, err := f.Run(ctx, 2); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("Run during shutdown: got %v, want ErrShuttingDown", err)
+	}
+	mu.Lock()
+	if len(calls) != 0 {
+		t.Errorf("hooks %v called before the running flow finished", calls)
+	}
+	mu.Unlock()
+
+	close(release)
+	if err := <-runErr; err != nil {
+		t.Errorf("running flow: %v", err)
+	}
+	err = <-shutdownErr
+	if !errors.Is(err, errHook) {
+		t.Errorf("Shutdown: got %v, want the hook error", err)
+	}
+	if want := []string{"c", "b", "a"}; !slices.Equal(calls, want) {
+		t.Errorf("hooks called in order %v, want %v", calls, want)
+	}
+	// Later calls return the first result without running the hooks again.
+	if err2 := g.Shutdown(ctx); err2 != err {
+		t.Errorf("second Shutdown: got %v, want %v", err2, err)
+	}
+	if len(calls) != 3 {
+		t.Errorf("hooks called %d times, want 3", len(calls))
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	g, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	f := DefineFlow(g, "wait", func(ctx context.Context, n int) (int, error) {
+		close(started)
+		<-release
+		return n, nil
+	})
+	hookCalled := false
+	RegisterShutdownHook(g, "h", func(context.Context) error {
+		hookCalled = true
+		return nil
+	})
+	go f.Run(context.Background(), 1)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := g.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+	if !hookCalled {
+		t.Error("hook not called after the drain timed out")
+	}
+}
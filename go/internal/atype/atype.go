@@ -30,4 +30,7 @@ const (
 	Prompt    ActionType = "prompt"
 	Tool      ActionType = "tool"
 	Custom    ActionType = "custom"
+
+	Transcriber       ActionType = "transcriber"
+	SpeechSynthesizer ActionType = "speech-synthesizer"
 )
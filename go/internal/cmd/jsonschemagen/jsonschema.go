@@ -31,12 +31,13 @@ type Schema struct {
 	Description          string                   `json:"description,omitempty"`
 	Properties           map[string]*Schema       `json:"properties,omitempty"`
 	AdditionalProperties *Schema                  `json:"additionalProperties,omitempty"`
-	Const                bool                     `json:"const,omitempty"`
+	Const                any                      `json:"const,omitempty"`
 	Required             []string                 `json:"required,omitempty"`
 	Items                *Schema                  `json:"items,omitempty"`
 	Enum                 []string                 `json:"enum,omitempty"`
 	Not                  any                      `json:"not,omitempty"`
 	AnyOf                []*Schema                `json:"anyOf,omitempty"`
+	OneOf                []*Schema                `json:"oneOf,omitempty"`
 	AllOf                []*Schema                `json:"allOf,omitempty"`
 	Default              any                      `json:"default,omitempty"`
 	Ref                  string                   `json:"$ref,omitempty"`
@@ -62,8 +63,9 @@ func (s *Schema) UnmarshalJSON(data []byte) error {
 		s.Not = &Schema{}
 		return nil
 	}
-	type nomethod *Schema
-	return json.Unmarshal(data, nomethod(s))
+	// Unmarshal into a type without this method, to avoid recursion.
+	type nomethod Schema
+	return json.Unmarshal(data, (*nomethod)(s))
 }
 
 var fields = reflect.VisibleFields(reflect.TypeOf(Schema{}))
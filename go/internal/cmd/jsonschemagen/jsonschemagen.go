@@ -125,12 +125,17 @@ func run(infile, defaultPkgPath, configFile, outRoot string) error {
 		gen := &generator{
 			pkgName: path.Base(pkgPath),
 			schemas: schemaMap,
+			all:     schemas,
 			cfg:     cfg,
 		}
 		src, err := gen.generate()
 		if err != nil {
 			return err
 		}
+		if src == nil {
+			// Every type in the package was omitted.
+			continue
+		}
 
 		// Format and write the source.
 		if !*noFormat {
@@ -250,23 +255,22 @@ const license = `
 
 type generator struct {
 	pkgName string
-	schemas map[string]*Schema
+	schemas map[string]*Schema // the schemas of this package
+	all     map[string]*Schema // the schemas of all packages
 	cfg     config
+	imports map[string]bool // paths of packages the generated code uses
 	pr      func(string, ...any)
 }
 
 // generate produces Go source for the types in schemas.
+// It returns nil if there are no types to generate.
 func (g *generator) generate() ([]byte, error) {
-	var buf bytes.Buffer
-
-	g.pr = func(format string, args ...any) { fmt.Fprintf(&buf, format, args...) }
-
-	g.pr("%s\n\n", license)
-	g.pr("// This file was generated by jsonschemagen. DO NOT EDIT.\n\n")
-	g.pr("package %s\n\n", g.pkgName)
-
+	// Generate the types first, to learn what they import.
+	var body bytes.Buffer
+	g.pr = func(format string, args ...any) { fmt.Fprintf(&body, format, args...) }
+	g.imports = map[string]bool{}
 	if pc := g.cfg.configFor(g.pkgName); pc != nil {
-		g.pr("import %q\n", pc.pkgPath)
+		g.imports[pc.pkgPath] = true
 	}
 
 	// Sort the names so the output is deterministic.
@@ -278,6 +282,26 @@ func (g *generator) generate() ([]byte, error) {
 			return nil, err
 		}
 	}
+	if body.Len() == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\n\n", license)
+	fmt.Fprintf(&buf, "// This file was generated by jsonschemagen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", g.pkgName)
+	switch imports := sortedKeys(g.imports); len(imports) {
+	case 0:
+	case 1:
+		fmt.Fprintf(&buf, "import %q\n", imports[0])
+	default:
+		fmt.Fprintf(&buf, "import (\n")
+		for _, imp := range imports {
+			fmt.Fprintf(&buf, "%q\n", imp)
+		}
+		fmt.Fprintf(&buf, ")\n")
+	}
+	buf.Write(body.Bytes())
 	return buf.Bytes(), nil
 }
 
@@ -299,8 +323,10 @@ func (g *generator) generateType(name string) (err error) {
 			return nil
 		}
 		if s.AnyOf != nil {
-			log.Printf("WARNING: %s: cannot handle anyOf", name)
-			return nil
+			return g.generateUnion(name, s, s.AnyOf, tcfg)
+		}
+		if s.OneOf != nil {
+			return g.generateUnion(name, s, s.OneOf, tcfg)
 		}
 		return errors.New("no type")
 	}
@@ -342,10 +368,10 @@ func (g *generator) generateStruct(name string, s *Schema, tcfg *itemConfig) err
 			continue
 		}
 		fs := s.Properties[field]
-		// Ignore properties with a non-empty "not" constraint.
+		// Ignore properties with a non-empty "not" constraint, even by reference.
 		// They are probably the result of inheriting from a base zod type with a "never" constraint.
 		// E.g. see EmptyPartSchema and its subtypes in js/ai/src/model.ts.
-		if fs.Not != nil {
+		if rs, err := g.resolve(fs); err == nil && rs.Not != nil {
 			continue
 		}
 		if fcfg.omit {
@@ -413,6 +439,18 @@ func (g *generator) typeExpr(s *Schema) (string, error) {
 			return "", fmt.Errorf("ref %q does not begin with prefix %q", s.Ref, refPrefix)
 		}
 		ic := g.cfg.configFor(name)
+		if strings.Contains(name, "/") {
+			// A reference to part of a definition, like "DataPart/properties/metadata".
+			// Use the type of that part, unless the config names one.
+			if ic != nil && ic.name != "" {
+				return ic.name, nil
+			}
+			rs, err := g.resolve(s)
+			if err != nil {
+				return "", err
+			}
+			return g.typeExpr(rs)
+		}
 		s2, ok := g.schemas[name]
 		if !ok {
 			// If there is no schema, perhaps there is a config value.
@@ -481,6 +519,221 @@ func (g *generator) typeExpr(s *Schema) (string, error) {
 	}
 }
 
+// resolve returns the schema that s refers to, if s is a reference
+// to part of a definition, like "#/$defs/DataPart/properties/text".
+// Otherwise it returns s.
+func (g *generator) resolve(s *Schema) (*Schema, error) {
+	name, ok := strings.CutPrefix(s.Ref, refPrefix)
+	if !ok {
+		return s, nil
+	}
+	def, rest, ok := strings.Cut(name, "/")
+	if !ok {
+		return s, nil
+	}
+	rs := g.all[def]
+	if rs == nil {
+		return nil, fmt.Errorf("unknown type in reference: %q", name)
+	}
+	for rest != "" {
+		var kind, key string
+		kind, rest, _ = strings.Cut(rest, "/")
+		key, rest, _ = strings.Cut(rest, "/")
+		if kind != "properties" || rs.Properties[key] == nil {
+			return nil, fmt.Errorf("cannot resolve reference %q", s.Ref)
+		}
+		rs = g.deref(rs.Properties[key])
+	}
+	// The part may itself be a reference.
+	return g.resolve(rs)
+}
+
+// deref returns the definition that s refers to, if s is a reference
+// to a whole definition, like "#/$defs/Media". Otherwise it returns s.
+func (g *generator) deref(s *Schema) *Schema {
+	if name, ok := strings.CutPrefix(s.Ref, refPrefix); ok && g.all[name] != nil {
+		return g.all[name]
+	}
+	return s
+}
+
+// A variant is one of the types of a union.
+type variant struct {
+	goName string  // name of the Go type
+	field  string  // name of the union field holding the type
+	schema *Schema // schema of the type
+	value  string  // value of the discriminator property, or a property only this variant requires
+}
+
+// generateUnion generates a struct for a schema that is one of the schemas in alts,
+// each of which must refer to an object type. The struct has a pointer field for each
+// type, exactly one of which is set, and marshals as that field.
+//
+// When unmarshaling, the type is chosen by the value of a discriminator property,
+// if there is one. The discriminator is the property set by the config's "discriminator"
+// directive, or else a property that has a single enum or const value in every type.
+// Without a discriminator, the type is the one whose required properties include one
+// that no other type requires and that is present. At most one type may have no
+// such property; it is used when no other type matches.
+func (g *generator) generateUnion(name string, s *Schema, alts []*Schema, tcfg *itemConfig) error {
+	var variants []*variant
+	for _, alt := range alts {
+		vname, ok := strings.CutPrefix(alt.Ref, refPrefix)
+		if !ok || strings.Contains(vname, "/") {
+			return fmt.Errorf("union member %v is not a reference to a definition", alt)
+		}
+		vs := g.schemas[vname]
+		if vs == nil || vs.Type.Any() != "object" {
+			return fmt.Errorf("union member %q is not an object type in package %s", vname, g.pkgName)
+		}
+		goName := adjustIdentifier(vname)
+		if ic := g.cfg.configFor(vname); ic != nil && ic.name != "" {
+			goName = ic.name
+		}
+		variants = append(variants, &variant{goName: goName, field: adjustIdentifier(goName), schema: vs})
+	}
+
+	disc := tcfg.discriminator
+	if disc == "" {
+		disc = g.findDiscriminator(variants)
+	}
+	var deflt *variant // the variant used when no other matches
+	if disc != "" {
+		for _, v := range variants {
+			val, ok := g.singleValue(v.schema.Properties[disc])
+			if !ok {
+				return fmt.Errorf("union member %s: discriminator %q does not have a single string value", v.goName, disc)
+			}
+			v.value = val
+		}
+	} else {
+		for _, v := range variants {
+			for _, req := range v.schema.Required {
+				if !slices.ContainsFunc(variants, func(o *variant) bool {
+					return o != v && slices.Contains(o.schema.Required, req)
+				}) {
+					v.value = req
+					break
+				}
+			}
+			if v.value == "" {
+				if deflt != nil {
+					return fmt.Errorf("cannot distinguish union members %s and %s", deflt.goName, v.goName)
+				}
+				deflt = v
+			}
+		}
+	}
+
+	goName := tcfg.name
+	if goName == "" {
+		goName = adjustIdentifier(name)
+	}
+	g.imports["encoding/json"] = true
+	g.imports["errors"] = true
+	if disc != "" || deflt == nil {
+		g.imports["fmt"] = true // for the error on unknown types
+	}
+
+	g.generateDoc(s, tcfg)
+	g.pr("// Exactly one field of a %s is set.\n", goName)
+	g.pr("type %s struct {\n", goName)
+	for _, v := range variants {
+		g.pr("  %s *%s\n", v.field, v.goName)
+	}
+	g.pr("}\n\n")
+
+	g.pr("// MarshalJSON marshals the field of u that is set.\n")
+	g.pr("func (u *%s) MarshalJSON() ([]byte, error) {\n", goName)
+	g.pr("  switch {\n")
+	for _, v := range variants {
+		g.pr("  case u.%s != nil:\n", v.field)
+		if disc != "" {
+			g.pr("    v := *u.%s\n", v.field)
+			g.pr("    v.%s = %q\n", adjustIdentifier(disc), v.value)
+			g.pr("    return json.Marshal(v)\n")
+		} else {
+			g.pr("    return json.Marshal(u.%s)\n", v.field)
+		}
+	}
+	g.pr("  }\n")
+	g.pr("  return nil, errors.New(\"%s: no field set\")\n", goName)
+	g.pr("}\n\n")
+
+	g.pr("// UnmarshalJSON unmarshals data into the field of u for its type.\n")
+	g.pr("func (u *%s) UnmarshalJSON(data []byte) error {\n", goName)
+	if disc != "" {
+		g.pr("  var d struct {\n")
+		g.pr("    Value string `json:\"%s\"`\n", disc)
+		g.pr("  }\n")
+		g.pr("  if err := json.Unmarshal(data, &d); err != nil {\n")
+		g.pr("    return err\n")
+		g.pr("  }\n")
+		g.pr("  *u = %s{}\n", goName)
+		g.pr("  switch d.Value {\n")
+		for _, v := range variants {
+			g.pr("  case %q:\n", v.value)
+			g.pr("    u.%s = new(%s)\n", v.field, v.goName)
+			g.pr("    return json.Unmarshal(data, u.%s)\n", v.field)
+		}
+		g.pr("  }\n")
+		g.pr("  return fmt.Errorf(\"%s: unknown %s %%q\", d.Value)\n", goName, disc)
+	} else {
+		g.pr("  var fields map[string]json.RawMessage\n")
+		g.pr("  if err := json.Unmarshal(data, &fields); err != nil {\n")
+		g.pr("    return err\n")
+		g.pr("  }\n")
+		g.pr("  *u = %s{}\n", goName)
+		g.pr("  switch {\n")
+		for _, v := range variants {
+			if v == deflt {
+				continue
+			}
+			g.pr("  case fields[%q] != nil:\n", v.value)
+			g.pr("    u.%s = new(%s)\n", v.field, v.goName)
+			g.pr("    return json.Unmarshal(data, u.%s)\n", v.field)
+		}
+		g.pr("  }\n")
+		if deflt != nil {
+			g.pr("  u.%s = new(%s)\n", deflt.field, deflt.goName)
+			g.pr("  return json.Unmarshal(data, u.%s)\n", deflt.field)
+		} else {
+			g.pr("  return fmt.Errorf(\"%s: cannot determine type of %%s\", data)\n", goName)
+		}
+	}
+	g.pr("}\n\n")
+	return nil
+}
+
+// findDiscriminator returns a property that has a single string value
+// in each of the variants, or "" if there is none.
+func (g *generator) findDiscriminator(variants []*variant) string {
+	for _, prop := range sortedKeys(variants[0].schema.Properties) {
+		if !slices.ContainsFunc(variants, func(v *variant) bool {
+			_, ok := g.singleValue(v.schema.Properties[prop])
+			return !ok
+		}) {
+			return prop
+		}
+	}
+	return ""
+}
+
+// singleValue returns the only value s allows, if it is a string.
+func (g *generator) singleValue(s *Schema) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	s = g.deref(s)
+	if c, ok := s.Const.(string); ok {
+		return c, true
+	}
+	if len(s.Enum) == 1 {
+		return s.Enum[0], true
+	}
+	return "", false
+}
+
 // adjustIdentifier returns name with the first letter capitalized
 // so it is exported, and makes other idiomatic Go adjustments.
 func adjustIdentifier(name string) string {
@@ -517,11 +770,12 @@ func (c config) configFor(name string) *itemConfig {
 // itemConfig is configuration for one item: a type, a field or a package.
 // Not all itemConfig fields apply to both, but using one type simplifies the parser.
 type itemConfig struct {
-	omit     bool
-	name     string
-	pkgPath  string
-	typeExpr string
-	docLines []string
+	omit          bool
+	name          string
+	pkgPath       string
+	typeExpr      string
+	discriminator string
+	docLines      []string
 }
 
 // parseConfigFile parses the config file.
@@ -544,6 +798,8 @@ type itemConfig struct {
 //	    package path, relative to outdir (last component is package name)
 //	import
 //	    path of package to import (for packages only)
+//	discriminator PROPERTY
+//	    unmarshal the union type by the value of PROPERTY (for unions only)
 func parseConfigFile(filename string) (config, error) {
 	c := config{
 		itemConfigs: map[string]*itemConfig{},
@@ -609,6 +865,11 @@ func parseConfigFile(filename string) (config, error) {
 				return errf("need NAME import PATH")
 			}
 			ic.pkgPath = words[2]
+		case "discriminator":
+			if len(words) < 3 {
+				return errf("need NAME discriminator PROPERTY")
+			}
+			ic.discriminator = words[2]
 		default:
 			return errf("unknown directive %q", words[1])
 		}
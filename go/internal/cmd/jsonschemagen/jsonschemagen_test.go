@@ -28,30 +28,40 @@ func Test(t *testing.T) {
 	if _, err := exec.LookPath("diff"); err != nil {
 		t.Skip("skipping; no diff program")
 	}
-	const pkgPath = "test"
-	outDir := t.TempDir()
-	err := run(
-		filepath.Join("testdata", "test.json"),
-		pkgPath,
-		filepath.Join("testdata", "test.config"),
-		outDir)
-	if err != nil {
-		t.Fatal(err)
-	}
-	outFile := filepath.Join(outDir, pkgPath, "gen.go")
-	goldenFile := filepath.Join("testdata", "golden")
-	if *update {
-		if err := os.Rename(outFile, goldenFile); err != nil {
-			t.Fatal(err)
-		}
-		t.Log("updated golden")
-	} else {
-		out, err := exec.Command("diff", "-u", goldenFile, outFile).CombinedOutput()
-		if err != nil {
-			t.Fatalf("%v\n%s", err, out)
-		}
-		if len(out) > 0 {
-			t.Errorf("%s", out)
-		}
+	for _, test := range []struct {
+		name   string // base name of the input files
+		golden string
+	}{
+		{"test", "golden"},
+		{"union", "union.golden"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			const pkgPath = "test"
+			outDir := t.TempDir()
+			err := run(
+				filepath.Join("testdata", test.name+".json"),
+				pkgPath,
+				filepath.Join("testdata", test.name+".config"),
+				outDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			outFile := filepath.Join(outDir, pkgPath, "gen.go")
+			goldenFile := filepath.Join("testdata", test.golden)
+			if *update {
+				if err := os.Rename(outFile, goldenFile); err != nil {
+					t.Fatal(err)
+				}
+				t.Log("updated golden")
+			} else {
+				out, err := exec.Command("diff", "-u", goldenFile, outFile).CombinedOutput()
+				if err != nil {
+					t.Fatalf("%v\n%s", err, out)
+				}
+				if len(out) > 0 {
+					t.Errorf("%s", out)
+				}
+			}
+		})
 	}
 }
@@ -31,14 +31,14 @@ func pineconeEx(ctx context.Context) error {
 	}
 
 	// [START init]
-	if err := pinecone.Init(ctx, ""); err != nil {
+	if err := pinecone.Init(ctx, nil); err != nil {
 		return err
 	}
 	// [END init]
 
 	var pineconeAPIKey string
 	// [START initkey]
-	if err := pinecone.Init(ctx, pineconeAPIKey); err != nil {
+	if err := pinecone.Init(ctx, &pinecone.ClientConfig{APIKey: pineconeAPIKey}); err != nil {
 		return err
 	}
 	// [END initkey]
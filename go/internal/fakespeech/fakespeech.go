@@ -0,0 +1,82 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fakespeech provides fake implementations of
+// ai.Transcriber and ai.SpeechSynthesizer for testing purposes.
+// The "audio" that Synthesize produces is not sound: it holds the
+// text, so that Transcribe can recover it. Audio pipelines can be
+// tested end to end without calling a speech service.
+package fakespeech
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+// Format is the content type of the audio made by Synthesize,
+// when the request does not ask for one.
+const Format = "audio/x-fake"
+
+// WordDuration is the length of time each word takes to speak,
+// in seconds. Transcribe uses it to time segments.
+const WordDuration = 0.5
+
+// Synthesize is a fake speech synthesis function.
+// The returned audio is a data URL holding the request text.
+func Synthesize(ctx context.Context, req *ai.SynthesizeSpeechRequest) (*ai.SynthesizeSpeechResponse, error) {
+	format := req.Format
+	if format == "" {
+		format = Format
+	}
+	data := base64.StdEncoding.EncodeToString([]byte(req.Text))
+	return &ai.SynthesizeSpeechResponse{
+		Audio: ai.NewMediaPart(format, "data:"+format+";base64,"+data),
+	}, nil
+}
+
+// Transcribe is a fake transcription function. It recovers the text
+// from audio made by [Synthesize]. If timestamps are requested, each
+// word is a segment lasting [WordDuration].
+func Transcribe(ctx context.Context, req *ai.TranscribeRequest) (*ai.TranscribeResponse, error) {
+	if req.Audio == nil {
+		return nil, errors.New("fakespeech: missing audio")
+	}
+	_, data, ok := strings.Cut(req.Audio.Text, ";base64,")
+	if !ok || !strings.HasPrefix(req.Audio.Text, "data:") {
+		return nil, errors.New("fakespeech: audio is not a base64 data URL")
+	}
+	text, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("fakespeech: %w", err)
+	}
+	res := &ai.TranscribeResponse{
+		Text:     string(text),
+		Language: req.Language,
+	}
+	if req.Timestamps {
+		for i, w := range strings.Fields(res.Text) {
+			res.Segments = append(res.Segments, &ai.TranscriptSegment{
+				Start: float64(i) * WordDuration,
+				End:   float64(i+1) * WordDuration,
+				Text:  w,
+			})
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakespeech
+
+import (
+	"context"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/internal/registry"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRoundTrip(t *testing.T) {
+	r, err := registry.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	synth := ai.DefineSpeechSynthesizer(r, "fake", "speech", Synthesize)
+	trans := ai.DefineTranscriber(r, "fake", "speech", Transcribe)
+	if ai.LookupTranscriber(r, "fake", "speech") == nil || ai.LookupSpeechSynthesizer(r, "fake", "speech") == nil {
+		t.Fatal("lookup failed")
+	}
+
+	ctx := context.Background()
+	sres, err := synth.SynthesizeSpeech(ctx, &ai.SynthesizeSpeechRequest{Text: "hello there", Format: "audio/wav"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sres.Audio.ContentType; got != "audio/wav" {
+		t.Errorf("content type: got %q, want audio/wav", got)
+	}
+	tres, err := trans.Transcribe(ctx, &ai.TranscribeRequest{Audio: sres.Audio, Language: "en", Timestamps: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ai.TranscribeResponse{
+		Text:     "hello there",
+		Language: "en",
+		Segments: []*ai.TranscriptSegment{
+			{Start: 0, End: 0.5, Text: "hello"},
+			{Start: 0.5, End: 1, Text: "there"},
+		},
+	}
+	if diff := cmp.Diff(want, tres); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	if _, err := trans.Transcribe(ctx, &ai.TranscribeRequest{Audio: ai.NewTextPart("hello")}); err == nil {
+		t.Error("transcribing a text part succeeded unexpectedly")
+	}
+	if _, err := synth.SynthesizeSpeech(ctx, &ai.SynthesizeSpeechRequest{}); err == nil {
+		t.Error("synthesizing empty text succeeded unexpectedly")
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrShuttingDown is returned when a flow is started after [Registry.Drain]
+// has been called.
+var ErrShuttingDown = errors.New("genkit is shutting down")
+
+// BeginFlow records that a flow run has started.
+// The caller must call end when the run, including saving its state, is over.
+// It returns [ErrShuttingDown] if the registry is draining.
+func (r *Registry) BeginFlow() (end func(), err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.draining {
+		return nil, ErrShuttingDown
+	}
+	r.activeFlows++
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.activeFlows--
+		if r.activeFlows == 0 && r.drained != nil {
+			close(r.drained)
+			r.drained = nil
+		}
+	}, nil
+}
+
+// Drain stops new flow runs from starting and waits until the running ones
+// have finished or ctx is done.
+func (r *Registry) Drain(ctx context.Context) error {
+	r.mu.Lock()
+	r.draining = true
+	if r.activeFlows == 0 {
+		r.mu.Unlock()
+		return nil
+	}
+	if r.drained == nil {
+		r.drained = make(chan struct{})
+	}
+	drained := r.drained
+	r.mu.Unlock()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -15,6 +15,7 @@
 package registry
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
@@ -36,11 +37,18 @@ type Registry struct {
 	frozen  bool // when true, no more additions
 	actions map[string]action.Action
 	flows   []Flow
+	health  map[string]HealthCheck
+
+	// For draining flows on shutdown.
+	draining    bool
+	activeFlows int
+	drained     chan struct{} // closed when activeFlows drops to zero while draining
 }
 
 func New() (*Registry, error) {
 	r := &Registry{
 		actions: map[string]action.Action{},
+		health:  map[string]HealthCheck{},
 	}
 	r.tstate = tracing.NewState()
 	return r, nil
@@ -117,10 +125,37 @@ func (r *Registry) ListFlows() []Flow {
 	return r.flows
 }
 
+// A HealthCheck reports whether something the program depends on is usable.
+type HealthCheck func(context.Context) error
+
+// RegisterHealthCheck records a health check under the given name.
+// It panics if a check with the same name is already registered.
+// Unlike actions, health checks may be registered at any time.
+func (r *Registry) RegisterHealthCheck(name string, check HealthCheck) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.health[name]; ok {
+		panic(fmt.Sprintf("health check %q is already registered", name))
+	}
+	r.health[name] = check
+}
+
+// HealthChecks returns the registered health checks, by name.
+func (r *Registry) HealthChecks() map[string]HealthCheck {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return maps.Clone(r.health)
+}
+
 func (r *Registry) RegisterSpanProcessor(sp sdktrace.SpanProcessor) {
 	r.tstate.RegisterSpanProcessor(sp)
 }
 
+// RegisterSpanObserver registers a function to be called at the end of every span.
+func (r *Registry) RegisterSpanObserver(o tracing.SpanObserver) {
+	r.tstate.RegisterSpanObserver(o)
+}
+
 // An Environment is the execution context in which the program is running.
 type Environment string
 
@@ -0,0 +1,357 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit writes a tamper-evident log of the flows and actions
+// run by a Genkit application.
+//
+// Each flow run and each action run (model calls, tool calls, retrievals
+// and so on) produces one [Record] holding the caller's identity, the action,
+// its input and output, and the trace ID. Records are captured for every
+// span, whether or not the span is sampled for tracing.
+//
+// Every record contains the hash of the previous record, and its own hash
+// covers all of its fields, so that editing, removing or reordering records
+// breaks the chain. Use [Verify] or [VerifyFile] to check a log.
+//
+// Records are hashed as they are written, then stored in order by a
+// background goroutine, so that actions do not wait for the backend.
+// Call [Log.Flush] or [Log.Close] to wait for them to be stored.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core"
+	"github.com/firebase/genkit/go/core/logger"
+	"github.com/firebase/genkit/go/core/tracing"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// A Record describes one flow or action run.
+type Record struct {
+	// Seq is the record's position in the log, starting at 1.
+	Seq uint64 `json:"seq"`
+	// Action is the key of the action that ran, such as "/flow/myFlow",
+	// "/model/googleai/gemini-1.5-flash" or "/tool/local/lookup".
+	Action    string          `json:"action"`
+	Subject   string          `json:"subject,omitempty"` // who invoked the action
+	TraceID   string          `json:"traceId"`
+	SpanID    string          `json:"spanId"`
+	Path      string          `json:"path"` // span path from the root span
+	StartTime time.Time       `json:"startTime"`
+	EndTime   time.Time       `json:"endTime"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	Output    json.RawMessage `json:"output,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	// ToolCalls are the tool requests in a model's response.
+	ToolCalls []*ai.ToolRequest `json:"toolCalls,omitempty"`
+	// PrevHash is the Hash of the previous record, or empty for the first record.
+	PrevHash string `json:"prevHash"`
+	// Hash is the hex-encoded SHA-256 hash of the record's JSON encoding
+	// with Hash set to the empty string.
+	Hash string `json:"hash"`
+}
+
+// computeHash returns the hash that r should have.
+func (r *Record) computeHash() (string, error) {
+	c := *r
+	c.Hash = ""
+	b, err := json.Marshal(&c)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// A Backend stores audit records.
+type Backend interface {
+	// Append stores r after all previously appended records.
+	Append(ctx context.Context, r *Record) error
+	// Last returns the most recently appended record, or nil if there are none.
+	// It is used to continue the chain when a [Log] is created.
+	Last(ctx context.Context) (*Record, error)
+}
+
+// Config configures a [Log].
+type Config struct {
+	// Backend stores the records. It is required.
+	Backend Backend
+	// Auth, if set, is used to retrieve the auth context of the caller.
+	// If nil, or if it finds no auth context, the action runtime context
+	// ([core.ActionContext]) is used.
+	Auth genkit.FlowAuth
+	// Subject returns the identity of the caller from the auth context.
+	// If nil, the "uid" or, failing that, the "sub" field is used.
+	Subject func(genkit.AuthContext) string
+	// Redact, if set, is called on each record before it is hashed and
+	// stored. It may remove or replace sensitive inputs and outputs.
+	Redact func(*Record)
+	// BufferSize is the number of written records that may wait to be
+	// stored. Once that many are waiting, for example because the backend
+	// syncs each record to disk, writers wait too. If zero, 1024 is used.
+	BufferSize int
+}
+
+// A Log writes audit records to a backend.
+type Log struct {
+	cfg Config
+
+	mu       sync.Mutex // guards the fields below and sends on queue
+	seq      uint64
+	prevHash string
+	closed   bool
+	queue    chan queued
+
+	done  chan struct{} // closed when the writer goroutine returns
+	errMu sync.Mutex
+	err   error // the first error from the backend
+}
+
+// A queued is a record waiting to be stored, or a request to flush.
+type queued struct {
+	ctx context.Context
+	r   *Record
+	// If flushed is not nil, r is nil and flushed is closed once
+	// the records queued before it are stored.
+	flushed chan struct{}
+}
+
+var errClosed = errorf("log is closed")
+
+// New returns a Log that records every flow and action run by g.
+// It continues the hash chain from the last record in cfg.Backend.
+// Call [Log.Close] before exiting to store the remaining records.
+func New(ctx context.Context, g *genkit.Genkit, cfg Config) (*Log, error) {
+	l, err := newLog(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	genkit.RegisterSpanObserver(g, l.observe)
+	return l, nil
+}
+
+func newLog(ctx context.Context, cfg Config) (*Log, error) {
+	if cfg.Backend == nil {
+		return nil, errorf("Config.Backend is required")
+	}
+	if cfg.BufferSize < 0 {
+		return nil, errorf("Config.BufferSize must not be negative")
+	}
+	if cfg.Subject == nil {
+		cfg.Subject = defaultSubject
+	}
+	if cfg.BufferSize == 0 {
+		cfg.BufferSize = 1024
+	}
+	l := &Log{cfg: cfg}
+	last, err := cfg.Backend.Last(ctx)
+	if err != nil {
+		return nil, errorf("reading last record: %w", err)
+	}
+	if last != nil {
+		l.seq = last.Seq
+		l.prevHash = last.Hash
+	}
+	l.queue = make(chan queued, cfg.BufferSize)
+	l.done = make(chan struct{})
+	go l.write()
+	return l, nil
+}
+
+func defaultSubject(ac genkit.AuthContext) string {
+	for _, k := range []string{"uid", "sub"} {
+		if s, ok := ac[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// Write adds r to the log. It sets r's Seq, PrevHash and Hash fields.
+// Most records are written automatically; Write is for adding
+// application-specific records to the same chain.
+//
+// Write returns before r is stored. Once the backend fails to store
+// a record, later records would break the chain, so they are dropped
+// and Write returns the backend's error.
+func (l *Log) Write(ctx context.Context, r *Record) error {
+	if err := l.backendErr(); err != nil {
+		return err
+	}
+	if l.cfg.Redact != nil {
+		l.cfg.Redact(r)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return errClosed
+	}
+	r.Seq = l.seq + 1
+	r.PrevHash = l.prevHash
+	h, err := r.computeHash()
+	if err != nil {
+		return errorf("hashing record: %w", err)
+	}
+	r.Hash = h
+	l.seq = r.Seq
+	l.prevHash = r.Hash
+	l.queue <- queued{ctx: context.WithoutCancel(ctx), r: r}
+	return nil
+}
+
+// Flush waits until the records already written are stored.
+// It returns the first error from the backend, if any.
+func (l *Log) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		<-l.done
+		return l.backendErr()
+	}
+	l.queue <- queued{flushed: flushed}
+	l.mu.Unlock()
+	select {
+	case <-flushed:
+		return l.backendErr()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stores the records already written and stops the log.
+// Later writes return an error. Close does not close the backend.
+// It returns the first error from the backend, if any.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.queue)
+	}
+	l.mu.Unlock()
+	<-l.done
+	return l.backendErr()
+}
+
+// write stores the queued records in order until the queue is closed.
+func (l *Log) write() {
+	defer close(l.done)
+	for q := range l.queue {
+		if q.flushed != nil {
+			close(q.flushed)
+			continue
+		}
+		if l.backendErr() != nil {
+			continue
+		}
+		if err := l.cfg.Backend.Append(q.ctx, q.r); err != nil {
+			err = errorf("appending record %d: %w", q.r.Seq, err)
+			l.errMu.Lock()
+			l.err = err
+			l.errMu.Unlock()
+			logger.FromContext(q.ctx).Error("audit: storing record", "action", q.r.Action, "err", err)
+		}
+	}
+}
+
+func (l *Log) backendErr() error {
+	l.errMu.Lock()
+	defer l.errMu.Unlock()
+	return l.err
+}
+
+// observe is a [tracing.SpanObserver] that writes a record for each
+// flow and action span.
+func (l *Log) observe(ctx context.Context, sr *tracing.SpanRecord) {
+	r := l.record(ctx, sr)
+	if r == nil {
+		return
+	}
+	if err := l.Write(ctx, r); err != nil && err != errClosed {
+		logger.FromContext(ctx).Error("audit: writing record", "action", r.Action, "err", err)
+	}
+}
+
+// record builds the record for sr, or returns nil if sr is not audited.
+func (l *Log) record(ctx context.Context, sr *tracing.SpanRecord) *Record {
+	var key string
+	switch sr.Type {
+	case "flow":
+		key = "/flow/" + sr.Name
+	case "action":
+		// A flow invoked as an action has its own flow span; don't log it twice.
+		if sr.Metadata["flow:wrapperAction"] == "true" {
+			return nil
+		}
+		key = "/" + sr.Metadata["subtype"] + "/" + sr.Name
+	default:
+		return nil
+	}
+	r := &Record{
+		Action:    key,
+		Subject:   l.subject(ctx),
+		TraceID:   sr.TraceID,
+		SpanID:    sr.SpanID,
+		Path:      sr.Path,
+		StartTime: sr.StartTime.UTC(),
+		EndTime:   sr.EndTime.UTC(),
+		Input:     marshal(ctx, sr.Input),
+	}
+	if sr.Err != nil {
+		r.Error = sr.Err.Error()
+	} else {
+		r.Output = marshal(ctx, sr.Output)
+	}
+	if resp, ok := sr.Output.(*ai.ModelResponse); ok && resp != nil && resp.Message != nil {
+		for _, p := range resp.Message.Content {
+			if p.IsToolRequest() {
+				r.ToolCalls = append(r.ToolCalls, p.ToolRequest)
+			}
+		}
+	}
+	return r
+}
+
+func (l *Log) subject(ctx context.Context) string {
+	var ac genkit.AuthContext
+	if l.cfg.Auth != nil {
+		ac = l.cfg.Auth.FromContext(ctx)
+	}
+	if ac == nil {
+		ac = core.ActionContext(ctx)
+	}
+	if ac == nil {
+		return ""
+	}
+	return l.cfg.Subject(ac)
+}
+
+func marshal(ctx context.Context, v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		logger.FromContext(ctx).Error("audit: marshaling value", "err", err)
+		return nil
+	}
+	return b
+}
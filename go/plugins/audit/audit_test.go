@@ -0,0 +1,316 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/firebase/genkit/go/internal/base"
+	"github.com/google/go-cmp/cmp"
+)
+
+var authKey = base.NewContextKey[genkit.AuthContext]()
+
+type fakeAuth struct{}
+
+func (fakeAuth) ProvideAuthContext(ctx context.Context, authHeader string) (context.Context, error) {
+	return authKey.NewContext(ctx, genkit.AuthContext{"uid": authHeader}), nil
+}
+
+func (fakeAuth) NewContext(ctx context.Context, ac genkit.AuthContext) context.Context {
+	return authKey.NewContext(ctx, ac)
+}
+
+func (fakeAuth) FromContext(ctx context.Context) genkit.AuthContext { return authKey.FromContext(ctx) }
+
+func (fakeAuth) CheckAuthPolicy(context.Context, any) error { return nil }
+
+func TestLog(t *testing.T) {
+	ctx := context.Background()
+	g, err := genkit.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	backend, err := NewFileBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	log, err := New(ctx, g, Config{Backend: backend, Auth: fakeAuth{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tool := genkit.DefineTool(g, "double", "doubles a number", func(ctx context.Context, in struct{ X int }) (int, error) {
+		return 2 * in.X, nil
+	})
+	// The model asks for the tool once, then answers with its result.
+	model := genkit.DefineModel(g, "test", "model", nil,
+		func(ctx context.Context, req *ai.ModelRequest, _ ai.ModelStreamingCallback) (*ai.ModelResponse, error) {
+			last := req.Messages[len(req.Messages)-1]
+			var part *ai.Part
+			if last.Role == ai.RoleTool {
+				part = ai.NewTextPart("done")
+			} else {
+				part = ai.NewToolRequestPart(&ai.ToolRequest{Name: "double", Input: map[string]any{"X": 21}})
+			}
+			return &ai.ModelResponse{Request: req, Message: &ai.Message{Role: ai.RoleModel, Content: []*ai.Part{part}}}, nil
+		})
+	flow := genkit.DefineFlow(g, "chat", func(ctx context.Context, in string) (string, error) {
+		return genkit.GenerateText(ctx, g, ai.WithModel(model), ai.WithTextPrompt(in), ai.WithTools(tool))
+	}, genkit.WithFlowAuth(fakeAuth{}))
+
+	if _, err := flow.Run(ctx, "hi", genkit.WithLocalAuth(genkit.AuthContext{"uid": "alice"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []*Record
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := readRecords(f, func(r *Record) error { records = append(records, r); return nil }); err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, r := range records {
+		actions = append(actions, r.Action)
+		if r.Subject != "alice" {
+			t.Errorf("%s: got subject %q, want alice", r.Action, r.Subject)
+		}
+		if r.TraceID != records[len(records)-1].TraceID {
+			t.Errorf("%s: trace ID differs from the flow's", r.Action)
+		}
+	}
+	want := []string{"/model/test/model", "/tool/local/double", "/model/test/model", "/flow/chat"}
+	if diff := cmp.Diff(want, actions); diff != "" {
+		t.Fatalf("actions mismatch (-want, +got):\n%s", diff)
+	}
+	if got := records[0].ToolCalls; len(got) != 1 || got[0].Name != "double" {
+		t.Errorf("got tool calls %+v, want one call to double", got)
+	}
+	if got := string(records[1].Input); got != `{"X":21}` {
+		t.Errorf("got tool input %s", got)
+	}
+	if got := string(records[3].Output); got != `"done"` {
+		t.Errorf("got flow output %s", got)
+	}
+
+	last, err := VerifyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Seq != 4 {
+		t.Errorf("got last seq %d, want 4", last.Seq)
+	}
+
+	// A new log continues the chain.
+	l, err := newLog(ctx, Config{Backend: backend})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Write(ctx, &Record{Action: "/custom/note"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if last, err = VerifyFile(path); err != nil {
+		t.Fatal(err)
+	} else if last.Seq != 5 {
+		t.Errorf("got last seq %d, want 5", last.Seq)
+	}
+}
+
+func TestLogHTTPSubject(t *testing.T) {
+	ctx := context.Background()
+	g, err := genkit.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &memBackend{}
+	log, err := New(ctx, g, Config{Backend: b, Auth: fakeAuth{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	genkit.DefineFlow(g, "echo", func(ctx context.Context, in string) (string, error) {
+		return in, nil
+	}, genkit.WithFlowAuth(fakeAuth{}))
+	srv := httptest.NewServer(genkit.NewFlowServeMux(g, nil))
+	defer srv.Close()
+
+	req, err := http.NewRequest("POST", srv.URL+"/echo", strings.NewReader(`{"data": "hi"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "bob")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %s", res.Status)
+	}
+	if err := log.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.records) != 1 {
+		t.Fatalf("got %d records, want 1", len(b.records))
+	}
+	if got := b.records[0].Subject; got != "bob" {
+		t.Errorf("got subject %q, want bob", got)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	b := &memBackend{}
+	l, err := newLog(ctx, Config{Backend: b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for _, a := range []string{"/a", "/b", "/c"} {
+		if err := l.Write(ctx, &Record{Action: a, Input: []byte(`{"k":"v"}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(b.records); err != nil {
+		t.Fatal(err)
+	}
+
+	copyRecords := func() []*Record {
+		var rs []*Record
+		for _, r := range b.records {
+			c := *r
+			rs = append(rs, &c)
+		}
+		return rs
+	}
+	for _, test := range []struct {
+		name   string
+		tamper func([]*Record) []*Record
+		want   string
+	}{
+		{"edit", func(rs []*Record) []*Record { rs[1].Input = []byte(`{"k":"w"}`); return rs }, "record 2: hash mismatch"},
+		{"remove", func(rs []*Record) []*Record { return append(rs[:1], rs[2:]...) }, "record 3: expected sequence number 2"},
+		{"remove first", func(rs []*Record) []*Record { return rs[1:] }, "record 2: expected sequence number 1"},
+		{"reorder", func(rs []*Record) []*Record { rs[1], rs[2] = rs[2], rs[1]; return rs }, "record 3: expected sequence number 2"},
+		{"rehash", func(rs []*Record) []*Record {
+			// Recomputing an edited record's hash breaks the link from the next record.
+			rs[1].Action = "/x"
+			rs[1].Hash, _ = rs[1].computeHash()
+			return rs
+		}, "record 3: previous hash does not match"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := Verify(test.tamper(copyRecords()))
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteDoesNotWaitForBackend(t *testing.T) {
+	ctx := context.Background()
+	b := &memBackend{release: make(chan struct{})}
+	l, err := newLog(ctx, Config{Backend: b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Append blocks until release is closed, but the writes return.
+	for _, a := range []string{"/a", "/b", "/c"} {
+		if err := l.Write(ctx, &Record{Action: a}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(b.release)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(b.records); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.records) != 3 {
+		t.Errorf("got %d records, want 3", len(b.records))
+	}
+	if err := l.Write(ctx, &Record{Action: "/d"}); err != errClosed {
+		t.Errorf("write after close: got %v, want %v", err, errClosed)
+	}
+}
+
+func TestBackendError(t *testing.T) {
+	ctx := context.Background()
+	b := &memBackend{fail: errors.New("disk full")}
+	l, err := newLog(ctx, Config{Backend: b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := l.Write(ctx, &Record{Action: "/a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Flush(ctx); !errors.Is(err, b.fail) {
+		t.Errorf("Flush: got %v, want %v", err, b.fail)
+	}
+	// Later records would not follow the last stored one, so they are refused.
+	if err := l.Write(ctx, &Record{Action: "/b"}); !errors.Is(err, b.fail) {
+		t.Errorf("Write: got %v, want %v", err, b.fail)
+	}
+}
+
+type memBackend struct {
+	records []*Record
+	release chan struct{} // if not nil, Append waits until it is closed
+	fail    error         // if not nil, Append returns it
+}
+
+func (b *memBackend) Append(_ context.Context, r *Record) error {
+	if b.release != nil {
+		<-b.release
+	}
+	if b.fail != nil {
+		return b.fail
+	}
+	b.records = append(b.records, r)
+	return nil
+}
+
+func (b *memBackend) Last(context.Context) (*Record, error) {
+	if len(b.records) == 0 {
+		return nil, nil
+	}
+	return b.records[len(b.records)-1], nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// auditverify checks the hash chain of audit log files written by
+// the audit package's FileBackend.
+//
+// Usage:
+//
+//	auditverify [-last HASH] FILE...
+//
+// For each file, it prints the number of records and the hash of the
+// last record. With -last, it also checks that the last record of each
+// file has the given hash, which detects records removed from the end.
+// It exits with status 1 if any file fails verification.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/firebase/genkit/go/plugins/audit"
+)
+
+var wantLast = flag.String("last", "", "expected hash of the last record")
+
+func main() {
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("auditverify: ")
+	if flag.NArg() == 0 {
+		log.Fatal("usage: auditverify [-last HASH] FILE...")
+	}
+	ok := true
+	for _, file := range flag.Args() {
+		last, err := audit.VerifyFile(file)
+		if err != nil {
+			log.Print(err)
+			ok = false
+			continue
+		}
+		if last == nil {
+			fmt.Printf("%s: empty\n", file)
+			continue
+		}
+		if *wantLast != "" && last.Hash != *wantLast {
+			log.Printf("%s: last record %d has hash %s, want %s", file, last.Seq, last.Hash, *wantLast)
+			ok = false
+			continue
+		}
+		fmt.Printf("%s: %d records OK, last hash %s\n", file, last.Seq, last.Hash)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+func errorf(format string, args ...any) error {
+	return fmt.Errorf("audit: "+format, args...)
+}
+
+// A FileBackend stores records in a file, one JSON object per line.
+type FileBackend struct {
+	path string
+	mu   sync.Mutex
+	f    *os.File
+}
+
+// NewFileBackend returns a FileBackend that appends to the file at path,
+// creating it if necessary.
+func NewFileBackend(path string) (*FileBackend, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileBackend{path: path, f: f}, nil
+}
+
+// Append implements [Backend.Append]. Each record is synced to
+// stable storage before Append returns.
+func (b *FileBackend) Append(ctx context.Context, r *Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, err := b.f.Write(data); err != nil {
+		return err
+	}
+	return b.f.Sync()
+}
+
+// Last implements [Backend.Last].
+func (b *FileBackend) Last(ctx context.Context) (*Record, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	f, err := os.Open(b.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var last *Record
+	err = readRecords(f, func(r *Record) error {
+		last = r
+		return nil
+	})
+	return last, err
+}
+
+// Close closes the file.
+func (b *FileBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.f.Close()
+}
+
+// readRecords calls f on each record in r, which holds one JSON record per line.
+func readRecords(r io.Reader, f func(*Record) error) error {
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := br.ReadBytes('\n')
+		if len(data) > 0 {
+			var rec Record
+			if err := json.Unmarshal(data, &rec); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			if err := f(&rec); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"fmt"
+	"os"
+)
+
+// A verifier checks records one at a time.
+type verifier struct {
+	prev *Record
+}
+
+func (v *verifier) check(r *Record) error {
+	wantSeq, wantPrev := uint64(1), ""
+	if v.prev != nil {
+		wantSeq, wantPrev = v.prev.Seq+1, v.prev.Hash
+	}
+	if r.Seq != wantSeq {
+		return fmt.Errorf("record %d: expected sequence number %d", r.Seq, wantSeq)
+	}
+	if r.PrevHash != wantPrev {
+		return fmt.Errorf("record %d: previous hash does not match record %d", r.Seq, r.Seq-1)
+	}
+	h, err := r.computeHash()
+	if err != nil {
+		return fmt.Errorf("record %d: %w", r.Seq, err)
+	}
+	if h != r.Hash {
+		return fmt.Errorf("record %d: hash mismatch; the record has been modified", r.Seq)
+	}
+	v.prev = r
+	return nil
+}
+
+// Verify checks that records form an unbroken chain starting at the
+// first record of a log. It returns an error describing the first
+// record that was modified, removed or reordered.
+//
+// Verify cannot detect records removed from the end of a log. To detect
+// that, compare the hash of the last record with one stored elsewhere.
+func Verify(records []*Record) error {
+	var v verifier
+	for _, r := range records {
+		if err := v.check(r); err != nil {
+			return errorf("%w", err)
+		}
+	}
+	return nil
+}
+
+// VerifyFile is like [Verify], but reads records from a file written by
+// a [FileBackend]. It returns the last record in the file, or nil if the
+// file is empty.
+func VerifyFile(path string) (*Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var v verifier
+	if err := readRecords(f, v.check); err != nil {
+		return nil, errorf("%s: %w", path, err)
+	}
+	return v.prev, nil
+}
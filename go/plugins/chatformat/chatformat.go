@@ -0,0 +1,57 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package chatformat converts conversations between Genkit messages
+// and other common formats: OpenAI chat messages, Gemini contents, and
+// a JSONL dataset format. Use it to import conversation logs, build
+// fine-tuning sets, or replay conversations against a Genkit model.
+//
+// Genkit tool requests carry no ID, so exporters that need one invent it,
+// and tool responses are matched to requests by tool name, in order.
+package chatformat
+
+import (
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+// splitDataURL returns the content type and base64 data of a base64
+// data URL. It reports false if url is not one.
+func splitDataURL(url string) (contentType, data string, ok bool) {
+	rest, ok := strings.CutPrefix(url, "data:")
+	if !ok {
+		return "", "", false
+	}
+	prefix, data, ok := strings.Cut(rest, ",")
+	if !ok {
+		return "", "", false
+	}
+	contentType, ok = strings.CutSuffix(prefix, ";base64")
+	return contentType, data, ok
+}
+
+// mediaType returns the content type of a media part, from the
+// part itself, its data URL or the extension of its URL.
+func mediaType(p *ai.Part) string {
+	if p.ContentType != "" {
+		return p.ContentType
+	}
+	if ct, _, ok := splitDataURL(p.Text); ok {
+		return ct
+	}
+	return mime.TypeByExtension(path.Ext(p.Text))
+}
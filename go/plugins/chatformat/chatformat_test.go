@@ -0,0 +1,205 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chatformat
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/google/go-cmp/cmp"
+)
+
+// conversation exercises text, media and tool calls.
+var conversation = []*ai.Message{
+	{Role: ai.RoleSystem, Content: []*ai.Part{ai.NewTextPart("Be brief.")}},
+	{Role: ai.RoleUser, Content: []*ai.Part{
+		ai.NewTextPart("What is in this picture, and what's the weather there?"),
+		ai.NewMediaPart("image/png", "data:image/png;base64,iVBORw0KGgo="),
+	}},
+	{Role: ai.RoleModel, Content: []*ai.Part{
+		ai.NewToolRequestPart(&ai.ToolRequest{Name: "weather", Input: map[string]any{"city": "Paris"}}),
+		ai.NewToolRequestPart(&ai.ToolRequest{Name: "weather", Input: map[string]any{"city": "Lyon"}}),
+	}},
+	{Role: ai.RoleTool, Content: []*ai.Part{
+		ai.NewToolResponsePart(&ai.ToolResponse{Name: "weather", Output: map[string]any{"temp": 20.0}}),
+		ai.NewToolResponsePart(&ai.ToolResponse{Name: "weather", Output: map[string]any{"temp": 22.0}}),
+	}},
+	{Role: ai.RoleModel, Content: []*ai.Part{ai.NewTextPart("The Eiffel Tower; 20°C.")}},
+}
+
+func TestOpenAI(t *testing.T) {
+	oms, err := ToOpenAI(conversation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(oms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `[{"role":"system","content":"Be brief."},` +
+		`{"role":"user","content":[{"type":"text","text":"What is in this picture, and what's the weather there?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw0KGgo="}}]},` +
+		`{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}},{"id":"call_2","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Lyon\"}"}}]},` +
+		`{"role":"tool","content":"{\"temp\":20}","tool_call_id":"call_1"},` +
+		`{"role":"tool","content":"{\"temp\":22}","tool_call_id":"call_2"},` +
+		`{"role":"assistant","content":"The Eiffel Tower; 20°C."}]`
+	if got := string(b); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	var decoded []*OpenAIMessage
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := FromOpenAI(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(conversation, msgs); diff != "" {
+		t.Errorf("round trip mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestFromOpenAIErrors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		json string
+		want string
+	}{
+		{"unknown role", `[{"role":"robot","content":"hi"}]`, "unknown role"},
+		{"unknown call", `[{"role":"tool","tool_call_id":"x","content":"1"}]`, "unknown tool call"},
+		{"bad arguments", `[{"role":"assistant","tool_calls":[{"id":"a","type":"function","function":{"name":"f","arguments":"{"}}]}]`, "arguments"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var oms []*OpenAIMessage
+			if err := json.Unmarshal([]byte(test.json), &oms); err != nil {
+				t.Fatal(err)
+			}
+			_, err := FromOpenAI(oms)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestFromOpenAIToolText(t *testing.T) {
+	oms := []*OpenAIMessage{
+		{Role: "assistant", ToolCalls: []*OpenAIToolCall{{ID: "a", Type: "function", Function: OpenAIFunctionCall{Name: "f"}}}},
+		{Role: "tool", ToolCallID: "a", Content: OpenAIContent{{Type: "text", Text: "sunny"}}},
+	}
+	msgs, err := FromOpenAI(oms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := msgs[1].Content[0].ToolResponse.Output
+	if want := map[string]any{"content": "sunny"}; !cmp.Equal(got, want) {
+		t.Errorf("got output %v, want %v", got, want)
+	}
+}
+
+func TestFromOpenAIToolCallsOnly(t *testing.T) {
+	for _, content := range []string{`null`, `""`} {
+		in := `{"role": "assistant", "content": ` + content + `, "tool_calls": [` +
+			`{"id": "a", "type": "function", "function": {"name": "f", "arguments": "{}"}}]}`
+		var om OpenAIMessage
+		if err := json.Unmarshal([]byte(in), &om); err != nil {
+			t.Fatal(err)
+		}
+		msgs, err := FromOpenAI([]*OpenAIMessage{&om})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parts := msgs[0].Content; len(parts) != 1 || !parts[0].IsToolRequest() {
+			t.Errorf("content %s: got parts %+v, want one tool request", content, parts)
+		}
+		oms, err := ToOpenAI(msgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := json.Marshal(oms[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(out), `"content"`) {
+			t.Errorf("content %s: got %s, want no content", content, out)
+		}
+	}
+}
+
+func TestGemini(t *testing.T) {
+	system, contents, err := ToGemini(conversation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if system == nil || system.Parts[0].Text != "Be brief." {
+		t.Errorf("got system instruction %+v", system)
+	}
+	if got := contents[0].Parts[1].InlineData; got == nil || got.MimeType != "image/png" || got.Data != "iVBORw0KGgo=" {
+		t.Errorf("got inline data %+v", got)
+	}
+	if got := contents[2].Role; got != "user" {
+		t.Errorf("function responses have role %q, want user", got)
+	}
+	msgs, err := FromGemini(system, contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(conversation, msgs); diff != "" {
+		t.Errorf("round trip mismatch (-want, +got):\n%s", diff)
+	}
+
+	_, contents, err = ToGemini([]*ai.Message{{Role: ai.RoleUser, Content: []*ai.Part{ai.NewMediaPart("video/mp4", "gs://bucket/v.mp4")}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := contents[0].Parts[0].FileData, (&GeminiFileData{MimeType: "video/mp4", FileURI: "gs://bucket/v.mp4"}); !cmp.Equal(got, want) {
+		t.Errorf("got file data %+v, want %+v", got, want)
+	}
+}
+
+func TestJSONL(t *testing.T) {
+	cs := []*Conversation{
+		{Messages: conversation, Metadata: map[string]any{"source": "test"}},
+		{Messages: []*ai.Message{ai.NewUserTextMessage("<hi>")}},
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, c := range cs {
+		if err := w.Write(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 2 {
+		t.Errorf("got %d lines, want 2", n)
+	}
+	// Blank lines are skipped.
+	got, err := ReadAll(strings.NewReader("\n" + buf.String() + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Text parts do not keep their content type through JSON.
+	opts := cmp.FilterPath(func(p cmp.Path) bool { return p.Last().String() == ".ContentType" }, cmp.Ignore())
+	if diff := cmp.Diff(cs, got, opts); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	_, err = ReadAll(strings.NewReader(`{"messages":[]}` + "\n{"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("got error %v, want one on line 2", err)
+	}
+}
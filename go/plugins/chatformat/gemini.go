@@ -0,0 +1,172 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chatformat
+
+import (
+	"fmt"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+// A GeminiContent is a message in the Gemini API format.
+type GeminiContent struct {
+	// "user" or "model". Contents holding function responses may also
+	// have the role "function". The system instruction has no role.
+	Role  string        `json:"role,omitempty"`
+	Parts []*GeminiPart `json:"parts"`
+}
+
+// A GeminiPart is one part of a [GeminiContent]. Exactly one field is set.
+type GeminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	InlineData       *GeminiBlob             `json:"inlineData,omitempty"`
+	FileData         *GeminiFileData         `json:"fileData,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// A GeminiBlob holds media inline.
+type GeminiBlob struct {
+	MimeType string `json:"mimeType"`
+	// The media, base64-encoded.
+	Data string `json:"data"`
+}
+
+// GeminiFileData refers to media by URI.
+type GeminiFileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+// A GeminiFunctionCall is a request from the model to call a function.
+type GeminiFunctionCall struct {
+	Name string         `json:"name"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+// A GeminiFunctionResponse is the result of a [GeminiFunctionCall].
+type GeminiFunctionResponse struct {
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+// ToGemini converts Genkit messages to Gemini contents.
+// System messages are combined into the returned system instruction,
+// which is nil if there are none. Tool messages become user contents
+// of function responses.
+func ToGemini(msgs []*ai.Message) (system *GeminiContent, contents []*GeminiContent, err error) {
+	for i, m := range msgs {
+		parts, err := toGeminiParts(m.Content)
+		if err != nil {
+			return nil, nil, fmt.Errorf("message %d: %w", i, err)
+		}
+		switch m.Role {
+		case ai.RoleSystem:
+			if system == nil {
+				system = &GeminiContent{}
+			}
+			system.Parts = append(system.Parts, parts...)
+		case ai.RoleUser, ai.RoleTool:
+			contents = append(contents, &GeminiContent{Role: "user", Parts: parts})
+		case ai.RoleModel:
+			contents = append(contents, &GeminiContent{Role: "model", Parts: parts})
+		default:
+			return nil, nil, fmt.Errorf("message %d: unknown role %q", i, m.Role)
+		}
+	}
+	return system, contents, nil
+}
+
+func toGeminiParts(parts []*ai.Part) ([]*GeminiPart, error) {
+	var out []*GeminiPart
+	for _, p := range parts {
+		var gp GeminiPart
+		switch {
+		case p.IsText() || p.IsData():
+			gp.Text = p.Text
+		case p.IsMedia():
+			ct := mediaType(p)
+			if _, data, ok := splitDataURL(p.Text); ok {
+				gp.InlineData = &GeminiBlob{MimeType: ct, Data: data}
+			} else {
+				gp.FileData = &GeminiFileData{MimeType: ct, FileURI: p.Text}
+			}
+		case p.IsToolRequest():
+			gp.FunctionCall = &GeminiFunctionCall{Name: p.ToolRequest.Name, Args: p.ToolRequest.Input}
+		case p.IsToolResponse():
+			gp.FunctionResponse = &GeminiFunctionResponse{Name: p.ToolResponse.Name, Response: p.ToolResponse.Output}
+		default:
+			return nil, fmt.Errorf("cannot convert %s part", partKindName(p))
+		}
+		out = append(out, &gp)
+	}
+	return out, nil
+}
+
+// FromGemini converts a Gemini system instruction, which may be nil,
+// and contents to Genkit messages. Contents made only of function
+// responses become tool messages.
+func FromGemini(system *GeminiContent, contents []*GeminiContent) ([]*ai.Message, error) {
+	var out []*ai.Message
+	if system != nil {
+		out = append(out, &ai.Message{Role: ai.RoleSystem, Content: fromGeminiParts(system.Parts)})
+	}
+	for i, c := range contents {
+		parts := fromGeminiParts(c.Parts)
+		var role ai.Role
+		switch c.Role {
+		case "user", "function", "":
+			role = ai.RoleUser
+			if allToolResponses(parts) {
+				role = ai.RoleTool
+			}
+		case "model":
+			role = ai.RoleModel
+		default:
+			return nil, fmt.Errorf("content %d: unknown role %q", i, c.Role)
+		}
+		out = append(out, &ai.Message{Role: role, Content: parts})
+	}
+	return out, nil
+}
+
+func fromGeminiParts(parts []*GeminiPart) []*ai.Part {
+	var out []*ai.Part
+	for _, p := range parts {
+		switch {
+		case p.InlineData != nil:
+			ct := p.InlineData.MimeType
+			out = append(out, ai.NewMediaPart(ct, "data:"+ct+";base64,"+p.InlineData.Data))
+		case p.FileData != nil:
+			out = append(out, ai.NewMediaPart(p.FileData.MimeType, p.FileData.FileURI))
+		case p.FunctionCall != nil:
+			out = append(out, ai.NewToolRequestPart(&ai.ToolRequest{Name: p.FunctionCall.Name, Input: p.FunctionCall.Args}))
+		case p.FunctionResponse != nil:
+			out = append(out, ai.NewToolResponsePart(&ai.ToolResponse{Name: p.FunctionResponse.Name, Output: p.FunctionResponse.Response}))
+		default:
+			out = append(out, ai.NewTextPart(p.Text))
+		}
+	}
+	return out
+}
+
+func allToolResponses(parts []*ai.Part) bool {
+	for _, p := range parts {
+		if !p.IsToolResponse() {
+			return false
+		}
+	}
+	return len(parts) > 0
+}
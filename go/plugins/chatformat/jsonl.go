@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chatformat
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+// A Conversation is one record of a JSONL dataset: a JSON object on a
+// single line. Messages use Genkit's JSON form, so tool calls and
+// media references (URLs, including data URLs) are kept as they are.
+type Conversation struct {
+	Messages []*ai.Message `json:"messages"`
+	// The tools that were available to the model, if any.
+	Tools []*ai.ToolDefinition `json:"tools,omitempty"`
+	// Information about the conversation, such as its source.
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// A Writer writes conversations as JSONL.
+type Writer struct {
+	enc *json.Encoder
+}
+
+// NewWriter returns a Writer that writes to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{enc: json.NewEncoder(w)}
+}
+
+// Write writes c as one line.
+func (w *Writer) Write(c *Conversation) error {
+	return w.enc.Encode(c)
+}
+
+// A Reader reads conversations from JSONL.
+type Reader struct {
+	r    *bufio.Reader
+	line int
+}
+
+// NewReader returns a Reader that reads from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next conversation, skipping blank lines.
+// At the end of the input, it returns io.EOF.
+func (r *Reader) Read() (*Conversation, error) {
+	for {
+		// ReadBytes has no line length limit, unlike a bufio.Scanner;
+		// lines with inline media can be large.
+		b, err := r.r.ReadBytes('\n')
+		if len(b) == 0 && err != nil {
+			return nil, err
+		}
+		r.line++
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(b)) == 0 {
+			continue
+		}
+		var c Conversation
+		if err := json.Unmarshal(b, &c); err != nil {
+			return nil, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		return &c, nil
+	}
+}
+
+// ReadAll reads all the conversations in r.
+func ReadAll(r io.Reader) ([]*Conversation, error) {
+	cr := NewReader(r)
+	var cs []*Conversation
+	for {
+		c, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return cs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		cs = append(cs, c)
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chatformat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/plugins/internal/toolcalls"
+)
+
+// An OpenAIMessage is a message in the OpenAI chat completions format.
+type OpenAIMessage struct {
+	// One of "system", "developer", "user", "assistant" or "tool".
+	Role    string        `json:"role"`
+	Content OpenAIContent `json:"content,omitempty"`
+	// The calls an assistant message makes.
+	ToolCalls []*OpenAIToolCall `json:"tool_calls,omitempty"`
+	// The call a tool message answers.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+// OpenAIContent is the content of an [OpenAIMessage]. In JSON, it is
+// either a string or an array of parts; it is marshaled as a string
+// when it is a single text part.
+type OpenAIContent []*OpenAIContentPart
+
+// An OpenAIContentPart is one part of an [OpenAIContent].
+type OpenAIContentPart struct {
+	// One of "text", "image_url" or "input_audio".
+	Type       string            `json:"type"`
+	Text       string            `json:"text,omitempty"`
+	ImageURL   *OpenAIImageURL   `json:"image_url,omitempty"`
+	InputAudio *OpenAIInputAudio `json:"input_audio,omitempty"`
+}
+
+// An OpenAIImageURL refers to an image by URL, which may be a data URL.
+type OpenAIImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// OpenAIInputAudio holds base64-encoded audio.
+type OpenAIInputAudio struct {
+	Data string `json:"data"`
+	// The audio format, such as "wav" or "mp3".
+	Format string `json:"format"`
+}
+
+// An OpenAIToolCall is a request from the assistant to call a function.
+type OpenAIToolCall struct {
+	ID       string             `json:"id"`
+	Type     string             `json:"type"`
+	Function OpenAIFunctionCall `json:"function"`
+}
+
+// OpenAIFunctionCall is the function and arguments of an [OpenAIToolCall].
+type OpenAIFunctionCall struct {
+	Name string `json:"name"`
+	// The arguments, as a JSON object.
+	Arguments string `json:"arguments"`
+}
+
+// MarshalJSON marshals a single text part as a string.
+func (c OpenAIContent) MarshalJSON() ([]byte, error) {
+	if len(c) == 1 && c[0].Type == "text" {
+		return json.Marshal(c[0].Text)
+	}
+	return json.Marshal([]*OpenAIContentPart(c))
+}
+
+// UnmarshalJSON accepts a string or an array of parts.
+// Null and the empty string, as in assistant messages that only
+// make tool calls, are no content.
+func (c *OpenAIContent) UnmarshalJSON(b []byte) error {
+	if string(bytes.TrimSpace(b)) == "null" {
+		*c = nil
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		if s == "" {
+			*c = nil
+		} else {
+			*c = OpenAIContent{{Type: "text", Text: s}}
+		}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]*OpenAIContentPart)(c))
+}
+
+// text returns the concatenated text parts of c.
+func (c OpenAIContent) text() string {
+	var sb strings.Builder
+	for _, p := range c {
+		sb.WriteString(p.Text)
+	}
+	return sb.String()
+}
+
+// ToOpenAI converts Genkit messages to OpenAI chat messages.
+// Each tool response becomes its own tool message.
+// Tool calls are given IDs of the form "call_N".
+func ToOpenAI(msgs []*ai.Message) ([]*OpenAIMessage, error) {
+	var out []*OpenAIMessage
+	var calls toolcalls.Pending
+	for i, m := range msgs {
+		switch m.Role {
+		case ai.RoleSystem, ai.RoleUser:
+			content, err := toOpenAIContent(m.Content, m.Role == ai.RoleUser)
+			if err != nil {
+				return nil, fmt.Errorf("message %d: %w", i, err)
+			}
+			out = append(out, &OpenAIMessage{Role: string(m.Role), Content: content})
+		case ai.RoleModel:
+			om := &OpenAIMessage{Role: "assistant"}
+			var text []*ai.Part
+			for _, p := range m.Content {
+				if !p.IsToolRequest() {
+					text = append(text, p)
+					continue
+				}
+				args, err := json.Marshal(p.ToolRequest.Input)
+				if err != nil {
+					return nil, fmt.Errorf("message %d: %w", i, err)
+				}
+				om.ToolCalls = append(om.ToolCalls, &OpenAIToolCall{
+					ID:       calls.Add("", p.ToolRequest.Name),
+					Type:     "function",
+					Function: OpenAIFunctionCall{Name: p.ToolRequest.Name, Arguments: string(args)},
+				})
+			}
+			content, err := toOpenAIContent(text, false)
+			if err != nil {
+				return nil, fmt.Errorf("message %d: %w", i, err)
+			}
+			om.Content = content
+			out = append(out, om)
+		case ai.RoleTool:
+			for _, p := range m.Content {
+				if !p.IsToolResponse() {
+					return nil, fmt.Errorf("message %d: tool message has a part that is not a tool response", i)
+				}
+				id, ok := calls.Answer(p.ToolResponse.Name)
+				if !ok {
+					return nil, fmt.Errorf("message %d: response from tool %q, which was not called", i, p.ToolResponse.Name)
+				}
+				output, err := json.Marshal(p.ToolResponse.Output)
+				if err != nil {
+					return nil, fmt.Errorf("message %d: %w", i, err)
+				}
+				out = append(out, &OpenAIMessage{
+					Role:       "tool",
+					ToolCallID: id,
+					Content:    OpenAIContent{{Type: "text", Text: string(output)}},
+				})
+			}
+		default:
+			return nil, fmt.Errorf("message %d: unknown role %q", i, m.Role)
+		}
+	}
+	return out, nil
+}
+
+// toOpenAIContent converts the parts of a message. Media is allowed only if media is true.
+func toOpenAIContent(parts []*ai.Part, media bool) (OpenAIContent, error) {
+	var c OpenAIContent
+	for _, p := range parts {
+		switch {
+		case p.IsText() || p.IsData():
+			c = append(c, &OpenAIContentPart{Type: "text", Text: p.Text})
+		case p.IsMedia() && media:
+			cp, err := toOpenAIMedia(p)
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, cp)
+		default:
+			return nil, fmt.Errorf("cannot convert %s part", partKindName(p))
+		}
+	}
+	return c, nil
+}
+
+func toOpenAIMedia(p *ai.Part) (*OpenAIContentPart, error) {
+	ct := mediaType(p)
+	switch {
+	case strings.HasPrefix(ct, "image/"):
+		return &OpenAIContentPart{Type: "image_url", ImageURL: &OpenAIImageURL{URL: p.Text}}, nil
+	case strings.HasPrefix(ct, "audio/"):
+		_, data, ok := splitDataURL(p.Text)
+		if !ok {
+			return nil, fmt.Errorf("audio must be a base64 data URL")
+		}
+		format := strings.TrimPrefix(ct, "audio/")
+		if format == "mpeg" {
+			format = "mp3"
+		}
+		return &OpenAIContentPart{Type: "input_audio", InputAudio: &OpenAIInputAudio{Data: data, Format: format}}, nil
+	default:
+		return nil, fmt.Errorf("cannot convert media of type %q", ct)
+	}
+}
+
+// FromOpenAI converts OpenAI chat messages to Genkit messages.
+// Consecutive tool messages become a single Genkit tool message.
+// Tool results that are not JSON objects are wrapped as {"content": result}.
+func FromOpenAI(msgs []*OpenAIMessage) ([]*ai.Message, error) {
+	var out []*ai.Message
+	var calls toolcalls.Pending
+	for i, m := range msgs {
+		switch m.Role {
+		case "system", "developer", "user":
+			role := ai.RoleUser
+			if m.Role != "user" {
+				role = ai.RoleSystem
+			}
+			parts, err := fromOpenAIContent(m.Content)
+			if err != nil {
+				return nil, fmt.Errorf("message %d: %w", i, err)
+			}
+			out = append(out, &ai.Message{Role: role, Content: parts})
+		case "assistant":
+			parts, err := fromOpenAIContent(m.Content)
+			if err != nil {
+				return nil, fmt.Errorf("message %d: %w", i, err)
+			}
+			for _, tc := range m.ToolCalls {
+				input, err := toolcalls.Input(tc.Function.Arguments)
+				if err != nil {
+					return nil, fmt.Errorf("message %d: %w", i, err)
+				}
+				calls.Add(tc.ID, tc.Function.Name)
+				parts = append(parts, ai.NewToolRequestPart(&ai.ToolRequest{Name: tc.Function.Name, Input: input}))
+			}
+			out = append(out, &ai.Message{Role: ai.RoleModel, Content: parts})
+		case "tool":
+			name, ok := calls.AnswerID(m.ToolCallID)
+			if !ok {
+				return nil, fmt.Errorf("message %d: answers unknown tool call %q", i, m.ToolCallID)
+			}
+			p := ai.NewToolResponsePart(&ai.ToolResponse{Name: name, Output: toolcalls.Output(m.Content.text())})
+			if n := len(out); n > 0 && out[n-1].Role == ai.RoleTool {
+				out[n-1].Content = append(out[n-1].Content, p)
+			} else {
+				out = append(out, &ai.Message{Role: ai.RoleTool, Content: []*ai.Part{p}})
+			}
+		default:
+			return nil, fmt.Errorf("message %d: unknown role %q", i, m.Role)
+		}
+	}
+	return out, nil
+}
+
+func fromOpenAIContent(c OpenAIContent) ([]*ai.Part, error) {
+	var parts []*ai.Part
+	for _, p := range c {
+		switch p.Type {
+		case "text":
+			parts = append(parts, ai.NewTextPart(p.Text))
+		case "image_url":
+			if p.ImageURL == nil {
+				return nil, fmt.Errorf("image_url part without image_url")
+			}
+			mp := ai.NewMediaPart("", p.ImageURL.URL)
+			mp.ContentType = mediaType(mp)
+			parts = append(parts, mp)
+		case "input_audio":
+			if p.InputAudio == nil {
+				return nil, fmt.Errorf("input_audio part without input_audio")
+			}
+			format := p.InputAudio.Format
+			if format == "mp3" {
+				format = "mpeg"
+			}
+			ct := "audio/" + format
+			parts = append(parts, ai.NewMediaPart(ct, "data:"+ct+";base64,"+p.InputAudio.Data))
+		default:
+			return nil, fmt.Errorf("unknown content part type %q", p.Type)
+		}
+	}
+	return parts, nil
+}
+
+// partKindName returns a name for the kind of p, for errors.
+func partKindName(p *ai.Part) string {
+	switch {
+	case p.IsMedia():
+		return "media"
+	case p.IsToolRequest():
+		return "tool request"
+	case p.IsToolResponse():
+		return "tool response"
+	default:
+		return "text"
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package crawler is a document loader that crawls a website and
+// produces one [ai.Document] per HTML page.
+//
+// The crawler starts from seed URLs, a sitemap, or both. It stays
+// within a set of allowed domains, honors robots.txt, limits the
+// request rate per host, and strips navigation and other boilerplate
+// from each page before emitting its text.
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core/logger"
+)
+
+const (
+	defaultUserAgent = "GenkitCrawler/1.0"
+	defaultMaxPages  = 100
+	// maxBodySize bounds how much of a single response is read.
+	maxBodySize = 10 << 20
+)
+
+// Config configures a crawl.
+type Config struct {
+	// SeedURLs are the pages the crawl starts from.
+	SeedURLs []string
+	// SitemapURL, if set, is a sitemap or sitemap index whose
+	// URLs are added to the seeds.
+	SitemapURL string
+	// AllowedDomains restricts the crawl to these hosts and their subdomains.
+	// If empty, the crawl is restricted to the hosts of the seed URLs
+	// and the sitemap.
+	AllowedDomains []string
+	// MaxDepth is the number of links that may be followed away from a seed.
+	// Zero fetches only the seeds.
+	MaxDepth int
+	// MaxPages is the maximum number of pages fetched.
+	// If zero, 100 is used.
+	MaxPages int
+	// Delay is the minimum time between two requests to the same host.
+	// A larger Crawl-delay in robots.txt takes precedence.
+	Delay time.Duration
+	// UserAgent is sent with every request and used to select
+	// robots.txt rules. If empty, "GenkitCrawler/1.0" is used.
+	UserAgent string
+	// IgnoreRobots disables robots.txt checks.
+	IgnoreRobots bool
+	// Client is used for all requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Load crawls the site described by cfg and returns the documents found.
+func Load(ctx context.Context, cfg Config) ([]*ai.Document, error) {
+	var docs []*ai.Document
+	err := Crawl(ctx, cfg, func(d *ai.Document) error {
+		docs = append(docs, d)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// Crawl crawls the site described by cfg, calling yield with each document
+// as it is produced. Each document's metadata holds the page's "url" and "title".
+// If yield returns an error, the crawl stops and Crawl returns that error.
+//
+// Pages that cannot be fetched are logged and skipped.
+func Crawl(ctx context.Context, cfg Config, yield func(*ai.Document) error) error {
+	c, err := newCrawler(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.SitemapURL != "" {
+		urls, err := c.sitemapURLs(ctx, cfg.SitemapURL)
+		if err != nil {
+			return fmt.Errorf("crawler: sitemap: %w", err)
+		}
+		for _, u := range urls {
+			c.enqueue(u, 0)
+		}
+	}
+	for _, u := range c.seeds {
+		c.enqueue(u, 0)
+	}
+	return c.run(ctx, yield)
+}
+
+type queued struct {
+	u     *url.URL
+	depth int
+}
+
+type crawler struct {
+	cfg       Config
+	client    *http.Client
+	seeds     []*url.URL
+	domains   []string
+	queue     []queued
+	seen      map[string]bool
+	robots    map[string]*robotsRules // keyed by scheme://host
+	lastFetch map[string]time.Time    // keyed by host
+}
+
+func newCrawler(cfg Config) (*crawler, error) {
+	if len(cfg.SeedURLs) == 0 && cfg.SitemapURL == "" {
+		return nil, errors.New("crawler: need at least one seed URL or a sitemap URL")
+	}
+	if cfg.MaxDepth < 0 {
+		return nil, fmt.Errorf("crawler: negative MaxDepth %d", cfg.MaxDepth)
+	}
+	if cfg.MaxPages == 0 {
+		cfg.MaxPages = defaultMaxPages
+	}
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = defaultUserAgent
+	}
+	c := &crawler{
+		cfg:       cfg,
+		client:    cfg.Client,
+		domains:   cfg.AllowedDomains,
+		seen:      map[string]bool{},
+		robots:    map[string]*robotsRules{},
+		lastFetch: map[string]time.Time{},
+	}
+	if c.client == nil {
+		c.client = http.DefaultClient
+	}
+	for _, s := range cfg.SeedURLs {
+		u, err := parseAbsURL(s)
+		if err != nil {
+			return nil, fmt.Errorf("crawler: seed: %w", err)
+		}
+		c.seeds = append(c.seeds, u)
+	}
+	if len(c.domains) == 0 {
+		for _, u := range c.seeds {
+			c.domains = append(c.domains, u.Host)
+		}
+		if cfg.SitemapURL != "" {
+			u, err := parseAbsURL(cfg.SitemapURL)
+			if err != nil {
+				return nil, fmt.Errorf("crawler: sitemap: %w", err)
+			}
+			c.domains = append(c.domains, u.Host)
+		}
+	}
+	return c, nil
+}
+
+func parseAbsURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%q is not an http or https URL", s)
+	}
+	return u, nil
+}
+
+// normalize returns a canonical form of u for de-duplication.
+func normalize(u *url.URL) string {
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	n.Host = strings.ToLower(n.Host)
+	n.Fragment = ""
+	n.RawFragment = ""
+	if n.Path == "" {
+		n.Path = "/"
+	}
+	return n.String()
+}
+
+// allowed reports whether u is within the crawl's domains.
+func (c *crawler) allowed(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Host)
+	hostname := strings.ToLower(u.Hostname())
+	for _, d := range c.domains {
+		d = strings.ToLower(d)
+		if host == d || hostname == d || strings.HasSuffix(hostname, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *crawler) enqueue(u *url.URL, depth int) {
+	if depth > c.cfg.MaxDepth || !c.allowed(u) {
+		return
+	}
+	key := normalize(u)
+	if c.seen[key] {
+		return
+	}
+	c.seen[key] = true
+	c.queue = append(c.queue, queued{u, depth})
+}
+
+func (c *crawler) run(ctx context.Context, yield func(*ai.Document) error) error {
+	fetched := 0
+	for len(c.queue) > 0 && fetched < c.cfg.MaxPages {
+		q := c.queue[0]
+		c.queue = c.queue[1:]
+		if !c.cfg.IgnoreRobots && !c.robotsFor(ctx, q.u).allowed(q.u) {
+			logger.FromContext(ctx).Debug("crawler: disallowed by robots.txt", "url", q.u.String())
+			continue
+		}
+		fetched++
+		page, err := c.fetchPage(ctx, q.u)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.FromContext(ctx).Debug("crawler: skipping page", "url", q.u.String(), "err", err)
+			continue
+		}
+		if page == nil {
+			continue
+		}
+		if !page.nofollow {
+			for _, l := range page.links {
+				c.enqueue(l, q.depth+1)
+			}
+		}
+		if page.noindex || page.text == "" {
+			continue
+		}
+		doc := ai.DocumentFromText(page.text, map[string]any{
+			"url":   page.url,
+			"title": page.title,
+		})
+		if err := yield(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchPage fetches and extracts u. It returns nil, nil if
+// the response is not HTML.
+func (c *crawler) fetchPage(ctx context.Context, u *url.URL) (*page, error) {
+	resp, err := c.get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+	mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mt != "text/html" && mt != "application/xhtml+xml" {
+		return nil, nil
+	}
+	// Redirects may have taken us elsewhere.
+	final := resp.Request.URL
+	if !c.allowed(final) {
+		return nil, nil
+	}
+	c.seen[normalize(final)] = true
+	return extract(io.LimitReader(resp.Body, maxBodySize), final)
+}
+
+// get issues a GET request for u, first waiting as long as needed
+// to respect the per-host delay.
+func (c *crawler) get(ctx context.Context, u *url.URL) (*http.Response, error) {
+	delay := c.cfg.Delay
+	if r := c.robots[robotsKey(u)]; r != nil && !c.cfg.IgnoreRobots && r.crawlDelay > delay {
+		delay = r.crawlDelay
+	}
+	if last, ok := c.lastFetch[u.Host]; ok && delay > 0 {
+		if wait := time.Until(last.Add(delay)); wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return nil, ctx.Err()
+			case <-t.C:
+			}
+		}
+	}
+	c.lastFetch[u.Host] = time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.cfg.UserAgent)
+	return c.client.Do(req)
+}
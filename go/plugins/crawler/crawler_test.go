@@ -0,0 +1,289 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/google/go-cmp/cmp"
+)
+
+const layout = `<!DOCTYPE html>
+<html><head><title>%s</title></head>
+<body>
+<header>Site Header</header>
+<nav><a href="/">Home</a> <a href="/a">A</a> <a href="/private/x">Private</a></nav>
+<main>%s</main>
+<footer>Copyright</footer>
+</body></html>`
+
+// testSite serves a small website and records the paths that were requested.
+type testSite struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []string
+}
+
+func newTestSite(t *testing.T) *testSite {
+	s := &testSite{}
+	pages := map[string]string{
+		"/": fmt.Sprintf(layout, "Home", `<h1>Welcome</h1><p>Start <a href="/a">here</a>.</p>`),
+		"/a": fmt.Sprintf(layout, "Page A", `<p>Alpha text.</p>
+			<script>var x = 1;</script>
+			<a href="/b#section">B</a> <a href="https://elsewhere.example/">Off site</a>`),
+		"/b":         fmt.Sprintf(layout, "Page B", `<p>Beta text.</p><a href="/c">C</a>`),
+		"/c":         fmt.Sprintf(layout, "Page C", `<p>Gamma text.</p>`),
+		"/private/x": fmt.Sprintf(layout, "Private", `<p>Secret.</p>`),
+		"/noindex": `<html><head><meta name="robots" content="noindex"></head>
+			<body><p>Hidden</p><a href="/c">C</a></body></html>`,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private/\n")
+	})
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>%s/pages.xml</loc></sitemap>
+</sitemapindex>`, s.URL)
+	})
+	mux.HandleFunc("/pages.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprintf(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>%[1]s/c</loc></url>
+  <url><loc>%[1]s/noindex</loc></url>
+</urlset>`, s.URL)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, body)
+	})
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.URL.Path)
+		s.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testSite) requested(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Contains(s.requests, path)
+}
+
+// summarize returns the URL path and title of each document.
+func summarize(t *testing.T, docs []*ai.Document) []string {
+	t.Helper()
+	var got []string
+	for _, d := range docs {
+		u, err := url.Parse(d.Metadata["url"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, u.Path+" "+d.Metadata["title"].(string))
+	}
+	return got
+}
+
+func TestCrawlDepth(t *testing.T) {
+	site := newTestSite(t)
+	for _, test := range []struct {
+		depth int
+		want  []string
+	}{
+		{0, []string{"/ Home"}},
+		{1, []string{"/ Home", "/a Page A"}},
+		{2, []string{"/ Home", "/a Page A", "/b Page B"}},
+		{3, []string{"/ Home", "/a Page A", "/b Page B", "/c Page C"}},
+	} {
+		t.Run(fmt.Sprint(test.depth), func(t *testing.T) {
+			docs, err := Load(context.Background(), Config{
+				SeedURLs: []string{site.URL + "/"},
+				MaxDepth: test.depth,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, summarize(t, docs)); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+	if site.requested("/private/x") {
+		t.Error("fetched a page disallowed by robots.txt")
+	}
+}
+
+func TestCrawlExtractsText(t *testing.T) {
+	site := newTestSite(t)
+	docs, err := Load(context.Background(), Config{SeedURLs: []string{site.URL + "/a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 {
+		t.Fatalf("got %d documents, want 1", len(docs))
+	}
+	got := docs[0].Content[0].Text
+	want := "Alpha text.\nB Off site"
+	if got != want {
+		t.Errorf("got text %q, want %q", got, want)
+	}
+}
+
+func TestCrawlSitemap(t *testing.T) {
+	site := newTestSite(t)
+	docs, err := Load(context.Background(), Config{SitemapURL: site.URL + "/sitemap.xml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The noindex page is fetched but not emitted.
+	if diff := cmp.Diff([]string{"/c Page C"}, summarize(t, docs)); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+	if !site.requested("/noindex") {
+		t.Error("noindex page was not fetched")
+	}
+}
+
+func TestCrawlMaxPages(t *testing.T) {
+	site := newTestSite(t)
+	docs, err := Load(context.Background(), Config{
+		SeedURLs: []string{site.URL + "/"},
+		MaxDepth: 5,
+		MaxPages: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Errorf("got %d documents, want 2", len(docs))
+	}
+}
+
+func TestCrawlDelay(t *testing.T) {
+	site := newTestSite(t)
+	const delay = 50 * time.Millisecond
+	start := time.Now()
+	_, err := Load(context.Background(), Config{
+		SeedURLs: []string{site.URL + "/"},
+		MaxDepth: 1,
+		Delay:    delay,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Three requests to one host: robots.txt, /, and /a.
+	if got := time.Since(start); got < 2*delay {
+		t.Errorf("crawl took %s, want at least %s", got, 2*delay)
+	}
+}
+
+func TestCrawlIgnoreRobots(t *testing.T) {
+	site := newTestSite(t)
+	docs, err := Load(context.Background(), Config{
+		SeedURLs:     []string{site.URL + "/private/x"},
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 {
+		t.Errorf("got %d documents, want 1", len(docs))
+	}
+	if site.requested("/robots.txt") {
+		t.Error("fetched robots.txt")
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	for _, cfg := range []Config{
+		{},
+		{SeedURLs: []string{"ftp://example.com"}},
+		{SeedURLs: []string{"https://example.com"}, MaxDepth: -1},
+	} {
+		if _, err := Load(context.Background(), cfg); err == nil {
+			t.Errorf("%+v: got nil error", cfg)
+		}
+	}
+}
+
+func TestRobots(t *testing.T) {
+	const robots = `
+# comment
+User-agent: otherbot
+Disallow: /
+
+User-agent: genkitcrawler
+User-agent: anotherbot
+Disallow: /docs/
+Allow: /docs/public
+Disallow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: *
+Disallow: /
+`
+	r := parseRobots(strings.NewReader(robots), "GenkitCrawler/1.0")
+	if r == nil {
+		t.Fatal("no rules matched")
+	}
+	if r.crawlDelay != 2*time.Second {
+		t.Errorf("got crawl delay %s, want 2s", r.crawlDelay)
+	}
+	for _, test := range []struct {
+		path string
+		want bool
+	}{
+		{"/", true},
+		{"/docs/private", false},
+		{"/docs/public/page", true},
+		{"/file.pdf", false},
+		{"/file.pdf?x=1", true},
+		{"/file.pdfx", true},
+	} {
+		u, err := url.Parse("http://example.com" + test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.allowed(u); got != test.want {
+			t.Errorf("%s: got %t, want %t", test.path, got, test.want)
+		}
+	}
+
+	// An agent with no group of its own falls back to "*".
+	r = parseRobots(strings.NewReader(robots), "SomeBot/2.0")
+	if u, _ := url.Parse("http://example.com/x"); r.allowed(u) {
+		t.Error("got allowed, want disallowed")
+	}
+}
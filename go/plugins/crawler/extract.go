@@ -0,0 +1,238 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// A page is the result of extracting an HTML document.
+type page struct {
+	url      string
+	title    string
+	text     string
+	links    []*url.URL
+	noindex  bool // <meta name="robots" content="noindex">
+	nofollow bool // <meta name="robots" content="nofollow">
+}
+
+// boilerplate holds elements whose content is never part of a page's text.
+var boilerplate = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Iframe:   true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Button:   true,
+	atom.Select:   true,
+}
+
+// boilerplateRoles holds ARIA roles that mark boilerplate.
+var boilerplateRoles = map[string]bool{
+	"navigation":    true,
+	"banner":        true,
+	"contentinfo":   true,
+	"complementary": true,
+	"search":        true,
+}
+
+// blocks holds elements that start a new line of text.
+var blocks = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Blockquote: true, atom.Br: true,
+	atom.Dd: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+	atom.Figcaption: true, atom.Figure: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Hr: true, atom.Li: true, atom.Main: true, atom.Ol: true, atom.P: true,
+	atom.Pre: true, atom.Section: true, atom.Table: true, atom.Td: true, atom.Th: true,
+	atom.Tr: true, atom.Ul: true,
+}
+
+// extract parses the HTML in r, which was fetched from u.
+func extract(r io.Reader, u *url.URL) (*page, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &page{url: u.String()}
+	base := u
+	var body, main, h1 *html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Title:
+				if p.title == "" {
+					p.title = collapse(textOf(n))
+				}
+			case atom.Base:
+				if b, err := u.Parse(attr(n, "href")); err == nil && attr(n, "href") != "" {
+					base = b
+				}
+			case atom.Meta:
+				if strings.EqualFold(attr(n, "name"), "robots") {
+					for _, d := range strings.Split(strings.ToLower(attr(n, "content")), ",") {
+						switch strings.TrimSpace(d) {
+						case "noindex":
+							p.noindex = true
+						case "nofollow":
+							p.nofollow = true
+						case "none":
+							p.noindex, p.nofollow = true, true
+						}
+					}
+				}
+			case atom.Body:
+				body = n
+			case atom.Main, atom.Article:
+				if main == nil && !isBoilerplate(n) {
+					main = n
+				}
+			case atom.H1:
+				if h1 == nil {
+					h1 = n
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	// Links are collected from the whole page, including navigation,
+	// since that is usually how the rest of a site is reached.
+	var links func(*html.Node)
+	links = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			rel := strings.ToLower(attr(n, "rel"))
+			if href := attr(n, "href"); href != "" && !strings.Contains(rel, "nofollow") {
+				if l, err := base.Parse(strings.TrimSpace(href)); err == nil {
+					l.Fragment = ""
+					l.RawFragment = ""
+					p.links = append(p.links, l)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			links(c)
+		}
+	}
+	links(doc)
+
+	root := main
+	if root == nil {
+		root = body
+	}
+	if root != nil {
+		var sb strings.Builder
+		writeText(&sb, root)
+		p.text = cleanLines(sb.String())
+	}
+	if p.title == "" && h1 != nil {
+		p.title = collapse(textOf(h1))
+	}
+	return p, nil
+}
+
+func isBoilerplate(n *html.Node) bool {
+	if boilerplate[n.DataAtom] || boilerplateRoles[strings.ToLower(attr(n, "role"))] {
+		return true
+	}
+	return attr(n, "aria-hidden") == "true" || hasAttr(n, "hidden")
+}
+
+// writeText writes the visible text of n to sb, skipping boilerplate.
+func writeText(sb *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		sb.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		if isBoilerplate(n) {
+			return
+		}
+	}
+	block := n.Type == html.ElementNode && blocks[n.DataAtom]
+	if block {
+		sb.WriteByte('\n')
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeText(sb, c)
+	}
+	if block {
+		sb.WriteByte('\n')
+	}
+}
+
+// textOf returns all the text under n.
+func textOf(n *html.Node) string {
+	var sb strings.Builder
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return sb.String()
+}
+
+// collapse replaces runs of whitespace with a single space.
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// cleanLines collapses whitespace within each line and drops empty lines.
+func cleanLines(s string) string {
+	var out []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = collapse(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && strings.EqualFold(a.Key, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robotsRules are the robots.txt rules that apply to the crawler on one host.
+// A nil *robotsRules allows everything.
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+func robotsKey(u *url.URL) string {
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// robotsFor returns the rules for u's host, fetching robots.txt the
+// first time the host is seen. A missing or unreadable robots.txt
+// allows everything.
+func (c *crawler) robotsFor(ctx context.Context, u *url.URL) *robotsRules {
+	key := robotsKey(u)
+	if r, ok := c.robots[key]; ok {
+		return r
+	}
+	var r *robotsRules
+	ru := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	resp, err := c.get(ctx, ru)
+	if err == nil {
+		if resp.StatusCode == http.StatusOK {
+			r = parseRobots(io.LimitReader(resp.Body, maxBodySize), c.cfg.UserAgent)
+		}
+		resp.Body.Close()
+	}
+	c.robots[key] = r
+	return r
+}
+
+// parseRobots parses a robots.txt file and returns the rules of the group
+// that best matches userAgent, falling back to the "*" group.
+func parseRobots(r io.Reader, userAgent string) *robotsRules {
+	type group struct {
+		agents []string
+		robotsRules
+	}
+	var (
+		groups []*group
+		cur    *group
+		// inAgents is true while reading consecutive User-agent lines.
+		inAgents bool
+	)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line, _, _ := strings.Cut(s.Text(), "#")
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		val = strings.TrimSpace(val)
+		if key == "user-agent" {
+			if !inAgents {
+				cur = &group{}
+				groups = append(groups, cur)
+				inAgents = true
+			}
+			cur.agents = append(cur.agents, strings.ToLower(val))
+			continue
+		}
+		inAgents = false
+		if cur == nil {
+			continue
+		}
+		switch key {
+		case "allow", "disallow":
+			// An empty Disallow allows everything; it adds no rule.
+			if val != "" {
+				cur.rules = append(cur.rules, robotsRule{allow: key == "allow", pattern: val})
+			}
+		case "crawl-delay":
+			if secs, err := strconv.ParseFloat(val, 64); err == nil && secs > 0 {
+				cur.crawlDelay = time.Duration(secs * float64(time.Second))
+			}
+		}
+	}
+
+	// The product token is the part of the user agent before the version.
+	token, _, _ := strings.Cut(strings.ToLower(userAgent), "/")
+	var match, star *group
+	for _, g := range groups {
+		for _, a := range g.agents {
+			if a == "*" {
+				if star == nil {
+					star = g
+				}
+			} else if match == nil && a != "" && strings.Contains(token, a) {
+				match = g
+			}
+		}
+	}
+	if match == nil {
+		match = star
+	}
+	if match == nil {
+		return nil
+	}
+	return &match.robotsRules
+}
+
+// allowed reports whether u may be fetched.
+// The longest matching rule wins; on a tie, Allow wins.
+func (r *robotsRules) allowed(u *url.URL) bool {
+	if r == nil {
+		return true
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	allow, best := true, -1
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		n := len(rule.pattern)
+		if n > best || (n == best && rule.allow) {
+			allow, best = rule.allow, n
+		}
+	}
+	return allow
+}
+
+// robotsMatch reports whether path matches a robots.txt pattern,
+// which is a path prefix that may contain '*' wildcards and end in '$'.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for _, p := range parts[1:] {
+		i := strings.Index(rest, p)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(p):]
+	}
+	if !anchored {
+		return true
+	}
+	if len(parts) > 1 {
+		// A trailing wildcard segment only needs to match the end of the path.
+		return rest == "" || strings.HasSuffix(path, parts[len(parts)-1])
+	}
+	return rest == ""
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/firebase/genkit/go/core/logger"
+)
+
+// maxSitemapDepth bounds how deeply sitemap indexes may nest.
+const maxSitemapDepth = 3
+
+// sitemap is either a <urlset> or a <sitemapindex>.
+// See https://www.sitemaps.org/protocol.html.
+type sitemap struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// sitemapURLs returns the page URLs listed in the sitemap at loc,
+// following sitemap indexes.
+func (c *crawler) sitemapURLs(ctx context.Context, loc string) ([]*url.URL, error) {
+	u, err := parseAbsURL(loc)
+	if err != nil {
+		return nil, err
+	}
+	return c.readSitemap(ctx, u, 0)
+}
+
+func (c *crawler) readSitemap(ctx context.Context, u *url.URL, depth int) ([]*url.URL, error) {
+	resp, err := c.get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: status %s", u, resp.Status)
+	}
+	var sm sitemap
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, maxBodySize)).Decode(&sm); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", u, err)
+	}
+	var urls []*url.URL
+	for _, l := range sm.URLs {
+		pu, err := u.Parse(strings.TrimSpace(l.Loc))
+		if err != nil {
+			continue
+		}
+		urls = append(urls, pu)
+	}
+	if depth >= maxSitemapDepth {
+		return urls, nil
+	}
+	for _, l := range sm.Sitemaps {
+		su, err := u.Parse(strings.TrimSpace(l.Loc))
+		if err != nil || !c.allowed(su) {
+			continue
+		}
+		sub, err := c.readSitemap(ctx, su, depth+1)
+		if err != nil {
+			// One broken child sitemap shouldn't stop the crawl.
+			logger.FromContext(ctx).Debug("crawler: skipping sitemap", "url", su.String(), "err", err)
+			continue
+		}
+		urls = append(urls, sub...)
+	}
+	return urls, nil
+}
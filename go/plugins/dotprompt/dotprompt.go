@@ -27,6 +27,7 @@ import (
 	"path/filepath"
 	"slices"
 	"strconv"
+	"text/template"
 
 	"github.com/aymerick/raymond"
 	"github.com/firebase/genkit/go/ai"
@@ -49,6 +50,12 @@ import (
 // The templates are evaluated with a couple of helpers.
 //   - {{role r}} changes to a new role for the following text
 //   - {{media url=URL}} adds a URL with an optional contentType
+//   - {{history}} marks where the conversation history goes
+//   - {{section "name"}} starts a new message for the named section,
+//     with the name as the "purpose" in the message metadata
+//
+// The frontmatter may select a different template language;
+// see [TemplateEngine].
 //
 // [Handlebars]: https://handlebarsjs.com
 type Prompt struct {
@@ -59,8 +66,12 @@ type Prompt struct {
 	Config
 
 	// The parsed prompt template.
+	// It is nil if the prompt does not use [EngineHandlebars].
 	Template *raymond.Template
 
+	// The parsed template, if the prompt uses [EngineGo].
+	goTemplate *template.Template
+
 	// The original prompt template text.
 	TemplateText string
 
@@ -69,12 +80,20 @@ type Prompt struct {
 
 	// A prompt that renders the prompt.
 	prompt *ai.Prompt
+
+	// Translations of the prompt, by locale.
+	locales *localeCache
 }
 
 // Config is optional configuration for a [Prompt].
 type Config struct {
 	// The prompt variant.
 	Variant string
+	// The locale of the prompt, such as "fr-CA".
+	// It is empty for the base prompt.
+	Locale string
+	// The language of the template. The default is [EngineHandlebars].
+	Engine TemplateEngine
 	// The name of the model for which the prompt is input.
 	// If this is non-empty, Model should be nil.
 	ModelName string
@@ -152,6 +171,8 @@ func OpenVariant(g *genkit.Genkit, name, variant string) (*Prompt, error) {
 type frontmatterYAML struct {
 	Name       string                     `yaml:"name,omitempty"`
 	Variant    string                     `yaml:"variant,omitempty"`
+	Locale     string                     `yaml:"locale,omitempty"`
+	Engine     string                     `yaml:"engine,omitempty"`
 	Model      string                     `yaml:"model,omitempty"`
 	Tools      []string                   `yaml:"tools,omitempty"`
 	Candidates int                        `yaml:"candidates,omitempty"`
@@ -191,18 +212,31 @@ func Parse(g *genkit.Genkit, name, variant string, data []byte) (*Prompt, error)
 // templateText should be a handlebars template.
 // hash is its SHA256 hash as a hex string.
 func newPrompt(name, templateText, hash string, config Config) (*Prompt, error) {
-	template, err := raymond.Parse(templateText)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse template: %w", err)
-	}
-	template.RegisterHelpers(templateHelpers)
-	return &Prompt{
+	p := &Prompt{
 		Name:         name,
 		Config:       config,
 		hash:         hash,
-		Template:     template,
 		TemplateText: templateText,
-	}, nil
+		locales:      &localeCache{},
+	}
+	switch config.Engine {
+	case "", EngineHandlebars:
+		template, err := raymond.Parse(templateText)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template: %w", err)
+		}
+		template.RegisterHelpers(templateHelpers)
+		p.Template = template
+	case EngineGo:
+		template, err := parseGoTemplate(name, templateText)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template: %w", err)
+		}
+		p.goTemplate = template
+	default:
+		return nil, fmt.Errorf("dotprompt: unknown template engine %q", config.Engine)
+	}
+	return p, nil
 }
 
 // parseFrontmatter parses the initial YAML frontmatter of a dotprompt file.
@@ -226,6 +260,8 @@ func parseFrontmatter(g *genkit.Genkit, data []byte) (name string, c Config, res
 
 	ret := Config{
 		Variant:          fy.Variant,
+		Locale:           fy.Locale,
+		Engine:           TemplateEngine(fy.Engine),
 		ModelName:        fy.Model,
 		Tools:            tools,
 		GenerationConfig: fy.Config,
@@ -15,6 +15,7 @@
 package dotprompt
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
@@ -25,6 +26,7 @@ import (
 	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/core/tracing"
 	"github.com/firebase/genkit/go/genkit"
+	"github.com/firebase/genkit/go/internal/base"
 )
 
 // PromptRequest is a request to execute a dotprompt template and
@@ -43,6 +45,11 @@ type PromptRequest struct {
 	ModelName string `json:"modelname,omitempty"`
 	// Streaming callback function
 	Stream ai.ModelStreamingCallback
+	// The conversation history, which replaces {{history}} in the prompt.
+	History []*ai.Message `json:"history,omitempty"`
+	// The locale in which to render the prompt. This overrides any
+	// locale in the context; see [ContextWithLocale].
+	Locale string `json:"locale,omitempty"`
 }
 
 // GenerateOption configures params for Generate function
@@ -103,8 +110,16 @@ fieldLoop:
 	return m, nil
 }
 
+// keepHistoryKey marks a context in which [Prompt.buildRequest] keeps
+// the history placeholder, so that [Prompt.Generate] can put the history
+// where the template says.
+var keepHistoryKey = base.NewContextKey[bool]()
+
 // buildRequest prepares an [ai.ModelRequest] based on the prompt,
 // using the input variables and other information in the [ai.PromptRequest].
+// It is the function of the prompt's action. Unless ctx is marked by
+// keepHistoryKey, a {{history}} in the template is removed, as there is
+// no history.
 func (p *Prompt) buildRequest(ctx context.Context, input any) (*ai.ModelRequest, error) {
 	req := &ai.ModelRequest{}
 
@@ -112,9 +127,12 @@ func (p *Prompt) buildRequest(ctx context.Context, input any) (*ai.ModelRequest,
 	if err != nil {
 		return nil, err
 	}
-	if req.Messages, err = p.RenderMessages(m); err != nil {
+	if req.Messages, err = p.renderMessages(m); err != nil {
 		return nil, err
 	}
+	if !keepHistoryKey.FromContext(ctx) {
+		req.Messages = insertHistory(req.Messages, nil)
+	}
 
 	req.Config = p.GenerationConfig
 
@@ -180,6 +198,9 @@ func (p *Prompt) Register(g *genkit.Genkit) error {
 // passes the rendered template to the AI model specified by
 // the prompt.
 //
+// If a locale is given by [WithLocale] or the context, the prompt's
+// translation for that locale is used instead; see [Prompt.Localize].
+//
 // This implements the [ai.Prompt] interface.
 func (p *Prompt) Generate(ctx context.Context, g *genkit.Genkit, opts ...GenerateOption) (*ai.ModelResponse, error) {
 	tracing.SetCustomMetadataAttr(ctx, "subtype", "prompt")
@@ -192,17 +213,30 @@ func (p *Prompt) Generate(ctx context.Context, g *genkit.Genkit, opts ...Generat
 		}
 	}
 
+	if locale := cmp.Or(pr.Locale, LocaleFromContext(ctx)); locale != "" {
+		lp, err := p.Localize(g, locale)
+		if err != nil {
+			return nil, err
+		}
+		tracing.SetCustomMetadataAttr(ctx, "locale", locale)
+		tracing.SetCustomMetadataAttr(ctx, "resolvedLocale", lp.Locale)
+		p = lp
+	}
+
 	var mr *ai.ModelRequest
 	var err error
+	rctx := keepHistoryKey.NewContext(ctx, true)
 	if p.prompt != nil {
-		mr, err = p.prompt.Render(ctx, pr.Input)
+		mr, err = p.prompt.Render(rctx, pr.Input)
 	} else {
-		mr, err = p.buildRequest(ctx, pr.Input)
+		mr, err = p.buildRequest(rctx, pr.Input)
 	}
 	if err != nil {
 		return nil, err
 	}
 
+	mr.Messages = insertHistory(mr.Messages, pr.History)
+
 	// Let some fields in pr override those in the prompt config.
 	if pr.Config != nil {
 		mr.Config = pr.Config
@@ -342,3 +376,26 @@ func WithStreaming(cb ai.ModelStreamingCallback) GenerateOption {
 		return nil
 	}
 }
+
+// WithLocale sets the locale in which to render the prompt.
+func WithLocale(locale string) GenerateOption {
+	return func(p *PromptRequest) error {
+		if p.Locale != "" {
+			return errors.New("dotprompt.WithLocale: cannot set Locale more than once")
+		}
+		p.Locale = locale
+		return nil
+	}
+}
+
+// WithHistory adds the conversation history. It replaces {{history}} in
+// the prompt or, if there is none, goes before the final user message.
+func WithHistory(history ...*ai.Message) GenerateOption {
+	return func(p *PromptRequest) error {
+		if p.History != nil {
+			return errors.New("dotprompt.WithHistory: cannot set History more than once")
+		}
+		p.History = history
+		return nil
+	}
+}
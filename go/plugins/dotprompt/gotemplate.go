@@ -0,0 +1,107 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotprompt
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+	"text/template"
+)
+
+// A TemplateEngine is the language in which a prompt template is written.
+// It is set by the "engine" field of the frontmatter.
+type TemplateEngine string
+
+const (
+	// EngineHandlebars is the default: templates are written in the
+	// Handlebars language.
+	EngineHandlebars TemplateEngine = "handlebars"
+	// EngineGo templates are written in the language of the Go
+	// [text/template] package. The data of the template is the map of
+	// input variables, so {{.subject}} is the subject input.
+	//
+	// Unlike in Handlebars, where a missing input renders as empty,
+	// referring to an input that was not given is an error. For an
+	// optional input, use the index function, as in
+	// {{with index . "subject"}}about {{.}}{{end}}.
+	//
+	// The dotprompt helpers are template functions:
+	//   - {{role "model"}} changes to a new role for the following text
+	//   - {{media URL}} or {{media URL CONTENT_TYPE}} adds media
+	//   - {{history}} marks where the conversation history goes
+	//   - {{section NAME}} starts a new message for the named section
+	//   - {{json VALUE}} or {{json VALUE INDENT}} formats a value as JSON
+	//
+	// Use [RegisterTemplateFuncs] to add functions of your own.
+	EngineGo TemplateEngine = "go"
+)
+
+var goFuncs struct {
+	mu    sync.Mutex
+	funcs template.FuncMap
+}
+
+// RegisterTemplateFuncs adds funcs to the functions available to
+// templates using [EngineGo]. It must be called before such templates
+// are parsed; in particular, before [Open] or [Define] is called for them.
+func RegisterTemplateFuncs(funcs template.FuncMap) {
+	goFuncs.mu.Lock()
+	defer goFuncs.mu.Unlock()
+	if goFuncs.funcs == nil {
+		goFuncs.funcs = template.FuncMap{}
+	}
+	maps.Copy(goFuncs.funcs, funcs)
+}
+
+// goTemplateFuncs are the dotprompt helpers for EngineGo templates.
+var goTemplateFuncs = template.FuncMap{
+	"role": func(role string) string {
+		return rolePrefix + role + markerSuffix
+	},
+	"media": func(url string, contentType ...string) (string, error) {
+		switch len(contentType) {
+		case 0:
+			return mediaMarker(url, ""), nil
+		case 1:
+			return mediaMarker(url, contentType[0]), nil
+		}
+		return "", fmt.Errorf("media: got %d content types, want at most one", len(contentType))
+	},
+	"history": func() string {
+		return historyMarker
+	},
+	"section": func(name string) string {
+		return sectionPrefix + name + markerSuffix
+	},
+	"json": func(v any, indent ...int) (string, error) {
+		switch len(indent) {
+		case 0:
+			return jsonString(v, 0), nil
+		case 1:
+			return jsonString(v, indent[0]), nil
+		}
+		return "", fmt.Errorf("json: got %d indents, want at most one", len(indent))
+	},
+}
+
+// parseGoTemplate parses a template written for EngineGo.
+func parseGoTemplate(name, text string) (*template.Template, error) {
+	goFuncs.mu.Lock()
+	defer goFuncs.mu.Unlock()
+	// Without missingkey=error, a missing input would be rendered
+	// as "<no value>" in the text sent to the model.
+	return template.New(name).Option("missingkey=error").Funcs(goTemplateFuncs).Funcs(goFuncs.funcs).Parse(text)
+}
@@ -0,0 +1,235 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotprompt
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGoTemplate(t *testing.T) {
+	RegisterTemplateFuncs(template.FuncMap{"upper": strings.ToUpper})
+	g, err := genkit.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name     string
+		template string
+		input    map[string]any
+		want     []*ai.Message
+	}{
+		{
+			name:     "variables and custom funcs",
+			template: `Hello {{.name | upper}}{{if .excited}}!{{end}}`,
+			input:    map[string]any{"name": "world", "excited": true},
+			want: []*ai.Message{
+				{Role: ai.RoleUser, Content: []*ai.Part{ai.NewTextPart("Hello WORLD!")}},
+			},
+		},
+		{
+			name:     "roles and media",
+			template: `{{role "system"}}Describe images.{{role "user"}}{{range .images}}{{media .}}{{end}}{{media .other "image/png"}} What are these?`,
+			input:    map[string]any{"images": []string{"http://1.jpg", "http://2.jpg"}, "other": "http://3.png"},
+			want: []*ai.Message{
+				{Role: ai.RoleSystem, Content: []*ai.Part{ai.NewTextPart("Describe images.")}},
+				{Role: ai.RoleUser, Content: []*ai.Part{
+					ai.NewMediaPart("", "http://1.jpg"),
+					ai.NewMediaPart("", "http://2.jpg"),
+					ai.NewMediaPart("image/png", "http://3.png"),
+					ai.NewTextPart(" What are these?"),
+				}},
+			},
+		},
+		{
+			name:     "sections and JSON",
+			template: `{{section "context"}}{{json .facts 2}}{{section "question"}}Why?`,
+			input:    map[string]any{"facts": map[string]any{"sky": "blue"}},
+			want: []*ai.Message{
+				{
+					Role:     ai.RoleUser,
+					Content:  []*ai.Part{ai.NewTextPart("{\n  \"sky\": \"blue\"\n}")},
+					Metadata: map[string]any{"purpose": "context"},
+				},
+				{
+					Role:     ai.RoleUser,
+					Content:  []*ai.Part{ai.NewTextPart("Why?")},
+					Metadata: map[string]any{"purpose": "question"},
+				},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := Parse(g, "", "", []byte("---\nengine: go\n---\n"+test.template))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Template != nil {
+				t.Error("Handlebars template was parsed")
+			}
+			got, err := p.RenderMessages(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := Parse(g, "", "", []byte("---\nengine: jinja\n---\nHi")); err == nil {
+		t.Error("unknown engine was accepted")
+	}
+}
+
+func TestGoTemplateMissingInput(t *testing.T) {
+	g, err := genkit.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := Parse(g, "", "", []byte("---\nengine: go\n---\nTell me about {{.subject}}."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := p.RenderMessages(map[string]any{}); err == nil {
+		t.Errorf("got %v, want error for missing input", got[0].Text())
+	}
+
+	// Optional inputs use index.
+	p, err = Parse(g, "", "", []byte("---\nengine: go\n---\nTell me a story{{with index . \"subject\"}} about {{.}}{{end}}."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		input map[string]any
+		want  string
+	}{
+		{map[string]any{}, "Tell me a story."},
+		{map[string]any{"subject": "cats"}, "Tell me a story about cats."},
+	} {
+		got, err := p.RenderMessages(test.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got[0].Text() != test.want {
+			t.Errorf("got %q, want %q", got[0].Text(), test.want)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	g, err := genkit.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []*ai.Message
+	model := genkit.DefineModel(g, "history", "test", nil, func(ctx context.Context, req *ai.ModelRequest, cb ai.ModelStreamingCallback) (*ai.ModelResponse, error) {
+		got = req.Messages
+		return &ai.ModelResponse{Message: ai.NewModelTextMessage("ok"), Request: req}, nil
+	})
+	history := []*ai.Message{ai.NewUserTextMessage("Hi"), ai.NewModelTextMessage("Hello")}
+	asHistory := func(m *ai.Message) *ai.Message {
+		return &ai.Message{Role: m.Role, Content: m.Content, Metadata: map[string]any{"purpose": "history"}}
+	}
+
+	for _, test := range []struct {
+		name, template string
+		want           []*ai.Message
+	}{
+		{
+			name:     "handlebars placeholder",
+			template: `{{role "system"}}Be brief.{{history}}Question`,
+			want: []*ai.Message{
+				{Role: ai.RoleSystem, Content: []*ai.Part{ai.NewTextPart("Be brief.")}},
+				asHistory(history[0]),
+				asHistory(history[1]),
+				ai.NewUserTextMessage("Question"),
+			},
+		},
+		{
+			name:     "go placeholder",
+			template: "---\nengine: go\n---\n{{role \"system\"}}Be brief.{{history}}Question",
+			want: []*ai.Message{
+				{Role: ai.RoleSystem, Content: []*ai.Part{ai.NewTextPart("Be brief.")}},
+				asHistory(history[0]),
+				asHistory(history[1]),
+				ai.NewUserTextMessage("Question"),
+			},
+		},
+		{
+			name:     "no placeholder",
+			template: `{{role "system"}}Be brief.{{role "user"}}Question`,
+			want: []*ai.Message{
+				{Role: ai.RoleSystem, Content: []*ai.Part{ai.NewTextPart("Be brief.")}},
+				asHistory(history[0]),
+				asHistory(history[1]),
+				ai.NewUserTextMessage("Question"),
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := Parse(g, "", "", []byte(test.template))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := p.Generate(context.Background(), g, WithModel(model), WithHistory(history...)); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+	if history[0].Metadata != nil {
+		t.Error("WithHistory modified the history messages")
+	}
+
+	// A registered prompt runs through its action, which must not
+	// return the placeholder.
+	p, err := Define(g, "registeredHistory", `{{role "system"}}Be brief.{{history}}Why?`, WithInputType(InputOutput{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Generate(context.Background(), g, WithModel(model), WithHistory(history...), WithInput(InputOutput{})); err != nil {
+		t.Fatal(err)
+	}
+	want := []*ai.Message{
+		{Role: ai.RoleSystem, Content: []*ai.Part{ai.NewTextPart("Be brief.")}},
+		asHistory(history[0]),
+		asHistory(history[1]),
+		ai.NewUserTextMessage("Why?"),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("registered prompt mismatch (-want, +got):\n%s", diff)
+	}
+	req, err := genkit.LookupPrompt(g, "dotprompt", "registeredHistory").Render(context.Background(), InputOutput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []*ai.Message{
+		{Role: ai.RoleSystem, Content: []*ai.Part{ai.NewTextPart("Be brief.")}},
+		ai.NewUserTextMessage("Why?"),
+	}
+	if diff := cmp.Diff(want, req.Messages); diff != "" {
+		t.Errorf("rendered action mismatch (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotprompt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/firebase/genkit/go/internal/base"
+)
+
+// localeKey is for storing a locale in a context.
+var localeKey = base.NewContextKey[string]()
+
+// ContextWithLocale returns a context in which prompts are rendered in
+// the given locale, such as "fr-CA".
+func ContextWithLocale(ctx context.Context, locale string) context.Context {
+	return localeKey.NewContext(ctx, locale)
+}
+
+// LocaleFromContext returns the locale stored in ctx by [ContextWithLocale],
+// or the empty string if there is none.
+func LocaleFromContext(ctx context.Context) string {
+	return localeKey.FromContext(ctx)
+}
+
+// maxCachedMisses bounds the number of locales a localeCache remembers
+// as having no translation. Locales often come from requests, so there
+// is no limit to how many distinct ones may be asked for.
+const maxCachedMisses = 64
+
+// localeCache holds the translations of a prompt that have been read.
+type localeCache struct {
+	mu      sync.Mutex
+	prompts map[string]*Prompt // nil if there is no file for the locale
+	misses  int                // number of nil entries in prompts
+}
+
+// Localize returns the translation of p for locale.
+//
+// Translations are read from the prompt directory, in files named
+// NAME.LOCALE.prompt, or NAME.VARIANT.LOCALE.prompt for a variant.
+// If there is no file for the locale, its subtags are dropped one at a
+// time, so "fr-CA" falls back to "fr". If no file is found, or locale
+// is not a well-formed language tag, Localize returns p.
+//
+// A translation without frontmatter uses the frontmatter of p.
+// Translations are not registered as actions of their own.
+func (p *Prompt) Localize(g *genkit.Genkit, locale string) (*Prompt, error) {
+	if p.Name == "" || g.Opts.PromptDir == "" {
+		return p, nil
+	}
+	for _, l := range localeFallbacks(locale) {
+		lp, err := p.localized(g, l)
+		if err != nil {
+			return nil, err
+		}
+		if lp != nil {
+			return lp, nil
+		}
+	}
+	return p, nil
+}
+
+// localized returns the translation of p for exactly locale,
+// or nil if there is none.
+func (p *Prompt) localized(g *genkit.Genkit, locale string) (*Prompt, error) {
+	c := p.locales
+	if c == nil {
+		// p was not made by this package, so there is nowhere to
+		// keep translations.
+		c = &localeCache{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if lp, ok := c.prompts[locale]; ok {
+		return lp, nil
+	}
+
+	name := p.Name
+	if p.Variant != "" {
+		name += "." + p.Variant
+	}
+	data, err := os.ReadFile(filepath.Join(g.Opts.PromptDir, name+"."+locale+".prompt"))
+	var lp *Prompt
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read dotprompt file %q for locale %q: %w", p.Name, locale, err)
+	case bytes.HasPrefix(data, []byte("---\n")):
+		if lp, err = Parse(g, p.Name, p.Variant, data); err != nil {
+			return nil, err
+		}
+	default:
+		if lp, err = New(p.Name, string(data), p.Config); err != nil {
+			return nil, err
+		}
+	}
+	if lp != nil {
+		lp.Variant = p.Variant
+		lp.Locale = locale
+	}
+	if c.prompts == nil {
+		c.prompts = map[string]*Prompt{}
+	}
+	if lp != nil {
+		c.prompts[locale] = lp
+	} else if c.misses < maxCachedMisses {
+		c.prompts[locale] = nil
+		c.misses++
+	}
+	return lp, nil
+}
+
+// localeFallbacks returns the locales to try for locale, most specific
+// first. For example, "zh_hant_tw" yields "zh-Hant-TW", "zh-Hant" and "zh".
+// It returns nil unless locale is made of subtags of one to eight ASCII
+// letters and digits, separated by '-' or '_', so that the locales it
+// returns are safe to use in file names.
+func localeFallbacks(locale string) []string {
+	subtags := strings.FieldsFunc(locale, func(r rune) bool { return r == '-' || r == '_' })
+	for i, s := range subtags {
+		if !validSubtag(s) {
+			return nil
+		}
+		switch {
+		case i == 0:
+			subtags[i] = strings.ToLower(s)
+		case len(s) == 4:
+			// A script, such as "Hant".
+			subtags[i] = strings.ToUpper(s[:1]) + strings.ToLower(s[1:])
+		case len(s) == 2 || len(s) == 3 && s[0] >= '0' && s[0] <= '9':
+			// A region, such as "CA" or "419".
+			subtags[i] = strings.ToUpper(s)
+		default:
+			subtags[i] = strings.ToLower(s)
+		}
+	}
+	var locales []string
+	for n := len(subtags); n > 0; n-- {
+		locales = append(locales, strings.Join(subtags[:n], "-"))
+	}
+	return locales
+}
+
+// validSubtag reports whether s can be a subtag of a BCP 47 language tag.
+func validSubtag(s string) bool {
+	if len(s) == 0 || len(s) > 8 {
+		return false
+	}
+	for _, r := range s {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
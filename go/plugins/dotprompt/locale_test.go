@@ -0,0 +1,155 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotprompt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLocaleFallbacks(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"de", []string{"de"}},
+		{"fr-CA", []string{"fr-CA", "fr"}},
+		{"FR_ca", []string{"fr-CA", "fr"}},
+		{"zh-hant-tw", []string{"zh-Hant-TW", "zh-Hant", "zh"}},
+		{"es-419", []string{"es-419", "es"}},
+		{"x/../../evil", nil},
+		{"fr-CA/..", nil},
+		{"..", nil},
+		{"en-toolongsubtag", nil},
+		{"fr CA", nil},
+	} {
+		if got := localeFallbacks(test.in); !cmp.Equal(got, test.want) {
+			t.Errorf("localeFallbacks(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	dir := t.TempDir()
+	for name, text := range map[string]string{
+		"greeting.prompt":    "---\nmodel: locale/test\n---\nHello",
+		"greeting.fr.prompt": "Bonjour",
+		// A translation with its own frontmatter.
+		"greeting.de.prompt":           "---\nmodel: locale/test\nconfig:\n  temperature: 0.5\n---\nHallo",
+		"greeting.casual.fr-CA.prompt": "Allo",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g, err := genkit.New(&genkit.Options{PromptDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	genkit.DefineModel(g, "locale", "test", nil, testGenerate)
+	p, err := Open(g, "greeting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	casual, err := New("greeting", "Hi", Config{Variant: "casual", ModelName: "locale/test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		prompt *Prompt
+		ctx    context.Context
+		opts   []GenerateOption
+		want   string
+	}{
+		{"base", p, context.Background(), nil, `AI reply to "Hello"`},
+		{"exact", p, context.Background(), []GenerateOption{WithLocale("fr")}, `AI reply to "Bonjour"`},
+		{"fallback", p, context.Background(), []GenerateOption{WithLocale("fr-CA")}, `AI reply to "Bonjour"`},
+		{"unknown", p, context.Background(), []GenerateOption{WithLocale("ja")}, `AI reply to "Hello"`},
+		{"context", p, ContextWithLocale(context.Background(), "de-AT"), nil, `AI reply to "Hallo"`},
+		{"option overrides context", p, ContextWithLocale(context.Background(), "de"), []GenerateOption{WithLocale("fr")}, `AI reply to "Bonjour"`},
+		{"variant", casual, context.Background(), []GenerateOption{WithLocale("fr-CA")}, `AI reply to "Allo"`},
+		{"variant fallback", casual, context.Background(), []GenerateOption{WithLocale("fr")}, `AI reply to "Hi"`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := test.prompt.Generate(test.ctx, g, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertResponse(t, resp, test.want)
+		})
+	}
+
+	de, err := p.Localize(g, "de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if de.Locale != "de" || de.GenerationConfig.Temperature != 0.5 {
+		t.Errorf("got locale %q and config %+v, want the German frontmatter", de.Locale, de.GenerationConfig)
+	}
+	if again, _ := p.Localize(g, "de-CH"); again != de {
+		t.Error("translation was not cached")
+	}
+}
+
+func TestLocalizeUntrustedLocales(t *testing.T) {
+	dir := t.TempDir()
+	promptDir := filepath.Join(dir, "prompts")
+	// With these, an unchecked "x/../../evil" locale would read
+	// dir/evil.prompt, outside the prompt directory.
+	if err := os.MkdirAll(filepath.Join(promptDir, "greeting.x"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range map[string]string{
+		filepath.Join(promptDir, "greeting.prompt"): "---\nmodel: locale/test\n---\nHello",
+		filepath.Join(dir, "evil.prompt"):           "Evil",
+	} {
+		if err := os.WriteFile(name, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g, err := genkit.New(&genkit.Options{PromptDir: promptDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := Open(g, "greeting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp, err := p.Localize(g, "x/../../evil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lp != p {
+		t.Errorf("got prompt with template %q, want the base prompt", lp.TemplateText)
+	}
+
+	for i := range 2 * maxCachedMisses {
+		if _, err := p.Localize(g, fmt.Sprintf("x-%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(p.locales.prompts); n > maxCachedMisses {
+		t.Errorf("cached %d locales without translations, want at most %d", n, maxCachedMisses)
+	}
+}
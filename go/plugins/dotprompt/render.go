@@ -19,6 +19,7 @@ import (
 	"errors"
 	"maps"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 
@@ -49,26 +50,53 @@ func (p *Prompt) RenderText(variables map[string]any) (string, error) {
 
 // RenderMessages executes the prompt's template and converts it into messages.
 // This just runs the template; it does not call a model.
+// A {{history}} in the template is removed, as there is no history.
 func (p *Prompt) RenderMessages(variables map[string]any) ([]*ai.Message, error) {
+	msgs, err := p.renderMessages(variables)
+	if err != nil {
+		return nil, err
+	}
+	return insertHistory(msgs, nil), nil
+}
+
+// renderMessages is like RenderMessages, but leaves in the
+// placeholder message for the history.
+func (p *Prompt) renderMessages(variables map[string]any) ([]*ai.Message, error) {
 	if p.DefaultInput != nil {
 		nv := make(map[string]any)
 		maps.Copy(nv, p.DefaultInput)
 		maps.Copy(nv, variables)
 		variables = nv
 	}
-	str, err := p.Template.Exec(variables)
-	if err != nil {
-		return nil, err
+	var str string
+	if p.goTemplate != nil {
+		var sb strings.Builder
+		if err := p.goTemplate.Execute(&sb, variables); err != nil {
+			return nil, err
+		}
+		str = sb.String()
+	} else {
+		var err error
+		if str, err = p.Template.Exec(variables); err != nil {
+			return nil, err
+		}
 	}
 	return p.toMessages(str)
 }
 
-const rolePrefix = "<<<dotprompt:role:"
-const roleSuffix = ">>>"
-const roleMatch = rolePrefix + "[a-z]+" + roleSuffix
+// The role, history and section helpers insert markers of the form
+// <<<dotprompt:role:ROLE>>>, <<<dotprompt:history>>> and
+// <<<dotprompt:section NAME>>>, which split the rendered template
+// into messages.
+const markerPrefix = "<<<dotprompt:"
+const markerSuffix = ">>>"
+const rolePrefix = markerPrefix + "role:"
+const historyMarker = markerPrefix + "history" + markerSuffix
+const sectionPrefix = markerPrefix + "section "
+const markerMatch = markerPrefix + "(?:role:[a-z]+|history|section [a-zA-Z0-9_-]+)" + markerSuffix
 
-var roleRegexp = sync.OnceValue(func() *regexp.Regexp {
-	return regexp.MustCompile(roleMatch)
+var markerRegexp = sync.OnceValue(func() *regexp.Regexp {
+	return regexp.MustCompile(markerMatch)
 })
 
 const mediaPrefix = "<<<dotprompt:media:url"
@@ -85,6 +113,12 @@ func jsonHelper(v any, options *raymond.Options) raymond.SafeString {
 	if indentArg := options.HashProp("indent"); indentArg != nil {
 		indent, _ = indentArg.(int)
 	}
+	return raymond.SafeString(jsonString(v, indent))
+}
+
+// jsonString returns v as JSON, indented by indent spaces if indent is
+// positive. It returns the error message if v can't be marshaled.
+func jsonString(v any, indent int) string {
 	var data []byte
 	var err error
 	if indent == 0 {
@@ -93,41 +127,61 @@ func jsonHelper(v any, options *raymond.Options) raymond.SafeString {
 		data, err = json.MarshalIndent(v, "", strings.Repeat(" ", indent))
 	}
 	if err != nil {
-		return raymond.SafeString(err.Error())
+		return err.Error()
 	}
-	return raymond.SafeString(data)
+	return string(data)
 }
 
 // roleHelper changes roles.
 func roleHelper(role string) raymond.SafeString {
-	return raymond.SafeString(rolePrefix + role + roleSuffix)
+	return raymond.SafeString(rolePrefix + role + markerSuffix)
 }
 
 // mediaHelper inserts media.
 func mediaHelper(options *raymond.Options) raymond.SafeString {
-	url := options.HashStr("url")
-	contentType := options.HashStr("contentType")
+	return raymond.SafeString(mediaMarker(options.HashStr("url"), options.HashStr("contentType")))
+}
+
+func mediaMarker(url, contentType string) string {
 	add := url
 	if contentType != "" {
 		add += " " + contentType
 	}
-	return raymond.SafeString(mediaPrefix + add + mediaSuffix)
+	return mediaPrefix + add + mediaSuffix
+}
+
+// historyHelper marks where the conversation history goes.
+func historyHelper() raymond.SafeString {
+	return raymond.SafeString(historyMarker)
+}
+
+// sectionHelper starts a new message, with the section name
+// as the "purpose" in its metadata.
+func sectionHelper(name string) raymond.SafeString {
+	return raymond.SafeString(sectionPrefix + name + markerSuffix)
 }
 
 // templateHelpers is the helpers supported by all dotprompt templates.
 var templateHelpers = map[string]any{
-	"json":  jsonHelper,
-	"role":  roleHelper,
-	"media": mediaHelper,
+	"json":    jsonHelper,
+	"role":    roleHelper,
+	"media":   mediaHelper,
+	"history": historyHelper,
+	"section": sectionHelper,
 }
 
 // toMessages converts the rendered prompt into a series of messages,
 // by splitting it on a magic regular expression.
-// This implements the "role" dotprompt helper function.
+// This implements the "role", "history" and "section" dotprompt helper functions.
+//
+// The history is represented by a placeholder message that is
+// replaced by [insertHistory]. Text after the history is in the
+// user role, unless the template sets a role.
 func (p *Prompt) toMessages(str string) ([]*ai.Message, error) {
 	type messageSource struct {
-		role   ai.Role
-		source string
+		role     ai.Role
+		source   string
+		metadata map[string]any
 	}
 
 	var msgs []*messageSource
@@ -135,9 +189,9 @@ func (p *Prompt) toMessages(str string) ([]*ai.Message, error) {
 		role: ai.RoleUser,
 	}
 
-	roleIndexes := roleRegexp().FindAllStringIndex(str, -1)
+	markerIndexes := markerRegexp().FindAllStringIndex(str, -1)
 	i := 0
-	for _, m := range roleIndexes {
+	for _, m := range markerIndexes {
 		if m[0] > i {
 			add := str[i:m[0]]
 			if strings.TrimSpace(add) != "" {
@@ -146,9 +200,22 @@ func (p *Prompt) toMessages(str string) ([]*ai.Message, error) {
 		}
 		if msg.source != "" {
 			msgs = append(msgs, msg)
-			msg = &messageSource{}
+			msg = &messageSource{role: msg.role}
+		}
+		marker := str[m[0]:m[1]]
+		switch {
+		case strings.HasPrefix(marker, rolePrefix):
+			msg.role = ai.Role(marker[len(rolePrefix) : len(marker)-len(markerSuffix)])
+			msg.metadata = nil
+		case marker == historyMarker:
+			msgs = append(msgs, &messageSource{
+				role:     msg.role,
+				metadata: map[string]any{"purpose": "history", "pending": true},
+			})
+			msg = &messageSource{role: ai.RoleUser}
+		case strings.HasPrefix(marker, sectionPrefix):
+			msg.metadata = map[string]any{"purpose": marker[len(sectionPrefix) : len(marker)-len(markerSuffix)]}
 		}
-		msg.role = ai.Role(str[m[0]+len(rolePrefix) : m[1]-len(roleSuffix)])
 		i = m[1]
 	}
 	if i < len(str) {
@@ -161,8 +228,11 @@ func (p *Prompt) toMessages(str string) ([]*ai.Message, error) {
 	aiMsgs := make([]*ai.Message, 0, len(msgs))
 	for _, msg := range msgs {
 		aiMsg := &ai.Message{
-			Role:    msg.role,
-			Content: p.toParts(msg.source),
+			Role:     msg.role,
+			Metadata: msg.metadata,
+		}
+		if msg.source != "" {
+			aiMsg.Content = p.toParts(msg.source)
 		}
 		aiMsgs = append(aiMsgs, aiMsg)
 	}
@@ -170,6 +240,35 @@ func (p *Prompt) toMessages(str string) ([]*ai.Message, error) {
 	return aiMsgs, nil
 }
 
+// insertHistory replaces the history placeholder in msgs with history.
+// If there is no placeholder, history goes before the last message if
+// it is a user message, and at the end otherwise. The history messages
+// have "history" as the "purpose" in their metadata.
+func insertHistory(msgs, history []*ai.Message) []*ai.Message {
+	var hist []*ai.Message
+	for _, m := range history {
+		hm := *m
+		hm.Metadata = maps.Clone(m.Metadata)
+		if hm.Metadata == nil {
+			hm.Metadata = map[string]any{}
+		}
+		hm.Metadata["purpose"] = "history"
+		hist = append(hist, &hm)
+	}
+	for i, m := range msgs {
+		if m.Metadata["purpose"] == "history" && m.Metadata["pending"] == true {
+			return slices.Concat(msgs[:i], hist, msgs[i+1:])
+		}
+	}
+	if len(hist) == 0 {
+		return msgs
+	}
+	if n := len(msgs); n > 0 && msgs[n-1].Role == ai.RoleUser {
+		return slices.Concat(msgs[:n-1], hist, msgs[n-1:])
+	}
+	return slices.Concat(msgs, hist)
+}
+
 // toParts builds the parts of a message based on a magic regexp.
 // This implements the "media" dotprompt helper function.
 func (p *Prompt) toParts(str string) []*ai.Part {
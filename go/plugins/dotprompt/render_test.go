@@ -211,6 +211,26 @@ func TestRenderMessages(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:     "allow sections and drop history",
+			template: `{{role "system"}}Be brief.{{history}}{{section "context"}}Some facts.{{section "question"}}Why?`,
+			want: []*ai.Message{
+				{
+					Role:    ai.RoleSystem,
+					Content: []*ai.Part{ai.NewTextPart("Be brief.")},
+				},
+				{
+					Role:     ai.RoleUser,
+					Content:  []*ai.Part{ai.NewTextPart("Some facts.")},
+					Metadata: map[string]any{"purpose": "context"},
+				},
+				{
+					Role:     ai.RoleUser,
+					Content:  []*ai.Part{ai.NewTextPart("Why?")},
+					Metadata: map[string]any{"purpose": "question"},
+				},
+			},
+		},
 	}
 
 	cmpPart := func(a, b *ai.Part) bool {
@@ -0,0 +1,267 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evalgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/google/go-cmp/cmp"
+)
+
+// passageRx matches the first word of each passage in a prompt.
+var passageRx = regexp.MustCompile(`Passage \d+:\n(\w+)`)
+
+// defineModel defines a model that asks about the first word of each
+// passage, or answers with the result of respond if it is not nil.
+func defineModel(g *genkit.Genkit, name string, calls *int, respond func(string) string) ai.Model {
+	return genkit.DefineModel(g, "test", name, nil,
+		func(ctx context.Context, req *ai.ModelRequest, _ ai.ModelStreamingCallback) (*ai.ModelResponse, error) {
+			*calls++
+			prompt := req.Messages[len(req.Messages)-1].Content[0].Text
+			var answer string
+			if respond != nil {
+				answer = respond(prompt)
+			} else {
+				var subjects []string
+				for _, m := range passageRx.FindAllStringSubmatch(prompt, -1) {
+					subjects = append(subjects, m[1])
+				}
+				answer = fmt.Sprintf(`{"question": "What about %s?", "answer": "%s."}`,
+					strings.Join(subjects, " and "), strings.Join(subjects, ", "))
+			}
+			return &ai.ModelResponse{Request: req, Message: &ai.Message{Role: ai.RoleModel, Content: []*ai.Part{ai.NewTextPart(answer)}}}, nil
+		})
+}
+
+var chunks = []*ai.Document{
+	ai.DocumentFromText("alpha is the first letter of the Greek alphabet.", nil),
+	ai.DocumentFromText("beta is the second letter of the Greek alphabet.", nil),
+	ai.DocumentFromText("short", nil),
+	ai.DocumentFromText("gamma is the third letter of the Greek alphabet.", nil),
+}
+
+func TestGenerate(t *testing.T) {
+	ctx := context.Background()
+	g, err := genkit.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls int
+	gen, err := New(g, Config{
+		Model:          defineModel(g, "asker", &calls, nil),
+		Size:           4,
+		Difficulties:   []Difficulty{Easy, Hard},
+		Types:          []QuestionType{Factual, MultiContext},
+		MinChunkLength: 10,
+		Rand:           rand.New(rand.NewPCG(1, 2)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases, err := gen.Generate(ctx, chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type kind struct {
+		Difficulty Difficulty
+		Type       QuestionType
+		Passages   int
+	}
+	var got []kind
+	for _, c := range cases {
+		got = append(got, kind{c.Difficulty, c.Type, len(c.Context)})
+		for _, p := range c.Context {
+			if p == "short" {
+				t.Errorf("%q: the short chunk was sampled", c.Input)
+			}
+			if !strings.Contains(c.Input, strings.Fields(p)[0]) {
+				t.Errorf("question %q does not match its context %q", c.Input, c.Context)
+			}
+		}
+		if c.TestCaseID != testCaseID(c.Input) {
+			t.Errorf("%q: got test case ID %q", c.Input, c.TestCaseID)
+		}
+	}
+	want := []kind{
+		{Easy, Factual, 1},
+		{Hard, Factual, 1},
+		{Easy, MultiContext, 2},
+		{Hard, MultiContext, 2},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+	if calls != 4 {
+		t.Errorf("got %d model calls, want 4", calls)
+	}
+}
+
+func TestGenerateSkipsDuplicatesAndUnanswerable(t *testing.T) {
+	ctx := context.Background()
+	g, err := genkit.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls int
+	answers := []string{
+		`{"question": "What is the first letter of the Greek alphabet?", "answer": "Alpha."}`,
+		`{"question": "", "answer": ""}`,
+		`{"question": "what is the FIRST letter of the Greek alphabet", "answer": "Alpha."}`,
+		`{"question": "Which letter comes second in the Greek alphabet?", "answer": "Beta."}`,
+	}
+	model := defineModel(g, "repeater", &calls, func(string) string {
+		return answers[(calls-1)%len(answers)]
+	})
+	gen, err := New(g, Config{Model: model, Size: 3, MaxAttempts: 5, MinChunkLength: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases, err := gen.Generate(ctx, chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range cases {
+		got = append(got, c.Input)
+	}
+	want := []string{
+		"What is the first letter of the Greek alphabet?",
+		"Which letter comes second in the Greek alphabet?",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+	if calls != 5 {
+		t.Errorf("got %d model calls, want 5", calls)
+	}
+}
+
+func TestGenerateFrom(t *testing.T) {
+	ctx := context.Background()
+	g, err := genkit.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := map[string][]*ai.Document{
+		"first": {chunks[0], chunks[1]},
+		"last":  {chunks[1], chunks[3]},
+		"never": {chunks[2]},
+	}
+	var queries []string
+	r := genkit.DefineRetriever(g, "test", "letters", func(ctx context.Context, req *ai.RetrieverRequest) (*ai.RetrieverResponse, error) {
+		q := req.Document.Text()
+		queries = append(queries, q)
+		return &ai.RetrieverResponse{Documents: results[q]}, nil
+	})
+	var calls int
+	gen, err := New(g, Config{
+		Model:          defineModel(g, "asker", &calls, nil),
+		Size:           3,
+		Types:          []QuestionType{Factual},
+		MinChunkLength: 10,
+		// Two attempts can use at most four chunks.
+		MaxAttempts: 2,
+		Rand:        rand.New(rand.NewPCG(1, 2)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases, err := gen.GenerateFrom(ctx, RetrieverSource(r, []string{"first", "last", "never", "never"}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) != 2 {
+		t.Fatalf("got %d test cases, want 2", len(cases))
+	}
+	if diff := cmp.Diff([]string{"first", "last", "never"}, queries); diff != "" {
+		t.Errorf("queries mismatch (-want, +got):\n%s", diff)
+	}
+
+	// The source stops retrieving once it has enough chunks.
+	queries = nil
+	docs, err := RetrieverSource(r, []string{"first", "last"}, nil).Sample(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 || len(queries) != 1 {
+		t.Errorf("got %d chunks from %d queries, want 2 from 1", len(docs), len(queries))
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	g, err := genkit.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls int
+	model := defineModel(g, "m", &calls, nil)
+	for _, cfg := range []Config{
+		{},
+		{Model: model, Size: -1},
+		{Model: model, Similarity: 2},
+		{Model: model, Difficulties: []Difficulty{"impossible"}},
+		{Model: model, Types: []QuestionType{"riddle"}},
+	} {
+		if _, err := New(g, cfg); err == nil {
+			t.Errorf("%+v: got nil, want error", cfg)
+		}
+	}
+	gen, err := New(g, Config{Model: model, Types: []QuestionType{MultiContext}, MinChunkLength: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gen.Generate(context.Background(), chunks[:1]); err == nil {
+		t.Error("multi-context questions from one chunk: got nil, want error")
+	}
+}
+
+func TestWriteDataset(t *testing.T) {
+	cases := []*TestCase{{
+		TestCaseID: "id",
+		Input:      "What is alpha?",
+		Reference:  "A letter.",
+		Context:    []string{"alpha is a letter."},
+		Difficulty: Easy,
+		Type:       Factual,
+	}}
+	var buf bytes.Buffer
+	if err := WriteDataset(&buf, cases); err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]any{{
+		"testCaseId": "id",
+		"input":      "What is alpha?",
+		"reference":  "A letter.",
+		"context":    []any{"alpha is a letter."},
+		"difficulty": "easy",
+		"type":       "factual",
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
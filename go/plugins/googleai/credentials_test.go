@@ -0,0 +1,82 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googleai
+
+import (
+	"context"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/plugins/internal/clientcache"
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestClientFor(t *testing.T) {
+	defaultClient := &genai.Client{}
+	saved := state.gclient
+	state.gclient = defaultClient
+	state.clients = clientcache.New(2, closeClient)
+	defer func() {
+		state.gclient = saved
+		state.clients = nil
+	}()
+
+	tenantKey := func(key string) context.Context {
+		return ai.WithCredentialProvider(context.Background(), func(_ context.Context, p string) (*ai.Credentials, error) {
+			if p != provider {
+				t.Errorf("got provider %q, want %q", p, provider)
+			}
+			return &ai.Credentials{APIKey: key}, nil
+		})
+	}
+	get := func(ctx context.Context) *genai.Client {
+		t.Helper()
+		c, release, err := clientFor(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+		return c
+	}
+
+	if got := get(context.Background()); got != defaultClient {
+		t.Error("without credentials, did not get the default client")
+	}
+	a := get(tenantKey("a"))
+	if a == defaultClient {
+		t.Fatal("with credentials, got the default client")
+	}
+	if got := get(tenantKey("a")); got != a {
+		t.Error("same credentials did not reuse the client")
+	}
+	if got := get(tenantKey("b")); got == a {
+		t.Error("different credentials shared a client")
+	}
+	if n := state.clients.Len(); n != 2 {
+		t.Errorf("got %d cached clients, want 2", n)
+	}
+
+	// After the API key is rotated, calls without credentials use the new key.
+	rotated := "rotated"
+	state.rotatedKey.Store(&rotated)
+	defer state.rotatedKey.Store(nil)
+	r := get(context.Background())
+	if r == defaultClient || r == a {
+		t.Error("after rotation, did not get a client for the new key")
+	}
+	if got := get(context.Background()); got != r {
+		t.Error("after rotation, the client for the new key was not reused")
+	}
+}
@@ -20,16 +20,20 @@ package googleai
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/genkit"
 	"github.com/firebase/genkit/go/internal"
+	"github.com/firebase/genkit/go/plugins/internal/clientcache"
 	"github.com/firebase/genkit/go/plugins/internal/gemini"
 	"github.com/firebase/genkit/go/plugins/internal/uri"
+	"github.com/firebase/genkit/go/plugins/ratelimit"
+	"github.com/firebase/genkit/go/plugins/secrets"
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
@@ -45,6 +49,13 @@ var state struct {
 	initted bool
 	// These happen to be the same.
 	gclient, pclient *genai.Client
+	// Clients for per-request credentials, and the options used to make them.
+	clients    *clientcache.Cache[*genai.Client]
+	clientOpts []option.ClientOption
+	// Quotas of models, from Config.RateLimits.
+	limits map[string]ratelimit.Limits
+	// The API key after a rotation, used in place of gclient's key.
+	rotatedKey atomic.Pointer[string]
 }
 
 var (
@@ -60,11 +71,23 @@ var (
 // Config is the configuration for the plugin.
 type Config struct {
 	// The API key to access the service.
-	// If empty, the values of the environment variables GOOGLE_GENAI_API_KEY
-	// and GOOGLE_API_KEY will be consulted, in that order.
+	// If empty, the secrets GOOGLE_GENAI_API_KEY and GOOGLE_API_KEY will be
+	// looked up in Secrets, in that order.
 	APIKey string
+	// Secrets provides the API key if APIKey is empty.
+	// The default is [secrets.Env].
+	// If it is a [secrets.Watcher], such as a [secrets.Refresher], the
+	// plugin switches to a new key when it is rotated.
+	Secrets secrets.Provider
 	// Options to the Google AI client.
 	ClientOptions []option.ClientOption
+	// The maximum number of clients kept for per-request credentials
+	// (see [ai.CredentialProvider]). The default is 16.
+	MaxClients int
+	// RateLimits holds the quota of each model, by model name.
+	// Calls to a model with limits wait until the quota allows them,
+	// and are retried when the service rejects them for exceeding it.
+	RateLimits map[string]ratelimit.Limits
 }
 
 // Init initializes the plugin and all known models and embedders.
@@ -87,13 +110,21 @@ func Init(ctx context.Context, g *genkit.Genkit, cfg *Config) (err error) {
 
 	apiKey := cfg.APIKey
 	if apiKey == "" {
-		apiKey = os.Getenv("GOOGLE_GENAI_API_KEY")
-		if apiKey == "" {
-			apiKey = os.Getenv("GOOGLE_API_KEY")
+		sp := cfg.Secrets
+		if sp == nil {
+			sp = secrets.Env()
 		}
-		if apiKey == "" {
+		var name string
+		name, apiKey, err = secrets.Lookup(ctx, sp, "GOOGLE_GENAI_API_KEY", "GOOGLE_API_KEY")
+		if errors.Is(err, secrets.ErrNotFound) {
 			return fmt.Errorf("Google AI requires setting GOOGLE_GENAI_API_KEY or GOOGLE_API_KEY in the environment. You can get an API key at https://ai.google.dev")
 		}
+		if err != nil {
+			return err
+		}
+		if w, ok := sp.(secrets.Watcher); ok {
+			w.Watch(name, func(key string) { state.rotatedKey.Store(&key) })
+		}
 	}
 
 	opts := append([]option.ClientOption{
@@ -107,6 +138,9 @@ func Init(ctx context.Context, g *genkit.Genkit, cfg *Config) (err error) {
 	}
 	state.gclient = client
 	state.pclient = client
+	state.clientOpts = cfg.ClientOptions
+	state.clients = clientcache.New(cfg.MaxClients, closeClient)
+	state.limits = cfg.RateLimits
 	state.initted = true
 	for model, caps := range knownCaps {
 		defineModel(g, model, caps)
@@ -114,6 +148,24 @@ func Init(ctx context.Context, g *genkit.Genkit, cfg *Config) (err error) {
 	for _, e := range knownEmbedders {
 		defineEmbedder(g, e)
 	}
+	genkit.RegisterHealthChecker(g, provider, genkit.HealthCheckerFunc(checkHealth))
+	genkit.RegisterShutdownHook(g, provider, func(context.Context) error {
+		state.clients.Purge()
+		return client.Close()
+	})
+	return nil
+}
+
+// checkHealth verifies that the service accepts the API key.
+func checkHealth(ctx context.Context) error {
+	client, release, err := clientFor(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if _, err := client.ListModels(ctx).Next(); err != nil && err != iterator.Done {
+		return fmt.Errorf("listing models: %w", err)
+	}
 	return nil
 }
 
@@ -148,13 +200,19 @@ func defineModel(g *genkit.Genkit, name string, caps ai.ModelCapabilities) ai.Mo
 		Label:    labelPrefix + " - " + name,
 		Supports: caps,
 	}
-	return genkit.DefineModel(g, provider, name, meta, func(
+	gen := func(
 		ctx context.Context,
 		input *ai.ModelRequest,
 		cb func(context.Context, *ai.ModelResponseChunk) error,
 	) (*ai.ModelResponse, error) {
-		return generate(ctx, state.gclient, name, input, cb)
-	})
+		client, release, err := clientFor(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return generate(ctx, client, name, input, cb)
+	}
+	return genkit.DefineModel(g, provider, name, meta, ratelimit.ForModel(state.limits, name, gen))
 }
 
 // IsDefinedModel reports whether the named [Model] is defined by this plugin.
@@ -183,10 +241,53 @@ func IsDefinedEmbedder(g *genkit.Genkit, name string) bool {
 
 //copy:stop
 
+//copy:start vertexai.go defineTranscriber
+
+// DefineTranscriber defines a transcriber that uses the named Gemini model
+// to convert speech to text. The model must accept audio input.
+// The transcriber has the same name as the model.
+func DefineTranscriber(g *genkit.Genkit, name string) ai.Transcriber {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if !state.initted {
+		panic(provider + ".Init not called")
+	}
+	return genkit.DefineTranscriber(g, provider, name, func(ctx context.Context, req *ai.TranscribeRequest) (*ai.TranscribeResponse, error) {
+		client, release, err := clientFor(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		resp, err := generate(ctx, client, name, gemini.TranscribeModelRequest(req), nil)
+		if err != nil {
+			return nil, err
+		}
+		return gemini.TranscribeResponse(req, resp)
+	})
+}
+
+// IsDefinedTranscriber reports whether the named [Transcriber] is defined by this plugin.
+func IsDefinedTranscriber(g *genkit.Genkit, name string) bool {
+	return genkit.IsDefinedTranscriber(g, provider, name)
+}
+
+// Transcriber returns the [ai.Transcriber] with the given name.
+// It returns nil if the transcriber was not defined.
+func Transcriber(g *genkit.Genkit, name string) ai.Transcriber {
+	return genkit.LookupTranscriber(g, provider, name)
+}
+
+//copy:stop
+
 // requires state.mu
 func defineEmbedder(g *genkit.Genkit, name string) ai.Embedder {
 	return genkit.DefineEmbedder(g, provider, name, func(ctx context.Context, input *ai.EmbedRequest) (*ai.EmbedResponse, error) {
-		em := state.pclient.EmbeddingModel(name)
+		client, release, err := clientFor(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		em := client.EmbeddingModel(name)
 		// TODO: set em.TaskType from EmbedRequest.Options?
 		batch := em.NewBatch()
 		for _, doc := range input.Documents {
@@ -208,6 +309,35 @@ func defineEmbedder(g *genkit.Genkit, name string) ai.Embedder {
 	})
 }
 
+// clientFor returns the client for a call in ctx: a client for the
+// per-request credentials in ctx, if there are any, or for the rotated API
+// key, or else the client created by [Init]. The caller must call release when done with the client.
+func clientFor(ctx context.Context) (client *genai.Client, release func(), err error) {
+	creds, err := ai.CredentialsFor(ctx, provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	if creds == nil {
+		key := state.rotatedKey.Load()
+		if key == nil {
+			return state.gclient, func() {}, nil
+		}
+		creds = &ai.Credentials{APIKey: *key}
+	}
+	return state.clients.Get(ctx, creds.Key(), func(ctx context.Context) (*genai.Client, error) {
+		opts := append([]option.ClientOption{genai.WithClientInfo("genkit-go", internal.Version)}, state.clientOpts...)
+		if creds.APIKey != "" {
+			opts = append(opts, option.WithAPIKey(creds.APIKey))
+		}
+		if creds.Endpoint != "" {
+			opts = append(opts, option.WithEndpoint(creds.Endpoint))
+		}
+		return genai.NewClient(ctx, opts...)
+	})
+}
+
+func closeClient(c *genai.Client) { c.Close() }
+
 //copy:start vertexai.go lookups
 
 // Model returns the [ai.Model] with the given name.
@@ -307,6 +437,10 @@ func newModel(client *genai.Client, model string, input *ai.ModelRequest) (*gena
 	gm := client.GenerativeModel(model)
 	gm.SetCandidateCount(1)
 	if c, ok := input.Config.(*ai.GenerationCommonConfig); ok && c != nil {
+		// The Gemini SDK has no way to set a seed.
+		if c.Seed != 0 {
+			return nil, &ai.UnsupportedConfigError{Model: provider + "/" + model, Option: "seed"}
+		}
 		if c.MaxOutputTokens != 0 {
 			gm.SetMaxOutputTokens(int32(c.MaxOutputTokens))
 		}
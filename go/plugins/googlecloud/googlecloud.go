@@ -51,6 +51,10 @@ type Config struct {
 	// The minimum level at which logs will be written.
 	// Defaults to [slog.LevelInfo].
 	LogLevel slog.Leveler
+
+	// If non-nil, the inputs and outputs of models are written to the log.
+	// They are never exported with traces.
+	ModelIO *ModelIOConfig
 }
 
 // Init initializes all telemetry in this package.
@@ -76,21 +80,33 @@ func Init(ctx context.Context, g *genkit.Genkit, cfg Config) (err error) {
 	}
 	aexp := &adjustingTraceExporter{texp}
 	genkit.RegisterSpanProcessor(g, sdktrace.NewBatchSpanProcessor(aexp))
-	if err := setMeterProvider(cfg.ProjectID, cfg.MetricInterval); err != nil {
+	mp, err := setMeterProvider(cfg.ProjectID, cfg.MetricInterval)
+	if err != nil {
+		return err
+	}
+	// Exports the final metrics.
+	genkit.RegisterShutdownHook(g, "googlecloud/metrics", mp.Shutdown)
+	lc, logger, err := setLogHandler(cfg.ProjectID, cfg.LogLevel)
+	if err != nil {
 		return err
 	}
-	return setLogHandler(cfg.ProjectID, cfg.LogLevel)
+	if cfg.ModelIO != nil {
+		genkit.RegisterSpanProcessor(g, newModelIOLogger(*cfg.ModelIO, logger))
+	}
+	// Closing the client flushes buffered log entries.
+	genkit.RegisterShutdownHook(g, "googlecloud/logging", func(context.Context) error { return lc.Close() })
+	return nil
 }
 
-func setMeterProvider(projectID string, interval time.Duration) error {
+func setMeterProvider(projectID string, interval time.Duration) (*sdkmetric.MeterProvider, error) {
 	mexp, err := mexporter.New(mexporter.WithProjectID(projectID))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	r := sdkmetric.NewPeriodicReader(mexp, sdkmetric.WithInterval(interval))
 	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))
 	otel.SetMeterProvider(mp)
-	return nil
+	return mp, nil
 }
 
 type adjustingTraceExporter struct {
@@ -129,12 +145,12 @@ func (s adjustedSpan) Attributes() []attribute.KeyValue {
 	return ts
 }
 
-func setLogHandler(projectID string, level slog.Leveler) error {
+func setLogHandler(projectID string, level slog.Leveler) (*logging.Client, *slog.Logger, error) {
 	c, err := logging.NewClient(context.Background(), "projects/"+projectID)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	logger := c.Logger("genkit_log")
-	slog.SetDefault(slog.New(newHandler(level, logger.Log)))
-	return nil
+	logger := slog.New(newHandler(level, projectID, c.Logger("genkit_log").Log))
+	slog.SetDefault(logger)
+	return c, logger, nil
 }
@@ -59,7 +59,8 @@ func TestGCP(t *testing.T) {
 	})
 	t.Run("metrics", func(t *testing.T) {
 		ctx := context.Background()
-		if err := setMeterProvider(*projectID, 1*time.Second); err != nil {
+		mp, err := setMeterProvider(*projectID, 1*time.Second)
+		if err != nil {
 			t.Fatal(err)
 		}
 		c, err := otel.Meter("genkit-test").Int64Counter("test")
@@ -67,17 +68,22 @@ func TestGCP(t *testing.T) {
 			t.Fatal(err)
 		}
 		c.Add(ctx, 100)
-		// Allow time to sample and export.
-		time.Sleep(2 * time.Second)
+		// Shutting down exports the final sample.
+		if err := mp.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
 	})
 	t.Run("logging", func(t *testing.T) {
-		if err := setLogHandler(*projectID, slog.LevelInfo); err != nil {
+		c, _, err := setLogHandler(*projectID, slog.LevelInfo)
+		if err != nil {
 			t.Fatal(err)
 		}
 		slog.Debug("testing GCP logging",
 			"binaryName", os.Args[0],
 			"goVersion", runtime.Version())
-		// Allow time to export.
-		time.Sleep(2 * time.Second)
+		// Closing the client flushes the log entry.
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
 	})
 }
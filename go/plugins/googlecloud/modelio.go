@@ -0,0 +1,120 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googlecloud
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"unicode/utf8"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ModelIOConfig configures the logging of model inputs and outputs.
+//
+// Each logged model call produces two log entries, one for the request and
+// one for the response, carrying the model name, the span path, and the
+// trace and span IDs of the call, so Cloud Logging shows them with its trace.
+// The entries have level Info, or Error for a failed call, so they are
+// dropped if [Config.LogLevel] is higher.
+// Inputs and outputs may contain personal or confidential data; use Redact
+// to remove it.
+type ModelIOConfig struct {
+	// The maximum length in bytes of a logged input or output.
+	// Longer ones are truncated. The default is 32 KiB.
+	MaxLength int
+	// The fraction of model calls to log, between 0 and 1.
+	// The default, 0, logs every call.
+	SampleRate float64
+	// If non-nil, Redact is called on the JSON of each input and output
+	// before it is truncated and logged, and its result is logged instead.
+	Redact func(model, text string) string
+}
+
+const defaultModelIOMaxLength = 32 << 10
+
+// A modelIOLogger is a SpanProcessor that logs the inputs and outputs
+// of model actions when their spans end.
+type modelIOLogger struct {
+	cfg    ModelIOConfig
+	logger *slog.Logger
+	// sample returns a pseudo-random number in [0, 1).
+	sample func() float64
+}
+
+func newModelIOLogger(cfg ModelIOConfig, logger *slog.Logger) *modelIOLogger {
+	if cfg.MaxLength <= 0 {
+		cfg.MaxLength = defaultModelIOMaxLength
+	}
+	if cfg.SampleRate <= 0 || cfg.SampleRate > 1 {
+		cfg.SampleRate = 1
+	}
+	return &modelIOLogger{cfg: cfg, logger: logger, sample: rand.Float64}
+}
+
+func (*modelIOLogger) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (l *modelIOLogger) OnEnd(s sdktrace.ReadOnlySpan) {
+	attrs := map[string]string{}
+	for _, a := range s.Attributes() {
+		attrs[string(a.Key)] = a.Value.Emit()
+	}
+	if attrs["genkit:metadata:subtype"] != "model" {
+		return
+	}
+	if l.sample() >= l.cfg.SampleRate {
+		return
+	}
+	model := attrs["genkit:name"]
+	path := attrs["genkit:path"]
+	// Log in the context of the span, so the handler can add the trace.
+	ctx := trace.ContextWithSpanContext(context.Background(), s.SpanContext())
+	l.log(ctx, slog.LevelInfo, "Input", model, path, "input", attrs["genkit:input"])
+	level := slog.LevelInfo
+	if attrs["genkit:state"] == "error" {
+		level = slog.LevelError
+	}
+	l.log(ctx, level, "Output", model, path, "output", attrs["genkit:output"])
+}
+
+// log writes an entry for the input or output text of a call to model.
+func (l *modelIOLogger) log(ctx context.Context, level slog.Level, msg, model, path, key, text string) {
+	if l.cfg.Redact != nil {
+		text = l.cfg.Redact(model, text)
+	}
+	text, truncated := truncate(text, l.cfg.MaxLength)
+	l.logger.LogAttrs(ctx, level, msg+"["+path+", "+model+"]",
+		slog.String("model", model),
+		slog.String("path", path),
+		slog.String(key, text),
+		slog.Bool("truncated", truncated))
+}
+
+func (*modelIOLogger) Shutdown(context.Context) error   { return nil }
+func (*modelIOLogger) ForceFlush(context.Context) error { return nil }
+
+// truncate returns the longest prefix of s that has at most n bytes
+// and does not split a rune, and whether it is shorter than s.
+func truncate(s string, n int) (string, bool) {
+	if len(s) <= n {
+		return s, false
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n], true
+}
@@ -0,0 +1,113 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googlecloud
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/logging"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestModelIOLogger(t *testing.T) {
+	var entries []logging.Entry
+	logger := slog.New(newHandler(slog.LevelInfo, "project", func(e logging.Entry) {
+		entries = append(entries, e)
+	}))
+	ml := newModelIOLogger(ModelIOConfig{
+		MaxLength:  12,
+		SampleRate: 0.5,
+		Redact: func(model, text string) string {
+			return strings.ReplaceAll(text, "secret", "******")
+		},
+	}, logger)
+	samples := []float64{0.2, 0.7}
+	ml.sample = func() float64 {
+		s := samples[0]
+		samples = samples[1:]
+		return s
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(ml))
+	tracer := tp.Tracer("test")
+
+	call := func(subtype, state string) {
+		_, span := tracer.Start(context.Background(), "call")
+		span.SetAttributes(
+			attribute.String("genkit:name", "test/model"),
+			attribute.String("genkit:path", "/flow/test/model"),
+			attribute.String("genkit:state", state),
+			attribute.String("genkit:input", `{"text":"my secret"}`),
+			attribute.String("genkit:output", `"ok"`),
+			attribute.String("genkit:metadata:subtype", subtype),
+		)
+		span.End()
+	}
+	call("model", "success") // sampled
+	call("model", "error")   // not sampled
+	call("tool", "success")  // not a model
+	if len(samples) != 0 {
+		t.Errorf("%d samples unused", len(samples))
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	in, out := entries[0], entries[1]
+	want := map[string]any{
+		"msg":       "Input[/flow/test/model, test/model]",
+		"model":     "test/model",
+		"path":      "/flow/test/model",
+		"input":     `{"text":"my `,
+		"truncated": true,
+	}
+	for k, v := range want {
+		if got := in.Payload.(map[string]any)[k]; got != v {
+			t.Errorf("input %s: got %v, want %v", k, got, v)
+		}
+	}
+	if got := out.Payload.(map[string]any)["output"]; got != `"ok"` {
+		t.Errorf("got output %v, want %q", got, `"ok"`)
+	}
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Trace, "projects/project/traces/") || e.SpanID == "" || !e.TraceSampled {
+			t.Errorf("entry not correlated with trace: Trace=%q SpanID=%q TraceSampled=%t", e.Trace, e.SpanID, e.TraceSampled)
+		}
+	}
+	if in.Trace != out.Trace || in.SpanID != out.SpanID {
+		t.Error("input and output entries have different spans")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"abc", 3, "abc"},
+		{"abcd", 3, "abc"},
+		{"aé", 2, "a"}, // does not split é
+		{"aé", 3, "aé"},
+	} {
+		got, truncated := truncate(test.in, test.n)
+		if got != test.want || truncated != (got != test.in) {
+			t.Errorf("truncate(%q, %d) = %q, %t, want %q", test.in, test.n, got, truncated, test.want)
+		}
+	}
+}
@@ -22,20 +22,23 @@ import (
 
 	"cloud.google.com/go/logging"
 	"github.com/jba/slog/withsupport"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func newHandler(level slog.Leveler, f func(logging.Entry)) *handler {
+func newHandler(level slog.Leveler, projectID string, f func(logging.Entry)) *handler {
 	if level == nil {
 		level = slog.LevelInfo
 	}
 	return &handler{
 		level:       level,
+		projectID:   projectID,
 		handleEntry: f,
 	}
 }
 
 type handler struct {
 	level       slog.Leveler
+	projectID   string // for the trace names of entries
 	handleEntry func(logging.Entry)
 	goa         *withsupport.GroupOrAttrs
 }
@@ -62,18 +65,21 @@ func (h *handler) Handle(ctx context.Context, r slog.Record) error {
 }
 
 func (h *handler) recordToEntry(ctx context.Context, r slog.Record) logging.Entry {
-	return logging.Entry{
+	e := logging.Entry{
 		Timestamp: r.Time,
 		Severity:  levelToSeverity(r.Level),
 		Payload:   recordToMap(r, h.goa.Collect()),
 		Labels:    map[string]string{"module": "genkit"},
 		// TODO: add a monitored resource
 		// Resource:       &monitoredres.MonitoredResource{},
-		// TODO: add trace information from the context.
-		// Trace:        "",
-		// SpanID:       "",
-		// TraceSampled: false,
 	}
+	// Correlate the entry with the trace of the span in ctx, if any.
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		e.Trace = "projects/" + h.projectID + "/traces/" + sc.TraceID().String()
+		e.SpanID = sc.SpanID().String()
+		e.TraceSampled = sc.IsSampled()
+	}
+	return e
 }
 
 func levelToSeverity(l slog.Level) logging.Severity {
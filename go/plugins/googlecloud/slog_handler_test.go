@@ -31,7 +31,7 @@ func TestHandler(t *testing.T) {
 		results = append(results, entryToMap(e))
 	}
 
-	if err := slogtest.TestHandler(newHandler(slog.LevelInfo, f), func() []map[string]any { return results }); err != nil {
+	if err := slogtest.TestHandler(newHandler(slog.LevelInfo, "project", f), func() []map[string]any { return results }); err != nil {
 		t.Fatal(err)
 	}
 }
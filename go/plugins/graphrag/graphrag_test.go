@@ -0,0 +1,222 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphrag
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/google/go-cmp/cmp"
+)
+
+// extractions are the fake model's responses, by prompt.
+var extractions = map[string]string{
+	"Ada works at Acme.": `{
+		"entities": [
+			{"name": "Ada", "type": "person", "description": "An engineer."},
+			{"name": "Acme", "type": "organization"}
+		],
+		"relations": [{"source": "Ada", "target": "Acme", "type": "Works For"}]
+	}`,
+	"Acme is headquartered in Zurich.": `{
+		"entities": [{"name": "Acme", "type": "organization", "description": "A company."}],
+		"relations": [{"source": "Acme", "target": " Zurich ", "type": "located in", "description": "Acme's headquarters."}]
+	}`,
+	"Zurich lies on Lake Zurich.": `{
+		"entities": [{"name": "Zurich", "type": "city"}, {"name": "Lake Zurich", "type": "lake"}],
+		"relations": [{"source": "Zurich", "target": "Lake Zurich", "type": "located on"}]
+	}`,
+	"Bob likes tea.": `{
+		"entities": [{"name": "Bob", "type": "person"}, {"name": "tea", "type": "drink"}],
+		"relations": [{"source": "Bob", "target": "tea", "type": "likes"}]
+	}`,
+	"Where does ada work?": `{"entities": ["ada"]}`,
+}
+
+func newTestGraph(t *testing.T, store Store) (ai.Indexer, ai.Retriever) {
+	t.Helper()
+	g, err := genkit.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model := genkit.DefineModel(g, "test", "extractor", nil,
+		func(ctx context.Context, req *ai.ModelRequest, _ ai.ModelStreamingCallback) (*ai.ModelResponse, error) {
+			prompt := strings.TrimSpace(req.Messages[len(req.Messages)-1].Content[0].Text)
+			answer, ok := extractions[prompt]
+			if !ok {
+				answer = `{"entities": [], "relations": []}`
+			}
+			return &ai.ModelResponse{Request: req, Message: &ai.Message{Role: ai.RoleModel, Content: []*ai.Part{ai.NewTextPart(answer)}}}, nil
+		})
+	indexer, retriever, err := DefineIndexerAndRetriever(g, "test", Config{Store: store, Model: model})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return indexer, retriever
+}
+
+var corpus = []*ai.Document{
+	ai.DocumentFromText("Ada works at Acme.", map[string]any{"id": "ada"}),
+	ai.DocumentFromText("Acme is headquartered in Zurich.", map[string]any{"id": "acme"}),
+	ai.DocumentFromText("Zurich lies on Lake Zurich.", map[string]any{"id": "zurich"}),
+	ai.DocumentFromText("Bob likes tea.", map[string]any{"id": "bob"}),
+}
+
+func TestRetrieve(t *testing.T) {
+	ctx := context.Background()
+	indexer, retriever := newTestGraph(t, &MemoryStore{})
+	if err := indexer.Index(ctx, &ai.IndexerRequest{Documents: corpus}); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name          string
+		opts          *RetrieverOptions
+		wantRelations string
+		wantChunks    []string
+	}{
+		{
+			name: "default",
+			wantRelations: "Ada -[works for]-> Acme\n" +
+				"Acme -[located in]-> Zurich: Acme's headquarters.\n",
+			wantChunks: []string{"ada", "acme", "zurich"},
+		},
+		{
+			name:          "depth 1",
+			opts:          &RetrieverOptions{Depth: 1},
+			wantRelations: "Ada -[works for]-> Acme\n",
+			wantChunks:    []string{"ada", "acme"},
+		},
+		{
+			name:          "given entities",
+			opts:          &RetrieverOptions{Entities: []string{"TEA", "nobody"}},
+			wantRelations: "Bob -[likes]-> tea\n",
+			wantChunks:    []string{"bob"},
+		},
+		{
+			name: "max chunks",
+			opts: &RetrieverOptions{MaxChunks: 1},
+			wantRelations: "Ada -[works for]-> Acme\n" +
+				"Acme -[located in]-> Zurich: Acme's headquarters.\n",
+			wantChunks: []string{"ada"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			// In the default case, the options are a nil *RetrieverOptions.
+			req := &ai.RetrieverRequest{Document: ai.DocumentFromText("Where does ada work?", nil), Options: test.opts}
+			resp, err := retriever.Retrieve(ctx, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			docs := resp.Documents
+			if len(docs) == 0 || docs[0].Metadata[RelationsMetadataKey] == nil {
+				t.Fatalf("got %d documents, want a relations document first", len(docs))
+			}
+			_, rels, _ := strings.Cut(docs[0].Content[0].Text, "\n")
+			if diff := cmp.Diff(test.wantRelations, rels); diff != "" {
+				t.Errorf("relations mismatch (-want, +got):\n%s", diff)
+			}
+			var got []string
+			var last float64
+			for i, d := range docs[1:] {
+				got = append(got, d.Metadata["id"].(string))
+				score := d.Metadata[ScoreMetadataKey].(float64)
+				if i > 0 && score > last {
+					t.Errorf("chunk %q scores %v, more than the one before it", got[i], score)
+				}
+				last = score
+			}
+			if diff := cmp.Diff(test.wantChunks, got); diff != "" {
+				t.Errorf("chunks mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, &MemoryStore{})
+}
+
+// testStore checks that s implements Store.
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	add := func(c *Chunk) {
+		t.Helper()
+		if err := s.Add(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add(&Chunk{
+		ID:       "c1",
+		Document: ai.DocumentFromText("Ada works at Acme.", map[string]any{"id": "c1"}),
+		Entities: []*Entity{{Name: "Ada", Type: "person"}, {Name: "Acme"}},
+		Relations: []*Relation{
+			{Source: "Ada", Target: "Acme", Type: "works for"},
+		},
+	})
+	add(&Chunk{
+		ID:       "c2",
+		Document: ai.DocumentFromText("Acme, a company, employs Ada.", nil),
+		Entities: []*Entity{{Name: "ACME", Type: "organization", Description: "A company."}, {Name: "Ada", Type: "engineer"}},
+		Relations: []*Relation{
+			{Source: "ada", Target: "acme", Type: "Works For", Description: "Ada is employed by Acme."},
+			{Source: "Acme", Target: "Acme", Type: "owns"},
+		},
+	})
+
+	e, err := s.Entity(ctx, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Entity{Name: "Acme", Type: "organization", Description: "A company.", Chunks: []string{"c1", "c2"}}
+	if diff := cmp.Diff(want, e); diff != "" {
+		t.Errorf("entity mismatch (-want, +got):\n%s", diff)
+	}
+	e, err = s.Entity(ctx, "ADA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != "person" {
+		t.Errorf("got type %q, want the first type, person", e.Type)
+	}
+	if e, err := s.Entity(ctx, "Bob"); err != nil || e != nil {
+		t.Errorf("got %v, %v for an unknown entity, want nil, nil", e, err)
+	}
+
+	rels, err := s.Relations(ctx, "Acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRels := []*Relation{
+		{Source: "Ada", Target: "Acme", Type: "works for", Description: "Ada is employed by Acme.", Chunks: []string{"c1", "c2"}},
+		{Source: "Acme", Target: "Acme", Type: "owns", Chunks: []string{"c2"}},
+	}
+	if diff := cmp.Diff(wantRels, rels); diff != "" {
+		t.Errorf("relations mismatch (-want, +got):\n%s", diff)
+	}
+
+	d, err := s.Document(ctx, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Content[0].Text != "Ada works at Acme." || d.Metadata["id"] != "c1" {
+		t.Errorf("got document %+v", d)
+	}
+	if d, err := s.Document(ctx, "c3"); err != nil || d != nil {
+		t.Errorf("got %v, %v for an unknown chunk, want nil, nil", d, err)
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphrag
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+// sqliteSchema creates the tables of a [SQLiteStore].
+// Entities are keyed by their lower-cased names, as in [MemoryStore].
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS graphrag_chunks (
+	id TEXT PRIMARY KEY,
+	document TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS graphrag_entities (
+	key TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	type TEXT NOT NULL,
+	description TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS graphrag_entity_chunks (
+	entity TEXT NOT NULL,
+	chunk TEXT NOT NULL,
+	PRIMARY KEY (entity, chunk)
+);
+CREATE TABLE IF NOT EXISTS graphrag_relations (
+	key TEXT PRIMARY KEY,
+	source_key TEXT NOT NULL,
+	target_key TEXT NOT NULL,
+	source TEXT NOT NULL,
+	target TEXT NOT NULL,
+	type TEXT NOT NULL,
+	description TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS graphrag_relations_source ON graphrag_relations (source_key);
+CREATE INDEX IF NOT EXISTS graphrag_relations_target ON graphrag_relations (target_key);
+CREATE TABLE IF NOT EXISTS graphrag_relation_chunks (
+	relation TEXT NOT NULL,
+	chunk TEXT NOT NULL,
+	PRIMARY KEY (relation, chunk)
+);
+`
+
+// SQLiteStore is a [Store] that keeps the graph in a SQLite database.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore returns a SQLiteStore that uses db, creating its tables
+// if they do not exist. The tables' names begin with "graphrag_".
+//
+// The caller opens db with a SQLite driver of their choice, such as
+// github.com/mattn/go-sqlite3 or modernc.org/sqlite.
+func NewSQLiteStore(ctx context.Context, db *sql.DB) (*SQLiteStore, error) {
+	if _, err := db.ExecContext(ctx, sqliteSchema); err != nil {
+		return nil, fmt.Errorf("graphrag: creating tables: %w", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// Add implements [Store.Add].
+func (s *SQLiteStore) Add(ctx context.Context, c *Chunk) (err error) {
+	doc, err := json.Marshal(c.Document)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO graphrag_chunks (id, document) VALUES (?, ?)
+		ON CONFLICT (id) DO UPDATE SET document = excluded.document`,
+		c.ID, string(doc)); err != nil {
+		return err
+	}
+	for _, e := range c.Entities {
+		k := key(e.Name)
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO graphrag_entities (key, name, type, description) VALUES (?, ?, ?, ?)
+			ON CONFLICT (key) DO UPDATE SET
+				type = CASE type WHEN '' THEN excluded.type ELSE type END,
+				description = CASE description WHEN '' THEN excluded.description ELSE description END`,
+			k, e.Name, e.Type, e.Description); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO graphrag_entity_chunks (entity, chunk) VALUES (?, ?)`,
+			k, c.ID); err != nil {
+			return err
+		}
+	}
+	for _, r := range c.Relations {
+		k := relationKey(r)
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO graphrag_relations (key, source_key, target_key, source, target, type, description)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (key) DO UPDATE SET
+				description = CASE description WHEN '' THEN excluded.description ELSE description END`,
+			k, key(r.Source), key(r.Target), r.Source, r.Target, r.Type, r.Description); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO graphrag_relation_chunks (relation, chunk) VALUES (?, ?)`,
+			k, c.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Entity implements [Store.Entity].
+func (s *SQLiteStore) Entity(ctx context.Context, name string) (*Entity, error) {
+	k := key(name)
+	var e Entity
+	err := s.db.QueryRowContext(ctx,
+		`SELECT name, type, description FROM graphrag_entities WHERE key = ?`, k).
+		Scan(&e.Name, &e.Type, &e.Description)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	e.Chunks, err = s.chunks(ctx,
+		`SELECT chunk FROM graphrag_entity_chunks WHERE entity = ? ORDER BY rowid`, k)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Relations implements [Store.Relations].
+func (s *SQLiteStore) Relations(ctx context.Context, name string) ([]*Relation, error) {
+	k := key(name)
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT key, source, target, type, description FROM graphrag_relations
+		WHERE source_key = ? OR target_key = ? ORDER BY rowid`, k, k)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var (
+		rels []*Relation
+		keys []string
+	)
+	for rows.Next() {
+		var (
+			r  Relation
+			rk string
+		)
+		if err := rows.Scan(&rk, &r.Source, &r.Target, &r.Type, &r.Description); err != nil {
+			return nil, err
+		}
+		rels = append(rels, &r)
+		keys = append(keys, rk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, r := range rels {
+		r.Chunks, err = s.chunks(ctx,
+			`SELECT chunk FROM graphrag_relation_chunks WHERE relation = ? ORDER BY rowid`, keys[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rels, nil
+}
+
+// Document implements [Store.Document].
+func (s *SQLiteStore) Document(ctx context.Context, id string) (*ai.Document, error) {
+	var doc string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT document FROM graphrag_chunks WHERE id = ?`, id).Scan(&doc)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var d ai.Document
+	if err := json.Unmarshal([]byte(doc), &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// chunks returns the chunk IDs selected by query.
+func (s *SQLiteStore) chunks(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,175 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphrag
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+// An Entity is a node of the graph: a person, organization, place,
+// thing or concept mentioned in the corpus.
+type Entity struct {
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Chunks are the IDs of the chunks that mention the entity.
+	Chunks []string `json:"chunks,omitempty"`
+}
+
+// A Relation is a directed edge of the graph between two entities,
+// named by their Name.
+type Relation struct {
+	Source      string `json:"source"`
+	Target      string `json:"target"`
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	// Chunks are the IDs of the chunks that state the relation.
+	Chunks []string `json:"chunks,omitempty"`
+}
+
+// A Chunk is an indexed document and the entities and relations
+// extracted from it.
+type Chunk struct {
+	ID        string
+	Document  *ai.Document
+	Entities  []*Entity
+	Relations []*Relation
+}
+
+// A Store stores the graph built from a corpus.
+//
+// Entity names are compared case-insensitively. When an entity or relation
+// that is already stored is added again, for example because another chunk
+// mentions it, its chunks are merged and its type and description are
+// kept unless they are empty.
+type Store interface {
+	// Add stores a chunk, along with its entities and relations.
+	// The Chunks fields of the entities and relations are ignored:
+	// they are recorded as being mentioned by c.
+	Add(ctx context.Context, c *Chunk) error
+	// Entity returns the entity with the given name, or nil if there is none.
+	Entity(ctx context.Context, name string) (*Entity, error)
+	// Relations returns the relations whose source or target is
+	// the entity with the given name.
+	Relations(ctx context.Context, name string) ([]*Relation, error)
+	// Document returns the document of the chunk with the given ID,
+	// or nil if there is none.
+	Document(ctx context.Context, id string) (*ai.Document, error)
+}
+
+// MemoryStore is a [Store] that keeps the graph in memory.
+// It is suitable for tests and prototypes.
+// The zero value is ready to use.
+type MemoryStore struct {
+	mu        sync.Mutex
+	docs      map[string]*ai.Document
+	entities  map[string]*Entity   // by key
+	relations map[string]*Relation // by relationKey
+	adjacent  map[string][]string  // relation keys by entity key
+}
+
+// Add implements [Store.Add].
+func (s *MemoryStore) Add(ctx context.Context, c *Chunk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.docs == nil {
+		s.docs = map[string]*ai.Document{}
+		s.entities = map[string]*Entity{}
+		s.relations = map[string]*Relation{}
+		s.adjacent = map[string][]string{}
+	}
+	s.docs[c.ID] = c.Document
+	for _, e := range c.Entities {
+		k := key(e.Name)
+		old, ok := s.entities[k]
+		if !ok {
+			old = &Entity{Name: e.Name}
+			s.entities[k] = old
+		}
+		old.Type = cmp.Or(old.Type, e.Type)
+		old.Description = cmp.Or(old.Description, e.Description)
+		old.Chunks = appendUnique(old.Chunks, c.ID)
+	}
+	for _, r := range c.Relations {
+		k := relationKey(r)
+		old, ok := s.relations[k]
+		if !ok {
+			old = &Relation{Source: r.Source, Target: r.Target, Type: r.Type}
+			s.relations[k] = old
+			s.adjacent[key(r.Source)] = append(s.adjacent[key(r.Source)], k)
+			if key(r.Target) != key(r.Source) {
+				s.adjacent[key(r.Target)] = append(s.adjacent[key(r.Target)], k)
+			}
+		}
+		old.Description = cmp.Or(old.Description, r.Description)
+		old.Chunks = appendUnique(old.Chunks, c.ID)
+	}
+	return nil
+}
+
+// Entity implements [Store.Entity].
+func (s *MemoryStore) Entity(ctx context.Context, name string) (*Entity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entities[key(name)]
+	if !ok {
+		return nil, nil
+	}
+	c := *e
+	c.Chunks = slices.Clone(e.Chunks)
+	return &c, nil
+}
+
+// Relations implements [Store.Relations].
+func (s *MemoryStore) Relations(ctx context.Context, name string) ([]*Relation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rels []*Relation
+	for _, k := range s.adjacent[key(name)] {
+		r := *s.relations[k]
+		r.Chunks = slices.Clone(r.Chunks)
+		rels = append(rels, &r)
+	}
+	return rels, nil
+}
+
+// Document implements [Store.Document].
+func (s *MemoryStore) Document(ctx context.Context, id string) (*ai.Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.docs[id], nil
+}
+
+// key returns the key under which an entity name is stored.
+func key(name string) string {
+	return strings.ToLower(name)
+}
+
+func relationKey(r *Relation) string {
+	return key(r.Source) + "\x00" + key(r.Type) + "\x00" + key(r.Target)
+}
+
+func appendUnique(s []string, v string) []string {
+	if slices.Contains(s, v) {
+		return s
+	}
+	return append(s, v)
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clientcache is a bounded, least-recently-used cache of clients.
+// Plugins use it to keep one client per set of per-request credentials.
+package clientcache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// DefaultSize is the number of clients kept when no size is configured.
+const DefaultSize = 16
+
+// A Cache holds up to a fixed number of clients, evicting the least
+// recently used one when it is full. An evicted client is closed once
+// every caller that is using it has released it.
+type Cache[C any] struct {
+	mu      sync.Mutex
+	size    int
+	lru     *list.List // of *entry[C]; front is most recently used
+	entries map[string]*list.Element
+	close   func(C)
+}
+
+type entry[C any] struct {
+	key     string
+	client  C
+	refs    int  // callers that have not yet released the client
+	evicted bool // removed from the cache; close when refs drops to zero
+}
+
+// New returns a cache holding at most size clients.
+// If size is not positive, DefaultSize is used.
+// If close is non-nil, it is called on each evicted client once the
+// client has been released.
+func New[C any](size int, close func(C)) *Cache[C] {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Cache[C]{
+		size:    size,
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+		close:   close,
+	}
+}
+
+// Get returns the client for key, calling create to make one if
+// it is not cached. Errors from create are not cached.
+// The caller must call release when it is done with the client.
+//
+// A cached client outlives the call that made it, so create is passed
+// a context with the values of ctx but without its deadline or
+// cancellation.
+func (c *Cache[C]) Get(ctx context.Context, key string, create func(context.Context) (C, error)) (client C, release func(), err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var e *entry[C]
+	if el, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(el)
+		e = el.Value.(*entry[C])
+	} else {
+		client, err := create(context.WithoutCancel(ctx))
+		if err != nil {
+			return client, nil, err
+		}
+		e = &entry[C]{key: key, client: client}
+		c.entries[key] = c.lru.PushFront(e)
+		for c.lru.Len() > c.size {
+			old := c.lru.Remove(c.lru.Back()).(*entry[C])
+			delete(c.entries, old.key)
+			old.evicted = true
+			c.maybeClose(old)
+		}
+	}
+	e.refs++
+	var once sync.Once
+	return e.client, func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			e.refs--
+			c.maybeClose(e)
+		})
+	}, nil
+}
+
+// maybeClose closes e's client if it is evicted and unused.
+// It requires c.mu.
+func (c *Cache[C]) maybeClose(e *entry[C]) {
+	if e.evicted && e.refs == 0 && c.close != nil {
+		c.close(e.client)
+	}
+}
+
+// Len returns the number of cached clients.
+func (c *Cache[C]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Purge evicts every cached client. Each is closed once it has been released.
+func (c *Cache[C]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.lru.Len() > 0 {
+		e := c.lru.Remove(c.lru.Back()).(*entry[C])
+		delete(c.entries, e.key)
+		e.evicted = true
+		c.maybeClose(e)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientcache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	var created, closed []string
+	c := New(2, func(s string) { closed = append(closed, s) })
+	// Clients outlive the call that creates them, so create does not see
+	// its cancellation.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	get := func(key string) {
+		t.Helper()
+		got, release, err := c.Get(ctx, key, func(ctx context.Context) (string, error) {
+			if ctx.Err() != nil {
+				t.Errorf("create got a canceled context")
+			}
+			created = append(created, key)
+			return key, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+		if got != key {
+			t.Fatalf("Get(%q) = %q", key, got)
+		}
+	}
+	get("a")
+	get("b")
+	get("a") // cached; now b is least recently used
+	get("c") // evicts b
+	get("a")
+	get("b") // evicts c
+
+	if want := []string{"a", "b", "c", "b"}; !slices.Equal(created, want) {
+		t.Errorf("created %v, want %v", created, want)
+	}
+	if want := []string{"b", "c"}; !slices.Equal(closed, want) {
+		t.Errorf("closed %v, want %v", closed, want)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", c.Len())
+	}
+
+	errCreate := errors.New("create failed")
+	if _, _, err := c.Get(ctx, "d", func(context.Context) (string, error) { return "", errCreate }); err != errCreate {
+		t.Errorf("got error %v, want %v", err, errCreate)
+	}
+	if c.Len() != 2 {
+		t.Errorf("after error, Len() = %d, want 2", c.Len())
+	}
+}
+
+func TestCacheCloseAfterRelease(t *testing.T) {
+	var closed []string
+	c := New(1, func(s string) { closed = append(closed, s) })
+	ctx := context.Background()
+	create := func(s string) func(context.Context) (string, error) {
+		return func(context.Context) (string, error) { return s, nil }
+	}
+	_, releaseA, err := c.Get(ctx, "a", create("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, releaseB, err := c.Get(ctx, "b", create("b")) // evicts a, which is still in use
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(closed) != 0 {
+		t.Fatalf("closed %v while in use", closed)
+	}
+	releaseA()
+	releaseA() // a second release has no effect
+	if !slices.Equal(closed, []string{"a"}) {
+		t.Errorf("closed %v, want [a]", closed)
+	}
+	releaseB()
+	if !slices.Equal(closed, []string{"a"}) {
+		t.Errorf("closed %v, want [a]: cached clients should stay open", closed)
+	}
+}
+
+func TestCachePurge(t *testing.T) {
+	var closed []string
+	c := New(2, func(s string) { closed = append(closed, s) })
+	ctx := context.Background()
+	create := func(s string) func(context.Context) (string, error) {
+		return func(context.Context) (string, error) { return s, nil }
+	}
+	_, releaseA, err := c.Get(ctx, "a", create("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, releaseB, err := c.Get(ctx, "b", create("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseB()
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("after Purge, Len() = %d, want 0", c.Len())
+	}
+	if !slices.Equal(closed, []string{"b"}) {
+		t.Errorf("closed %v, want [b]", closed)
+	}
+	releaseA()
+	if !slices.Equal(closed, []string{"b", "a"}) {
+		t.Errorf("closed %v, want [b a]", closed)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/internal/base"
+)
+
+// Gemini models accept audio as input, so transcription is a model
+// call with a prompt that asks for the transcript as JSON.
+
+// TranscribeModelRequest returns the model request that transcribes
+// the audio in req.
+func TranscribeModelRequest(req *ai.TranscribeRequest) *ai.ModelRequest {
+	var sb strings.Builder
+	sb.WriteString("Transcribe the speech in this audio verbatim.")
+	if req.Language != "" {
+		fmt.Fprintf(&sb, " The speech is in the language with BCP-47 code %q.", req.Language)
+	}
+	sb.WriteString(` Respond with only a JSON object with these fields: "text", the full transcript; "language", the BCP-47 code of the spoken language`)
+	if req.Timestamps {
+		sb.WriteString(`; "segments", a list of objects with the fields "start" and "end", the offsets in seconds from the start of the audio, and "text", the words spoken in that time. Use one segment per sentence.`)
+	} else {
+		sb.WriteString(".")
+	}
+	return &ai.ModelRequest{
+		Messages: []*ai.Message{{
+			Role:    ai.RoleUser,
+			Content: []*ai.Part{ai.NewTextPart(sb.String()), req.Audio},
+		}},
+	}
+}
+
+// TranscribeResponse parses the response to a request made by
+// [TranscribeModelRequest].
+func TranscribeResponse(req *ai.TranscribeRequest, resp *ai.ModelResponse) (*ai.TranscribeResponse, error) {
+	var res ai.TranscribeResponse
+	if err := json.Unmarshal([]byte(base.ExtractJSONFromMarkdown(resp.Text())), &res); err != nil {
+		return nil, fmt.Errorf("parsing transcript: %w", err)
+	}
+	if res.Text == "" {
+		var words []string
+		for _, s := range res.Segments {
+			words = append(words, s.Text)
+		}
+		res.Text = strings.Join(words, " ")
+	}
+	if !req.Timestamps {
+		res.Segments = nil
+	}
+	if res.Language == "" {
+		res.Language = req.Language
+	}
+	return &res, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gemini
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTranscribeModelRequest(t *testing.T) {
+	audio := ai.NewMediaPart("audio/mp3", "data:audio/mp3;base64,AAAA")
+	mreq := TranscribeModelRequest(&ai.TranscribeRequest{Audio: audio, Language: "fr-FR", Timestamps: true})
+	content := mreq.Messages[0].Content
+	if len(content) != 2 || content[1] != audio {
+		t.Fatalf("got content %v, want a prompt and the audio", content)
+	}
+	for _, want := range []string{`"fr-FR"`, `"segments"`} {
+		if !strings.Contains(content[0].Text, want) {
+			t.Errorf("prompt %q does not contain %s", content[0].Text, want)
+		}
+	}
+}
+
+func TestTranscribeResponse(t *testing.T) {
+	resp := func(text string) *ai.ModelResponse {
+		return &ai.ModelResponse{Message: ai.NewModelTextMessage(text)}
+	}
+	for _, test := range []struct {
+		name string
+		req  *ai.TranscribeRequest
+		text string
+		want *ai.TranscribeResponse
+	}{
+		{
+			name: "plain",
+			req:  &ai.TranscribeRequest{},
+			text: "```json\n{\"text\": \"Hi there.\", \"language\": \"en\"}\n```",
+			want: &ai.TranscribeResponse{Text: "Hi there.", Language: "en"},
+		},
+		{
+			name: "segments",
+			req:  &ai.TranscribeRequest{Timestamps: true, Language: "en-US"},
+			text: `{"segments": [{"start": 0, "end": 1.5, "text": "Hi."}, {"start": 1.5, "end": 2, "text": "Bye."}]}`,
+			want: &ai.TranscribeResponse{
+				Text:     "Hi. Bye.",
+				Language: "en-US",
+				Segments: []*ai.TranscriptSegment{
+					{Start: 0, End: 1.5, Text: "Hi."},
+					{Start: 1.5, End: 2, Text: "Bye."},
+				},
+			},
+		},
+		{
+			name: "unrequested segments",
+			req:  &ai.TranscribeRequest{},
+			text: `{"text": "Hi.", "segments": [{"start": 0, "end": 1, "text": "Hi."}]}`,
+			want: &ai.TranscribeResponse{Text: "Hi."},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := TranscribeResponse(test.req, resp(test.text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+	if _, err := TranscribeResponse(&ai.TranscribeRequest{}, resp("not json")); err == nil {
+		t.Error("parsing a non-JSON response succeeded unexpectedly")
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package toolcalls pairs tool calls with their responses for plugins that
+// convert Genkit messages to and from formats with tool call IDs.
+//
+// Genkit tool requests carry no ID, so converters invent one for each call,
+// and match tool responses to calls by tool name, in order.
+// This is used by the chatformat, langchaingo and vertexai plugins.
+package toolcalls
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Pending tracks tool calls that have not yet been answered,
+// to pair them with their responses.
+// The zero value is ready to use.
+type Pending struct {
+	// Prefix begins the IDs made up by Add, followed by a number.
+	// The default is "call_".
+	Prefix string
+
+	next    int
+	pending []call
+}
+
+type call struct {
+	id, name string
+}
+
+// Add records a call to the named tool. If id is empty, one is made up.
+func (c *Pending) Add(id, name string) string {
+	c.next++
+	if id == "" {
+		prefix := c.Prefix
+		if prefix == "" {
+			prefix = "call_"
+		}
+		id = fmt.Sprintf("%s%d", prefix, c.next)
+	}
+	c.pending = append(c.pending, call{id, name})
+	return id
+}
+
+// Answer removes and returns the ID of the earliest pending call to the named tool.
+func (c *Pending) Answer(name string) (string, bool) {
+	for i, tc := range c.pending {
+		if tc.name == name {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			return tc.id, true
+		}
+	}
+	return "", false
+}
+
+// AnswerID removes the pending call with the given ID and returns its tool name.
+func (c *Pending) AnswerID(id string) (string, bool) {
+	for i, tc := range c.pending {
+		if tc.id == id {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			return tc.name, true
+		}
+	}
+	return "", false
+}
+
+// Input converts the JSON arguments of a tool call to a tool request input.
+func Input(args string) (map[string]any, error) {
+	if strings.TrimSpace(args) == "" {
+		return nil, nil
+	}
+	var m map[string]any
+	if err := json.Unmarshal([]byte(args), &m); err != nil {
+		return nil, fmt.Errorf("tool call arguments: %w", err)
+	}
+	return m, nil
+}
+
+// Output converts the text of a tool result to a tool response output.
+// A JSON object is used as is; anything else is wrapped as {"content": text}.
+func Output(text string) map[string]any {
+	var m map[string]any
+	if err := json.Unmarshal([]byte(text), &m); err == nil && m != nil {
+		return m
+	}
+	return map[string]any{"content": text}
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package toolcalls
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPending(t *testing.T) {
+	var c Pending
+	a := c.Add("", "weather")
+	b := c.Add("", "time")
+	w2 := c.Add("given", "weather")
+	if a != "call_1" || b != "call_2" || w2 != "given" {
+		t.Fatalf("got IDs %q, %q, %q", a, b, w2)
+	}
+	// Calls to the same tool are answered in order.
+	if id, ok := c.Answer("weather"); !ok || id != a {
+		t.Errorf("got (%q, %t), want (%q, true)", id, ok, a)
+	}
+	if name, ok := c.AnswerID(w2); !ok || name != "weather" {
+		t.Errorf("got (%q, %t), want (weather, true)", name, ok)
+	}
+	if _, ok := c.Answer("weather"); ok {
+		t.Error("answered a call that was already answered")
+	}
+	if _, ok := c.AnswerID("nope"); ok {
+		t.Error("answered an unknown ID")
+	}
+
+	c = Pending{Prefix: "toolu_"}
+	if id := c.Add("", "x"); id != "toolu_1" {
+		t.Errorf("got ID %q, want toolu_1", id)
+	}
+}
+
+func TestInputOutput(t *testing.T) {
+	in, err := Input(`{"city": "Paris"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]any{"city": "Paris"}, in); diff != "" {
+		t.Errorf("Input mismatch (-want, +got):\n%s", diff)
+	}
+	if in, err := Input("  "); err != nil || in != nil {
+		t.Errorf("got (%v, %v), want (nil, nil)", in, err)
+	}
+	if _, err := Input("{"); err == nil {
+		t.Error("got nil error for invalid JSON")
+	}
+
+	for text, want := range map[string]map[string]any{
+		`{"sky": "sunny"}`: {"sky": "sunny"},
+		`[1, 2]`:           {"content": "[1, 2]"},
+		`sunny`:            {"content": "sunny"},
+		`null`:             {"content": "null"},
+	} {
+		if diff := cmp.Diff(want, Output(text)); diff != "" {
+			t.Errorf("Output(%q) mismatch (-want, +got):\n%s", text, diff)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package langchaingo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/tmc/langchaingo/embeddings"
+)
+
+// DefineEmbedder defines a Genkit embedder named "langchaingo/name" that calls e.
+// Only the text of each document is embedded.
+func DefineEmbedder(g *genkit.Genkit, name string, e embeddings.Embedder) ai.Embedder {
+	return genkit.DefineEmbedder(g, provider, name, func(ctx context.Context, req *ai.EmbedRequest) (*ai.EmbedResponse, error) {
+		var texts []string
+		for _, d := range req.Documents {
+			texts = append(texts, d.Text())
+		}
+		vecs, err := e.EmbedDocuments(ctx, texts)
+		if err != nil {
+			return nil, err
+		}
+		if len(vecs) != len(texts) {
+			return nil, fmt.Errorf("langchaingo embedder returned %d embeddings for %d documents", len(vecs), len(texts))
+		}
+		res := &ai.EmbedResponse{}
+		for _, v := range vecs {
+			res.Embeddings = append(res.Embeddings, &ai.DocumentEmbedding{Embedding: v})
+		}
+		return res, nil
+	})
+}
+
+// IsDefinedEmbedder reports whether the named embedder is defined by this plugin.
+func IsDefinedEmbedder(g *genkit.Genkit, name string) bool {
+	return genkit.IsDefinedEmbedder(g, provider, name)
+}
+
+// Embedder returns the [ai.Embedder] with the given name.
+// It returns nil if the embedder was not defined.
+func Embedder(g *genkit.Genkit, name string) ai.Embedder {
+	return genkit.LookupEmbedder(g, provider, name)
+}
+
+// NewEmbedder returns a langchaingo embedder that embeds with e.
+func NewEmbedder(e ai.Embedder) embeddings.Embedder {
+	return &embedder{e}
+}
+
+type embedder struct {
+	e ai.Embedder
+}
+
+func (e *embedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	req := &ai.EmbedRequest{}
+	for _, t := range texts {
+		req.Documents = append(req.Documents, ai.DocumentFromText(t, nil))
+	}
+	res, err := e.e.Embed(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("embedder %s returned %d embeddings for %d texts", e.e.Name(), len(res.Embeddings), len(texts))
+	}
+	vecs := make([][]float32, len(res.Embeddings))
+	for i, emb := range res.Embeddings {
+		vecs[i] = emb.Embedding
+	}
+	return vecs, nil
+}
+
+func (e *embedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	vecs, err := e.EmbedDocuments(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vecs[0], nil
+}
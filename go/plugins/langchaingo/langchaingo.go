@@ -0,0 +1,310 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package langchaingo adapts langchaingo models, embedders and vector
+// stores to Genkit, and Genkit models, embedders, indexers and retrievers
+// to langchaingo.
+//
+// The Define functions register langchaingo values as Genkit actions,
+// so existing integrations gain Genkit tracing and can be used by flows
+// and the developer UI. The New functions go the other way, for code
+// written against langchaingo interfaces.
+package langchaingo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/firebase/genkit/go/plugins/internal/toolcalls"
+	"github.com/tmc/langchaingo/llms"
+)
+
+const provider = "langchaingo"
+
+// DefineModel defines a Genkit model named "langchaingo/name" that calls m.
+// If caps is nil, the model is assumed to support multi-turn
+// conversations, system messages and tools, but not media.
+func DefineModel(g *genkit.Genkit, name string, m llms.Model, caps *ai.ModelCapabilities) ai.Model {
+	mc := ai.ModelCapabilities{Multiturn: true, SystemRole: true, Tools: true}
+	if caps != nil {
+		mc = *caps
+	}
+	meta := &ai.ModelMetadata{
+		Label:    "LangChainGo - " + name,
+		Supports: mc,
+	}
+	return genkit.DefineModel(g, provider, name, meta, func(ctx context.Context, req *ai.ModelRequest, cb ai.ModelStreamingCallback) (*ai.ModelResponse, error) {
+		resp, err := generate(ctx, m, req, cb)
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", provider, name, err)
+		}
+		return resp, nil
+	})
+}
+
+// IsDefinedModel reports whether the named model is defined by this plugin.
+func IsDefinedModel(g *genkit.Genkit, name string) bool {
+	return genkit.IsDefinedModel(g, provider, name)
+}
+
+// Model returns the [ai.Model] with the given name.
+// It returns nil if the model was not defined.
+func Model(g *genkit.Genkit, name string) ai.Model {
+	return genkit.LookupModel(g, provider, name)
+}
+
+func generate(ctx context.Context, m llms.Model, req *ai.ModelRequest, cb ai.ModelStreamingCallback) (*ai.ModelResponse, error) {
+	msgs, err := toLangchain(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	opts := callOptions(req)
+	if cb != nil {
+		opts = append(opts, llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+			return cb(ctx, &ai.ModelResponseChunk{Content: []*ai.Part{ai.NewTextPart(string(chunk))}})
+		}))
+	}
+	resp, err := m.GenerateContent(ctx, msgs, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("model returned no choices")
+	}
+	c := resp.Choices[0]
+	msg := &ai.Message{Role: ai.RoleModel}
+	if c.Content != "" {
+		msg.Content = append(msg.Content, ai.NewTextPart(c.Content))
+	}
+	for _, tc := range c.ToolCalls {
+		if tc.FunctionCall == nil {
+			continue
+		}
+		p, err := toolRequestPart(tc.FunctionCall)
+		if err != nil {
+			return nil, err
+		}
+		msg.Content = append(msg.Content, p)
+	}
+	if len(c.ToolCalls) == 0 && c.FuncCall != nil {
+		p, err := toolRequestPart(c.FuncCall)
+		if err != nil {
+			return nil, err
+		}
+		msg.Content = append(msg.Content, p)
+	}
+	return &ai.ModelResponse{
+		Message:      msg,
+		FinishReason: finishReason(c.StopReason),
+		Custom:       c.GenerationInfo,
+		Usage:        usage(c.GenerationInfo),
+		Request:      req,
+	}, nil
+}
+
+// callOptions converts the request's config, tools and output format.
+func callOptions(req *ai.ModelRequest) []llms.CallOption {
+	var opts []llms.CallOption
+	if c, ok := req.Config.(*ai.GenerationCommonConfig); ok && c != nil {
+		if c.MaxOutputTokens != 0 {
+			opts = append(opts, llms.WithMaxTokens(c.MaxOutputTokens))
+		}
+		if c.Temperature != 0 {
+			opts = append(opts, llms.WithTemperature(c.Temperature))
+		}
+		if c.TopK != 0 {
+			opts = append(opts, llms.WithTopK(c.TopK))
+		}
+		if c.TopP != 0 {
+			opts = append(opts, llms.WithTopP(c.TopP))
+		}
+		if c.Seed != 0 {
+			opts = append(opts, llms.WithSeed(c.Seed))
+		}
+		if len(c.StopSequences) > 0 {
+			opts = append(opts, llms.WithStopWords(c.StopSequences))
+		}
+	}
+	if len(req.Tools) > 0 {
+		var tools []llms.Tool
+		for _, t := range req.Tools {
+			tools = append(tools, llms.Tool{
+				Type: "function",
+				Function: &llms.FunctionDefinition{
+					Name:        t.Name,
+					Description: t.Description,
+					Parameters:  t.InputSchema,
+				},
+			})
+		}
+		opts = append(opts, llms.WithTools(tools))
+	}
+	if req.Output != nil && req.Output.Format == ai.OutputFormatJSON {
+		opts = append(opts, llms.WithJSONMode())
+	}
+	return opts
+}
+
+// finishReason maps the stop reasons of common langchaingo models.
+func finishReason(r string) ai.FinishReason {
+	switch strings.ToLower(r) {
+	case "stop", "end_turn", "stop_sequence", "tool_calls", "tool_use":
+		return ai.FinishReasonStop
+	case "length", "max_tokens":
+		return ai.FinishReasonLength
+	case "content_filter", "safety":
+		return ai.FinishReasonBlocked
+	case "":
+		return ai.FinishReasonUnknown
+	default:
+		return ai.FinishReasonOther
+	}
+}
+
+// usage reads token counts from the generation info of common langchaingo models.
+func usage(info map[string]any) *ai.GenerationUsage {
+	in, okIn := intInfo(info, "PromptTokens", "InputTokens", "input_tokens")
+	out, okOut := intInfo(info, "CompletionTokens", "OutputTokens", "output_tokens")
+	if !okIn && !okOut {
+		return nil
+	}
+	total, ok := intInfo(info, "TotalTokens", "total_tokens")
+	if !ok {
+		total = in + out
+	}
+	return &ai.GenerationUsage{InputTokens: in, OutputTokens: out, TotalTokens: total}
+}
+
+func intInfo(info map[string]any, keys ...string) (int, bool) {
+	for _, k := range keys {
+		switch v := info[k].(type) {
+		case int:
+			return v, true
+		case int32:
+			return int(v), true
+		case int64:
+			return int(v), true
+		case float64:
+			return int(v), true
+		}
+	}
+	return 0, false
+}
+
+// NewLLM returns a langchaingo model that generates with m.
+// Calls through it are traced like any other use of m.
+func NewLLM(g *genkit.Genkit, m ai.Model) llms.Model {
+	return &llm{g: g, m: m}
+}
+
+type llm struct {
+	g *genkit.Genkit
+	m ai.Model
+}
+
+// Call implements the deprecated [llms.Model.Call].
+func (l *llm) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, l, prompt, options...)
+}
+
+func (l *llm) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	var opts llms.CallOptions
+	for _, o := range options {
+		o(&opts)
+	}
+	msgs, err := fromLangchain(messages)
+	if err != nil {
+		return nil, err
+	}
+	genOpts := []ai.GenerateOption{
+		ai.WithModel(l.m),
+		ai.WithMessages(msgs...),
+		ai.WithConfig(&ai.GenerationCommonConfig{
+			MaxOutputTokens: opts.MaxTokens,
+			Temperature:     opts.Temperature,
+			StopSequences:   opts.StopWords,
+			TopK:            opts.TopK,
+			TopP:            opts.TopP,
+			Seed:            opts.Seed,
+		}),
+		// The langchaingo caller runs the tools.
+		ai.WithReturnToolRequests(true),
+	}
+	for _, t := range opts.Tools {
+		if t.Function == nil {
+			continue
+		}
+		td := &ai.ToolDefinition{Name: t.Function.Name, Description: t.Function.Description}
+		if t.Function.Parameters != nil {
+			// Parameters may be any JSON-marshalable value.
+			b, err := json.Marshal(t.Function.Parameters)
+			if err != nil {
+				return nil, fmt.Errorf("parameters of tool %q: %w", t.Function.Name, err)
+			}
+			if err := json.Unmarshal(b, &td.InputSchema); err != nil {
+				return nil, fmt.Errorf("parameters of tool %q: %w", t.Function.Name, err)
+			}
+		}
+		genOpts = append(genOpts, ai.WithToolDefinitions(td))
+	}
+	if opts.JSONMode {
+		genOpts = append(genOpts, ai.WithOutputFormat(ai.OutputFormatJSON))
+	}
+	if opts.StreamingFunc != nil {
+		genOpts = append(genOpts, ai.WithStreaming(func(ctx context.Context, chunk *ai.ModelResponseChunk) error {
+			return opts.StreamingFunc(ctx, []byte(chunk.Text()))
+		}))
+	}
+	resp, err := genkit.Generate(ctx, l.g, genOpts...)
+	if err != nil {
+		return nil, err
+	}
+	choice := &llms.ContentChoice{
+		StopReason:     string(resp.FinishReason),
+		GenerationInfo: map[string]any{},
+	}
+	if u := resp.Usage; u != nil {
+		choice.GenerationInfo["PromptTokens"] = u.InputTokens
+		choice.GenerationInfo["CompletionTokens"] = u.OutputTokens
+		choice.GenerationInfo["TotalTokens"] = u.TotalTokens
+	}
+	var calls toolcalls.Pending
+	var text strings.Builder
+	if resp.Message != nil {
+		for _, p := range resp.Message.Content {
+			if !p.IsToolRequest() {
+				text.WriteString(p.Text)
+				continue
+			}
+			args, err := json.Marshal(p.ToolRequest.Input)
+			if err != nil {
+				return nil, err
+			}
+			choice.ToolCalls = append(choice.ToolCalls, llms.ToolCall{
+				ID:           calls.Add("", p.ToolRequest.Name),
+				Type:         "function",
+				FunctionCall: &llms.FunctionCall{Name: p.ToolRequest.Name, Arguments: string(args)},
+			})
+		}
+	}
+	choice.Content = text.String()
+	if len(choice.ToolCalls) > 0 {
+		choice.FuncCall = choice.ToolCalls[0].FunctionCall
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{choice}}, nil
+}
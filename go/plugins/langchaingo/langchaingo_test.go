@@ -0,0 +1,272 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package langchaingo
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/google/go-cmp/cmp"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/vectorstores"
+)
+
+// fakeLLM asks for the weather tool once, then answers with the tool result.
+type fakeLLM struct {
+	got  []llms.MessageContent
+	opts llms.CallOptions
+}
+
+func (f *fakeLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, f, prompt, options...)
+}
+
+func (f *fakeLLM) GenerateContent(ctx context.Context, msgs []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	f.got = msgs
+	f.opts = llms.CallOptions{}
+	for _, o := range options {
+		o(&f.opts)
+	}
+	last := msgs[len(msgs)-1]
+	if resp, ok := last.Parts[0].(llms.ToolCallResponse); ok {
+		if f.opts.StreamingFunc != nil {
+			if err := f.opts.StreamingFunc(ctx, []byte("It is ")); err != nil {
+				return nil, err
+			}
+		}
+		return &llms.ContentResponse{Choices: []*llms.ContentChoice{{
+			Content:        "It is " + resp.Content,
+			StopReason:     "stop",
+			GenerationInfo: map[string]any{"PromptTokens": 10, "CompletionTokens": 3},
+		}}}, nil
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{
+		StopReason: "tool_calls",
+		ToolCalls: []llms.ToolCall{{
+			ID:           "abc",
+			Type:         "function",
+			FunctionCall: &llms.FunctionCall{Name: "weather", Arguments: `{"city":"Paris"}`},
+		}},
+	}}}, nil
+}
+
+func TestModel(t *testing.T) {
+	g, err := genkit.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeLLM{}
+	m := DefineModel(g, "fake", fake, nil)
+	if Model(g, "fake") == nil {
+		t.Fatal("model not defined")
+	}
+	weather := genkit.DefineTool(g, "weather", "the weather in a city",
+		func(ctx context.Context, in struct {
+			City string `json:"city"`
+		}) (map[string]any, error) {
+			return map[string]any{"forecast": "sunny in " + in.City}, nil
+		})
+
+	var chunks []string
+	resp, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithTextPrompt("Weather in Paris?"),
+		ai.WithTools(weather),
+		ai.WithConfig(&ai.GenerationCommonConfig{Temperature: 0.5, Seed: 7}),
+		ai.WithStreaming(func(ctx context.Context, c *ai.ModelResponseChunk) error {
+			chunks = append(chunks, c.Text())
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resp.Text(), `It is {"response":{"forecast":"sunny in Paris"}}`; got != want {
+		t.Errorf("got text %q, want %q", got, want)
+	}
+	if got, want := resp.Usage, (&ai.GenerationUsage{InputTokens: 10, OutputTokens: 3, TotalTokens: 13}); !cmp.Equal(got, want) {
+		t.Errorf("got usage %+v, want %+v", got, want)
+	}
+	if !cmp.Equal(chunks, []string{"It is "}) {
+		t.Errorf("got chunks %q", chunks)
+	}
+	if fake.opts.Temperature != 0.5 || fake.opts.Seed != 7 || len(fake.opts.Tools) != 1 {
+		t.Errorf("got options %+v", fake.opts)
+	}
+	// The second call carries the tool call and its response, matched by ID.
+	call := fake.got[1].Parts[0].(llms.ToolCall)
+	resp2 := fake.got[2].Parts[0].(llms.ToolCallResponse)
+	if call.ID != resp2.ToolCallID || resp2.Name != "weather" {
+		t.Errorf("tool call %+v does not match response %+v", call, resp2)
+	}
+
+	// Errors name the model.
+	_, err = genkit.Generate(context.Background(), g,
+		ai.WithModel(m),
+		ai.WithMessages(&ai.Message{Role: ai.RoleTool, Content: []*ai.Part{
+			ai.NewToolResponsePart(&ai.ToolResponse{Name: "weather", Output: map[string]any{}}),
+		}}))
+	if err == nil || !strings.Contains(err.Error(), "langchaingo/fake: ") {
+		t.Errorf("got error %v, want one naming langchaingo/fake", err)
+	}
+}
+
+func TestLLM(t *testing.T) {
+	g, err := genkit.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got *ai.ModelRequest
+	m := genkit.DefineModel(g, "test", "echo", nil, func(ctx context.Context, req *ai.ModelRequest, cb ai.ModelStreamingCallback) (*ai.ModelResponse, error) {
+		got = req
+		msg := ai.NewModelTextMessage("checking")
+		if len(req.Tools) > 0 {
+			msg.Content = append(msg.Content, ai.NewToolRequestPart(&ai.ToolRequest{Name: req.Tools[0].Name, Input: map[string]any{"q": "x"}}))
+		}
+		return &ai.ModelResponse{Message: msg, FinishReason: ai.FinishReasonStop, Request: req}, nil
+	})
+	l := NewLLM(g, m)
+
+	// Tools declared by langchaingo are returned to the caller, not run by Genkit.
+	resp, err := l.GenerateContent(context.Background(),
+		[]llms.MessageContent{
+			llms.TextParts(llms.ChatMessageTypeSystem, "Be brief."),
+			llms.TextParts(llms.ChatMessageTypeHuman, "Search for x."),
+		},
+		llms.WithMaxTokens(100),
+		llms.WithTools([]llms.Tool{{Type: "function", Function: &llms.FunctionDefinition{
+			Name:       "search",
+			Parameters: map[string]any{"type": "object"},
+		}}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := resp.Choices[0]
+	if c.Content != "checking" || len(c.ToolCalls) != 1 || c.ToolCalls[0].FunctionCall.Arguments != `{"q":"x"}` {
+		t.Errorf("got choice %+v", c)
+	}
+	if got.Messages[0].Role != ai.RoleSystem || got.Config.(*ai.GenerationCommonConfig).MaxOutputTokens != 100 {
+		t.Errorf("got request %+v", got)
+	}
+	if got.Tools[0].InputSchema["type"] != "object" {
+		t.Errorf("got tools %+v", got.Tools)
+	}
+
+	text, err := llms.GenerateFromSinglePrompt(context.Background(), l, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "checking" {
+		t.Errorf("got %q, want %q", text, "checking")
+	}
+}
+
+// fakeEmbedder embeds a text as its length.
+type fakeEmbedder struct{}
+
+func (fakeEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	var vecs [][]float32
+	for _, t := range texts {
+		vecs = append(vecs, []float32{float32(len(t))})
+	}
+	return vecs, nil
+}
+
+func (e fakeEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	vecs, err := e.EmbedDocuments(ctx, []string{text})
+	return vecs[0], err
+}
+
+func TestEmbedder(t *testing.T) {
+	g, err := genkit.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wrap in both directions.
+	e := NewEmbedder(DefineEmbedder(g, "len", fakeEmbedder{}))
+	vecs, err := e.EmbedDocuments(context.Background(), []string{"a", "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]float32{{1}, {3}}; !cmp.Equal(vecs, want) {
+		t.Errorf("got %v, want %v", vecs, want)
+	}
+}
+
+// fakeStore returns the documents containing the query.
+type fakeStore struct {
+	docs []schema.Document
+	opts vectorstores.Options
+}
+
+func (s *fakeStore) AddDocuments(ctx context.Context, docs []schema.Document, _ ...vectorstores.Option) ([]string, error) {
+	s.docs = append(s.docs, docs...)
+	return nil, nil
+}
+
+func (s *fakeStore) SimilaritySearch(ctx context.Context, query string, n int, options ...vectorstores.Option) ([]schema.Document, error) {
+	s.opts = vectorstores.Options{}
+	for _, o := range options {
+		o(&s.opts)
+	}
+	var res []schema.Document
+	for _, d := range s.docs {
+		if strings.Contains(d.PageContent, query) && len(res) < n {
+			d.Score = 0.5
+			res = append(res, d)
+		}
+	}
+	return res, nil
+}
+
+func TestVectorStore(t *testing.T) {
+	g, err := genkit.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &fakeStore{}
+	// Wrap in both directions.
+	indexer, retriever := DefineVectorStore(g, "fake", store)
+	vs := NewVectorStore(indexer, retriever, nil)
+	ctx := context.Background()
+	if _, err := vs.AddDocuments(ctx, []schema.Document{
+		{PageContent: "red apple", Metadata: map[string]any{"id": 1}},
+		{PageContent: "green apple"},
+		{PageContent: "pear"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	docs, err := vs.SimilaritySearch(ctx, "apple", 1, vectorstores.WithNameSpace("fruit"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []schema.Document{{PageContent: "red apple", Metadata: map[string]any{"id": 1, "score": float32(0.5)}, Score: 0.5}}
+	if diff := cmp.Diff(want, docs); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+	if store.opts.NameSpace != "fruit" {
+		t.Errorf("namespace %q was not passed on", store.opts.NameSpace)
+	}
+	_, err = retriever.Retrieve(ctx, &ai.RetrieverRequest{
+		Document: ai.DocumentFromText("apple", nil),
+		Options:  RetrieverOptions{K: 1},
+	})
+	if err == nil || !strings.Contains(err.Error(), "options have type") {
+		t.Errorf("got error %v, want one about the options type", err)
+	}
+}
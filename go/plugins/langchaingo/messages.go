@@ -0,0 +1,180 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package langchaingo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/plugins/internal/toolcalls"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// toLangchain converts Genkit messages to langchaingo messages.
+// Genkit tool requests have no IDs, so calls are given IDs of the form
+// "call_N" and responses are matched to calls by tool name, in order.
+func toLangchain(msgs []*ai.Message) ([]llms.MessageContent, error) {
+	var out []llms.MessageContent
+	var calls toolcalls.Pending
+	for _, m := range msgs {
+		mc := llms.MessageContent{Role: toLangchainRole(m.Role)}
+		for _, p := range m.Content {
+			switch {
+			case p.IsText() || p.IsData():
+				mc.Parts = append(mc.Parts, llms.TextContent{Text: p.Text})
+			case p.IsMedia():
+				cp, err := toLangchainMedia(p)
+				if err != nil {
+					return nil, err
+				}
+				mc.Parts = append(mc.Parts, cp)
+			case p.IsToolRequest():
+				args, err := json.Marshal(p.ToolRequest.Input)
+				if err != nil {
+					return nil, err
+				}
+				mc.Parts = append(mc.Parts, llms.ToolCall{
+					ID:   calls.Add("", p.ToolRequest.Name),
+					Type: "function",
+					FunctionCall: &llms.FunctionCall{
+						Name:      p.ToolRequest.Name,
+						Arguments: string(args),
+					},
+				})
+			case p.IsToolResponse():
+				id, ok := calls.Answer(p.ToolResponse.Name)
+				if !ok {
+					return nil, fmt.Errorf("response from tool %q, which was not called", p.ToolResponse.Name)
+				}
+				output, err := json.Marshal(p.ToolResponse.Output)
+				if err != nil {
+					return nil, err
+				}
+				mc.Parts = append(mc.Parts, llms.ToolCallResponse{
+					ToolCallID: id,
+					Name:       p.ToolResponse.Name,
+					Content:    string(output),
+				})
+			}
+		}
+		out = append(out, mc)
+	}
+	return out, nil
+}
+
+func toLangchainRole(r ai.Role) llms.ChatMessageType {
+	switch r {
+	case ai.RoleSystem:
+		return llms.ChatMessageTypeSystem
+	case ai.RoleModel:
+		return llms.ChatMessageTypeAI
+	case ai.RoleTool:
+		return llms.ChatMessageTypeTool
+	default:
+		return llms.ChatMessageTypeHuman
+	}
+}
+
+// toLangchainMedia converts a data URL to binary content,
+// and any other URL to an image URL.
+func toLangchainMedia(p *ai.Part) (llms.ContentPart, error) {
+	rest, ok := strings.CutPrefix(p.Text, "data:")
+	if !ok {
+		return llms.ImageURLContent{URL: p.Text}, nil
+	}
+	prefix, data, ok := strings.Cut(rest, ",")
+	if !ok {
+		return nil, fmt.Errorf("invalid data URL")
+	}
+	ct, isBase64 := strings.CutSuffix(prefix, ";base64")
+	if p.ContentType != "" {
+		ct = p.ContentType
+	}
+	b := []byte(data)
+	if isBase64 {
+		var err error
+		if b, err = base64.StdEncoding.DecodeString(data); err != nil {
+			return nil, fmt.Errorf("invalid data URL: %w", err)
+		}
+	}
+	return llms.BinaryContent{MIMEType: ct, Data: b}, nil
+}
+
+// fromLangchain converts langchaingo messages to Genkit messages.
+func fromLangchain(msgs []llms.MessageContent) ([]*ai.Message, error) {
+	var out []*ai.Message
+	for _, mc := range msgs {
+		m := &ai.Message{Role: fromLangchainRole(mc.Role)}
+		for _, cp := range mc.Parts {
+			p, err := fromLangchainPart(cp)
+			if err != nil {
+				return nil, err
+			}
+			m.Content = append(m.Content, p)
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+func fromLangchainRole(t llms.ChatMessageType) ai.Role {
+	switch t {
+	case llms.ChatMessageTypeSystem:
+		return ai.RoleSystem
+	case llms.ChatMessageTypeAI:
+		return ai.RoleModel
+	case llms.ChatMessageTypeTool, llms.ChatMessageTypeFunction:
+		return ai.RoleTool
+	default:
+		return ai.RoleUser
+	}
+}
+
+func fromLangchainPart(cp llms.ContentPart) (*ai.Part, error) {
+	switch cp := cp.(type) {
+	case llms.TextContent:
+		return ai.NewTextPart(cp.Text), nil
+	case llms.ImageURLContent:
+		ct := mime.TypeByExtension(path.Ext(cp.URL))
+		if rest, ok := strings.CutPrefix(cp.URL, "data:"); ok {
+			ct, _, _ = strings.Cut(rest, ";")
+		}
+		return ai.NewMediaPart(ct, cp.URL), nil
+	case llms.BinaryContent:
+		return ai.NewMediaPart(cp.MIMEType, cp.String()), nil
+	case llms.ToolCall:
+		if cp.FunctionCall == nil {
+			return nil, fmt.Errorf("tool call %q has no function", cp.ID)
+		}
+		return toolRequestPart(cp.FunctionCall)
+	case llms.ToolCallResponse:
+		return ai.NewToolResponsePart(&ai.ToolResponse{Name: cp.Name, Output: toolcalls.Output(cp.Content)}), nil
+	default:
+		return nil, fmt.Errorf("unsupported content part %T", cp)
+	}
+}
+
+func toolRequestPart(fc *llms.FunctionCall) (*ai.Part, error) {
+	input, err := toolcalls.Input(fc.Arguments)
+	if err != nil {
+		return nil, fmt.Errorf("call to %q: %w", fc.Name, err)
+	}
+	return ai.NewToolRequestPart(&ai.ToolRequest{Name: fc.Name, Input: input}), nil
+}
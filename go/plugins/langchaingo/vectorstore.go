@@ -0,0 +1,168 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package langchaingo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/vectorstores"
+)
+
+// RetrieverOptions may be passed in the Options field
+// of [ai.RetrieverRequest] to a retriever defined by [DefineVectorStore].
+type RetrieverOptions struct {
+	// The number of documents to retrieve. The default is 3.
+	K int `json:"k,omitempty"`
+	// Only documents at least this similar are returned, if the
+	// vector store supports it.
+	ScoreThreshold float32 `json:"scoreThreshold,omitempty"`
+	// The namespace to search, if the vector store supports it.
+	Namespace string `json:"namespace,omitempty"`
+	// A filter in the vector store's own format.
+	Filters any `json:"filters,omitempty"`
+}
+
+// defaultK is the number of documents retrieved when the request does not say.
+const defaultK = 3
+
+// DefineVectorStore defines a Genkit indexer and retriever, both named
+// "langchaingo/name", that add documents to and search vs.
+// The retriever searches for the text of the request document.
+// Scores from vs are put in the "score" metadata of the retrieved documents.
+func DefineVectorStore(g *genkit.Genkit, name string, vs vectorstores.VectorStore) (ai.Indexer, ai.Retriever) {
+	indexer := genkit.DefineIndexer(g, provider, name, func(ctx context.Context, req *ai.IndexerRequest) error {
+		var docs []schema.Document
+		for _, d := range req.Documents {
+			docs = append(docs, schema.Document{PageContent: d.Text(), Metadata: d.Metadata})
+		}
+		_, err := vs.AddDocuments(ctx, docs)
+		return err
+	})
+	retriever := genkit.DefineRetriever(g, provider, name, func(ctx context.Context, req *ai.RetrieverRequest) (*ai.RetrieverResponse, error) {
+		k := defaultK
+		var opts []vectorstores.Option
+		if req.Options != nil {
+			ro, ok := req.Options.(*RetrieverOptions)
+			if !ok {
+				return nil, fmt.Errorf("langchaingo.Retrieve options have type %T, want %T", req.Options, &RetrieverOptions{})
+			}
+			if ro == nil {
+				ro = &RetrieverOptions{}
+			}
+			if ro.K > 0 {
+				k = ro.K
+			}
+			if ro.ScoreThreshold != 0 {
+				opts = append(opts, vectorstores.WithScoreThreshold(ro.ScoreThreshold))
+			}
+			if ro.Namespace != "" {
+				opts = append(opts, vectorstores.WithNameSpace(ro.Namespace))
+			}
+			if ro.Filters != nil {
+				opts = append(opts, vectorstores.WithFilters(ro.Filters))
+			}
+		}
+		docs, err := vs.SimilaritySearch(ctx, req.Document.Text(), k, opts...)
+		if err != nil {
+			return nil, err
+		}
+		res := &ai.RetrieverResponse{}
+		for _, d := range docs {
+			meta := map[string]any{}
+			for k, v := range d.Metadata {
+				meta[k] = v
+			}
+			meta["score"] = d.Score
+			res.Documents = append(res.Documents, ai.DocumentFromText(d.PageContent, meta))
+		}
+		return res, nil
+	})
+	return indexer, retriever
+}
+
+// NewVectorStore returns a langchaingo vector store that adds documents
+// with indexer and searches with retriever. Either may be nil if that
+// operation is not needed.
+//
+// If retrieverOptions is non-nil, it is called to make the Options of
+// each retriever request from the number of documents wanted and the
+// langchaingo options. Otherwise the Options are a [*RetrieverOptions].
+func NewVectorStore(indexer ai.Indexer, retriever ai.Retriever, retrieverOptions func(numDocuments int, opts *vectorstores.Options) any) vectorstores.VectorStore {
+	return &vectorStore{indexer, retriever, retrieverOptions}
+}
+
+type vectorStore struct {
+	indexer          ai.Indexer
+	retriever        ai.Retriever
+	retrieverOptions func(int, *vectorstores.Options) any
+}
+
+// AddDocuments indexes docs. Genkit indexers do not return IDs, so the
+// returned slice is always empty.
+func (v *vectorStore) AddDocuments(ctx context.Context, docs []schema.Document, _ ...vectorstores.Option) ([]string, error) {
+	if v.indexer == nil {
+		return nil, errors.New("langchaingo: vector store has no indexer")
+	}
+	req := &ai.IndexerRequest{}
+	for _, d := range docs {
+		req.Documents = append(req.Documents, ai.DocumentFromText(d.PageContent, d.Metadata))
+	}
+	return nil, v.indexer.Index(ctx, req)
+}
+
+func (v *vectorStore) SimilaritySearch(ctx context.Context, query string, numDocuments int, options ...vectorstores.Option) ([]schema.Document, error) {
+	if v.retriever == nil {
+		return nil, errors.New("langchaingo: vector store has no retriever")
+	}
+	var opts vectorstores.Options
+	for _, o := range options {
+		o(&opts)
+	}
+	var ropts any
+	if v.retrieverOptions != nil {
+		ropts = v.retrieverOptions(numDocuments, &opts)
+	} else {
+		ropts = &RetrieverOptions{
+			K:              numDocuments,
+			ScoreThreshold: opts.ScoreThreshold,
+			Namespace:      opts.NameSpace,
+			Filters:        opts.Filters,
+		}
+	}
+	res, err := v.retriever.Retrieve(ctx, &ai.RetrieverRequest{
+		Document: ai.DocumentFromText(query, nil),
+		Options:  ropts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var docs []schema.Document
+	for _, d := range res.Documents {
+		sd := schema.Document{PageContent: d.Text(), Metadata: d.Metadata}
+		switch s := d.Metadata["score"].(type) {
+		case float32:
+			sd.Score = s
+		case float64:
+			sd.Score = float32(s)
+		}
+		docs = append(docs, sd)
+	}
+	return docs, nil
+}
@@ -49,6 +49,10 @@ func embed(ctx context.Context, serverAddress string, req *ai.EmbedRequest) (*ai
 		return nil, fmt.Errorf("invalid embedding model: model must be specified")
 	}
 
+	serverAddress, apiKey, err := serverFor(ctx, serverAddress)
+	if err != nil {
+		return nil, err
+	}
 	if serverAddress == "" {
 		return nil, fmt.Errorf("invalid server address: address cannot be empty")
 	}
@@ -60,7 +64,7 @@ func embed(ctx context.Context, serverAddress string, req *ai.EmbedRequest) (*ai
 		return nil, fmt.Errorf("failed to marshal embed request: %w", err)
 	}
 
-	resp, err := sendEmbedRequest(ctx, serverAddress, jsonData)
+	resp, err := sendEmbedRequest(ctx, serverAddress, apiKey, jsonData)
 	if err != nil {
 		return nil, err
 	}
@@ -78,13 +82,16 @@ func embed(ctx context.Context, serverAddress string, req *ai.EmbedRequest) (*ai
 	return newEmbedResponse(ollamaResp.Embeddings), nil
 }
 
-func sendEmbedRequest(ctx context.Context, serverAddress string, jsonData []byte) (*http.Response, error) {
+func sendEmbedRequest(ctx context.Context, serverAddress, apiKey string, jsonData []byte) (*http.Response, error) {
 	client := &http.Client{}
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", serverAddress+"/api/embed", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+	}
 	return client.Do(httpReq)
 }
 
@@ -132,7 +139,7 @@ func DefineEmbedder(g *genkit.Genkit, serverAddress string, model string) ai.Emb
 	if !state.initted {
 		panic("ollama.Init not called")
 	}
-	return genkit.DefineEmbedder(g, provider, serverAddress, func(ctx context.Context, req *ai.EmbedRequest) (*ai.EmbedResponse, error) {
+	e := genkit.DefineEmbedder(g, provider, serverAddress, func(ctx context.Context, req *ai.EmbedRequest) (*ai.EmbedResponse, error) {
 		if req.Options == nil {
 			req.Options = &EmbedOptions{Model: model}
 		}
@@ -141,6 +148,8 @@ func DefineEmbedder(g *genkit.Genkit, serverAddress string, model string) ai.Emb
 		}
 		return embed(ctx, serverAddress, req)
 	})
+	genkit.RegisterHealthChecker(g, provider+"/embedder/"+serverAddress, healthChecker(serverAddress, model))
+	return e
 }
 
 // IsDefinedEmbedder reports whether the embedder with the given server address is defined by this plugin.
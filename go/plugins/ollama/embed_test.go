@@ -64,3 +64,53 @@ func TestEmbedInvalidServerAddress(t *testing.T) {
 		t.Fatalf("expected invalid server address error, got %v", err)
 	}
 }
+
+func TestEmbedCredentials(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		json.NewEncoder(w).Encode(ollamaEmbedResponse{
+			Embeddings: [][]float32{{0.1, 0.2, 0.3}},
+		})
+	}))
+	defer server.Close()
+
+	ctx := ai.WithCredentialProvider(context.Background(), func(context.Context, string) (*ai.Credentials, error) {
+		return &ai.Credentials{Endpoint: server.URL, APIKey: "tenant-key"}, nil
+	})
+	req := &ai.EmbedRequest{
+		Documents: []*ai.Document{ai.DocumentFromText("test", nil)},
+		Options:   &EmbedOptions{Model: "all-minilm"},
+	}
+	// The configured address is empty; the credentials supply the endpoint.
+	if _, err := embed(ctx, "", req); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Bearer tenant-key"; gotAuth != want {
+		t.Errorf("got Authorization %q, want %q", gotAuth, want)
+	}
+}
+
+func TestCheckModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"models": [{"name": "llama3:latest"}, {"name": "gemma2:2b"}]}`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	for _, model := range []string{"", "llama3", "llama3:latest", "gemma2:2b"} {
+		if err := checkModel(ctx, server.URL, model); err != nil {
+			t.Errorf("%q: %v", model, err)
+		}
+	}
+	if err := checkModel(ctx, server.URL, "gemma2"); err == nil || !strings.Contains(err.Error(), "ollama pull gemma2") {
+		t.Errorf("got %v, want missing model error", err)
+	}
+	if err := checkModel(ctx, "http://127.0.0.1:1", ""); err == nil {
+		t.Error("got nil error for unreachable server")
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// healthChecker returns a health checker that verifies that the server
+// at serverAddress is reachable and, if model is not empty, that it has
+// the model.
+func healthChecker(serverAddress, model string) genkit.HealthChecker {
+	return genkit.HealthCheckerFunc(func(ctx context.Context) error {
+		return checkModel(ctx, serverAddress, model)
+	})
+}
+
+func checkModel(ctx context.Context, serverAddress, model string) error {
+	if serverAddress == "" {
+		return fmt.Errorf("no server address")
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", serverAddress+"/api/tags", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("ollama server %s: %w", serverAddress, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ollama server %s: status %s", serverAddress, resp.Status)
+	}
+	if model == "" {
+		return nil
+	}
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return fmt.Errorf("ollama server %s: decoding model list: %w", serverAddress, err)
+	}
+	for _, m := range tags.Models {
+		// A model pulled without a tag is listed as "name:latest".
+		if m.Name == model || strings.TrimSuffix(m.Name, ":latest") == model {
+			return nil
+		}
+	}
+	return fmt.Errorf("ollama server %s does not have model %q; run `ollama pull %s`", serverAddress, model, model)
+}
@@ -69,7 +69,9 @@ func DefineModel(g *genkit.Genkit, model ModelDefinition, caps *ai.ModelCapabili
 		Supports: mc,
 	}
 	gen := &generator{model: model, serverAddress: state.serverAddress}
-	return genkit.DefineModel(g, provider, model.Name, meta, gen.generate)
+	m := genkit.DefineModel(g, provider, model.Name, meta, gen.generate)
+	genkit.RegisterHealthChecker(g, provider+"/"+model.Name, healthChecker(state.serverAddress, model.Name))
+	return m
 
 }
 
@@ -119,14 +121,21 @@ type ollamaChatRequest struct {
 	Messages []*ollamaMessage `json:"messages"`
 	Model    string           `json:"model"`
 	Stream   bool             `json:"stream"`
+	Options  *ollamaOptions   `json:"options,omitempty"`
 }
 
 type ollamaModelRequest struct {
-	System string   `json:"system,omitempty"`
-	Images []string `json:"images,omitempty"`
-	Model  string   `json:"model"`
-	Prompt string   `json:"prompt"`
-	Stream bool     `json:"stream"`
+	System  string         `json:"system,omitempty"`
+	Images  []string       `json:"images,omitempty"`
+	Model   string         `json:"model"`
+	Prompt  string         `json:"prompt"`
+	Stream  bool           `json:"stream"`
+	Options *ollamaOptions `json:"options,omitempty"`
+}
+
+// ollamaOptions holds the model parameters of a request.
+type ollamaOptions struct {
+	Seed int `json:"seed,omitempty"`
 }
 
 // TODO: Add optional parameters (images, format, options, etc.) based on your use case
@@ -168,10 +177,20 @@ func Init(ctx context.Context, cfg *Config) (err error) {
 	return nil
 }
 
+// options returns the Ollama options for the request's config.
+func (g *generator) options(input *ai.ModelRequest) *ollamaOptions {
+	c, ok := input.Config.(*ai.GenerationCommonConfig)
+	if !ok || c == nil || c.Seed == 0 {
+		return nil
+	}
+	return &ollamaOptions{Seed: c.Seed}
+}
+
 // Generate makes a request to the Ollama API and processes the response.
 func (g *generator) generate(ctx context.Context, input *ai.ModelRequest, cb func(context.Context, *ai.ModelResponseChunk) error) (*ai.ModelResponse, error) {
 
 	stream := cb != nil
+	opts := g.options(input)
 	var payload any
 	isChatModel := g.model.Type == "chat"
 	if !isChatModel {
@@ -180,11 +199,12 @@ func (g *generator) generate(ctx context.Context, input *ai.ModelRequest, cb fun
 			return nil, fmt.Errorf("failed to grab image parts: %v", err)
 		}
 		payload = ollamaModelRequest{
-			Model:  g.model.Name,
-			Prompt: concatMessages(input, []ai.Role{ai.RoleUser, ai.RoleModel, ai.RoleTool}),
-			System: concatMessages(input, []ai.Role{ai.RoleSystem}),
-			Images: images,
-			Stream: stream,
+			Model:   g.model.Name,
+			Prompt:  concatMessages(input, []ai.Role{ai.RoleUser, ai.RoleModel, ai.RoleTool}),
+			System:  concatMessages(input, []ai.Role{ai.RoleSystem}),
+			Images:  images,
+			Stream:  stream,
+			Options: opts,
 		}
 	} else {
 		var messages []*ollamaMessage
@@ -200,6 +220,7 @@ func (g *generator) generate(ctx context.Context, input *ai.ModelRequest, cb fun
 			Messages: messages,
 			Model:    g.model.Name,
 			Stream:   stream,
+			Options:  opts,
 		}
 	}
 	client := &http.Client{Timeout: 30 * time.Second}
@@ -207,16 +228,23 @@ func (g *generator) generate(ctx context.Context, input *ai.ModelRequest, cb fun
 	if err != nil {
 		return nil, err
 	}
+	serverAddress, apiKey, err := serverFor(ctx, g.serverAddress)
+	if err != nil {
+		return nil, err
+	}
 	// Determine the correct endpoint
-	endpoint := g.serverAddress + "/api/chat"
+	endpoint := serverAddress + "/api/chat"
 	if !isChatModel {
-		endpoint = g.serverAddress + "/api/generate"
+		endpoint = serverAddress + "/api/generate"
 	}
 	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(payloadBytes))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
 	req = req.WithContext(ctx)
 	resp, err := client.Do(req)
 	if err != nil {
@@ -281,6 +309,24 @@ func (g *generator) generate(ctx context.Context, input *ai.ModelRequest, cb fun
 	}
 }
 
+// serverFor returns the server address and API key for a call in ctx.
+// Per-request credentials in ctx may replace the server address with their
+// Endpoint, and supply an API key for an Ollama server behind an
+// authenticating proxy.
+func serverFor(ctx context.Context, serverAddress string) (addr, apiKey string, err error) {
+	creds, err := ai.CredentialsFor(ctx, provider)
+	if err != nil {
+		return "", "", err
+	}
+	if creds == nil {
+		return serverAddress, "", nil
+	}
+	if creds.Endpoint != "" {
+		serverAddress = creds.Endpoint
+	}
+	return serverAddress, creds.APIKey, nil
+}
+
 func convertParts(role ai.Role, parts []*ai.Part) (*ollamaMessage, error) {
 	message := &ollamaMessage{
 		Role: roleMapping[role],
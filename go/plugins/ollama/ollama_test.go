@@ -126,3 +126,24 @@ func equalContent(a, b []*ai.Part) bool {
 	}
 	return true
 }
+
+func TestOptions(t *testing.T) {
+	g := &generator{model: ModelDefinition{Name: "llama3", Type: "chat"}}
+	for _, test := range []struct {
+		name   string
+		config any
+		want   *ollamaOptions
+	}{
+		{"no config", nil, nil},
+		{"other config", map[string]any{"seed": 1}, nil},
+		{"no seed", &ai.GenerationCommonConfig{Temperature: 0.5}, nil},
+		{"seed", &ai.GenerationCommonConfig{Seed: 42}, &ollamaOptions{Seed: 42}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := g.options(&ai.ModelRequest{Config: test.config})
+			if (got == nil) != (test.want == nil) || (got != nil && *got != *test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
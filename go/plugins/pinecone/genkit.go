@@ -28,6 +28,7 @@ import (
 
 	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/genkit"
+	"github.com/firebase/genkit/go/plugins/secrets"
 )
 
 const provider = "pinecone"
@@ -46,23 +47,36 @@ var state struct {
 	client  *client
 }
 
+// ClientConfig passes configuration options to the plugin.
+type ClientConfig struct {
+	// The API key to use with Pinecone.
+	// If not set, the default is read from the PINECONE_API_KEY
+	// environment variable.
+	APIKey string
+	// Secrets, if set, is consulted instead of the environment for
+	// PINECONE_API_KEY. If it is a [secrets.Watcher], such as a
+	// [secrets.Refresher], the plugin switches to the new key when it
+	// is rotated.
+	Secrets secrets.Provider
+}
+
 // Init initializes the Pinecone plugin.
-// If apiKey is the empty string, it is read from the PINECONE_API_KEY
-// environment variable.
-func Init(ctx context.Context, apiKey string) (err error) {
-	// Init initializes the Pinecone plugin.
-	state.mu.Lock()
-	defer state.mu.Unlock()
-	if state.initted {
-		panic("pinecone.Init already called")
-	}
+// The cfg argument may be nil to use the defaults.
+func Init(ctx context.Context, cfg *ClientConfig) (err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("pinecone.Init: %w", err)
 		}
 	}()
-
-	client, err := newClient(ctx, apiKey)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.initted {
+		panic("pinecone.Init already called")
+	}
+	if cfg == nil {
+		cfg = &ClientConfig{}
+	}
+	client, err := newClient(ctx, cfg.APIKey, cfg.Secrets)
 	if err != nil {
 		return err
 	}
@@ -89,7 +103,13 @@ func DefineIndexer(ctx context.Context, g *genkit.Genkit, cfg Config) (ai.Indexe
 	if err != nil {
 		return nil, err
 	}
-	return genkit.DefineIndexer(g, provider, cfg.IndexID, ds.Index), nil
+	// The indexer and retriever for an index share a health checker.
+	check := !IsDefinedRetriever(g, cfg.IndexID)
+	ix := genkit.DefineIndexer(g, provider, cfg.IndexID, ds.Index)
+	if check {
+		registerHealthChecker(g, cfg.IndexID)
+	}
+	return ix, nil
 }
 
 // DefineRetriever defines a Retriever with the given configuration.
@@ -98,7 +118,32 @@ func DefineRetriever(ctx context.Context, g *genkit.Genkit, cfg Config) (ai.Retr
 	if err != nil {
 		return nil, err
 	}
-	return genkit.DefineRetriever(g, provider, cfg.IndexID, ds.Retrieve), nil
+	// The indexer and retriever for an index share a health checker.
+	check := !IsDefinedIndexer(g, cfg.IndexID)
+	rt := genkit.DefineRetriever(g, provider, cfg.IndexID, ds.Retrieve)
+	if check {
+		registerHealthChecker(g, cfg.IndexID)
+	}
+	return rt, nil
+}
+
+// registerHealthChecker registers a health checker that verifies that
+// the index exists and is ready. The indexer and retriever for an index
+// share the checker.
+func registerHealthChecker(g *genkit.Genkit, indexID string) {
+	genkit.RegisterHealthChecker(g, provider+"/"+indexID, genkit.HealthCheckerFunc(func(ctx context.Context) error {
+		state.mu.Lock()
+		client := state.client
+		state.mu.Unlock()
+		data, err := client.indexData(ctx, indexID)
+		if err != nil {
+			return err
+		}
+		if !data.Status.Ready {
+			return fmt.Errorf("pinecone index %q is not ready (state %q)", indexID, data.Status.State)
+		}
+		return nil
+	}))
 }
 
 // IsDefinedIndexer reports whether the named [Indexer] is defined by this plugin.
@@ -44,7 +44,7 @@ func TestGenkit(t *testing.T) {
 
 	// Get information about the index.
 
-	client, err := newClient(ctx, *testAPIKey)
+	client, err := newClient(ctx, *testAPIKey, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -78,7 +78,7 @@ func TestGenkit(t *testing.T) {
 	embedder.Register(d2, v2)
 	embedder.Register(d3, v3)
 
-	if err := Init(ctx, *testAPIKey); err != nil {
+	if err := Init(ctx, &ClientConfig{APIKey: *testAPIKey}); err != nil {
 		t.Fatal(err)
 	}
 	cfg := Config{
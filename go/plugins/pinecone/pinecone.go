@@ -15,9 +15,10 @@
 // Package pinecone implements a genkit plugin for the Pinecone vector
 // database. This defines an indexer and a retriever.
 //
-// Accessing Pinecone requires an API key, passed via [Config].
+// Accessing Pinecone requires an API key, passed via [ClientConfig].
 // If the API key is the empty string, the plugin will use the
-// PINECONE_API_KEY environment variable.
+// PINECONE_API_KEY environment variable, or the secret of that name in
+// [ClientConfig.Secrets].
 //
 // All Pinecone data is stored in what Pinecone calls an index.
 // The Pinecone plugin supports a single index, passed via [Config].
@@ -36,7 +37,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"sync"
+
+	"github.com/firebase/genkit/go/plugins/secrets"
 )
 
 // Set pineconeDebug to true to dump data sent to and received from the server.
@@ -45,28 +48,53 @@ const pineconeDebug = false
 // apiServer is the Pinecone API server.
 const apiServer = "api.pinecone.io"
 
-// apiKeyEnv is the environment variable to use for the API key.
+// apiKeyEnv is the environment variable, or secret name, to use for the API key.
 const apiKeyEnv = "PINECONE_API_KEY"
 
 // A client is used to perform database operations.
 type client struct {
+	mu     sync.Mutex
 	apiKey string
 }
 
 // newClient builds a client.
 //
 // apiKey is the API key to use to access Pinecone.
-// If it is the empty string, it is read from the PINECONE_API_KEY
-// environment variable.
-func newClient(ctx context.Context, apiKey string) (*client, error) {
-	key, err := resolveAPIKey(apiKey)
+// If it is the empty string, it is the PINECONE_API_KEY secret in p,
+// or in the environment if p is nil. If p is a [secrets.Watcher],
+// the client switches to the new key when it is rotated.
+func newClient(ctx context.Context, apiKey string, p secrets.Provider) (*client, error) {
+	if apiKey != "" {
+		return &client{apiKey: apiKey}, nil
+	}
+	if p == nil {
+		p = secrets.Env()
+	}
+	key, err := p.Secret(ctx, apiKeyEnv)
+	if errors.Is(err, secrets.ErrNotFound) {
+		return nil, fmt.Errorf("pinecone API key not set; try setting %s", apiKeyEnv)
+	}
 	if err != nil {
 		return nil, err
 	}
-	client := &client{
-		apiKey: key,
+	c := &client{apiKey: key}
+	if w, ok := p.(secrets.Watcher); ok {
+		w.Watch(apiKeyEnv, c.setAPIKey)
 	}
-	return client, nil
+	return c, nil
+}
+
+// key returns the current API key.
+func (c *client) key() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.apiKey
+}
+
+func (c *client) setAPIKey(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.apiKey = key
 }
 
 // An indexData contains information about a single Pinecone index.
@@ -336,7 +364,7 @@ func (c *client) fetchData(ctx context.Context, url string, result any) error {
 	if err != nil {
 		return err
 	}
-	req.Header.Add("Api-Key", c.apiKey)
+	req.Header.Add("Api-Key", c.key())
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -370,7 +398,7 @@ func (c *client) postData(ctx context.Context, url string, post, result any) err
 	if err != nil {
 		return err
 	}
-	req.Header.Add("Api-Key", c.apiKey)
+	req.Header.Add("Api-Key", c.key())
 	req.Header.Add("Content-Type", "application/json")
 
 	encode := func() error {
@@ -467,15 +495,3 @@ func (c *client) serverError(r io.Reader) error {
 	}
 	return fmt.Errorf("pinecone error %d: %s", msg.Code, msg.Message)
 }
-
-// resolveAPIKey reads the API key from the environment if necessary.
-func resolveAPIKey(apiKey string) (string, error) {
-	if apiKey != "" {
-		return apiKey, nil
-	}
-	key := os.Getenv(apiKeyEnv)
-	if key == "" {
-		return "", fmt.Errorf("pinecone API key not set; try setting %s", apiKeyEnv)
-	}
-	return key, nil
-}
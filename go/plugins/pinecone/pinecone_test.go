@@ -17,9 +17,13 @@ package pinecone
 import (
 	"context"
 	"flag"
+	"os"
+	"path/filepath"
 	"slices"
 	"testing"
 	"time"
+
+	"github.com/firebase/genkit/go/plugins/secrets"
 )
 
 var (
@@ -41,7 +45,7 @@ func TestPinecone(t *testing.T) {
 
 	ctx := context.Background()
 
-	c, err := newClient(ctx, *testAPIKey)
+	c, err := newClient(ctx, *testAPIKey, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -140,3 +144,34 @@ func TestPinecone(t *testing.T) {
 		t.Errorf("got IDs %v, expected %v", got, want)
 	}
 }
+
+func TestClientFromSecrets(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), ".env")
+	write := func(key string) {
+		if err := os.WriteFile(path, []byte(apiKeyEnv+"="+key), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("key1")
+	r := secrets.NewRefresher(secrets.Dotenv(path), 0)
+	defer r.Close()
+	c, err := newClient(ctx, "", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.key(); got != "key1" {
+		t.Errorf("got key %q, want key1", got)
+	}
+	write("key2")
+	if err := r.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.key(); got != "key2" {
+		t.Errorf("after rotation, got key %q, want key2", got)
+	}
+
+	if _, err := newClient(ctx, "", secrets.Dir(t.TempDir())); err == nil {
+		t.Error("got nil error for missing key")
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promptguard
+
+import "regexp"
+
+// A Rule is a heuristic that flags text matching Pattern.
+type Rule struct {
+	// Name identifies the rule in findings.
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// DefaultRules returns the heuristic rules used when [Config.Rules] is nil.
+// They look for common ways of addressing the model from inside content:
+// instructions to ignore earlier instructions, attempts to reassign the
+// model's role, chat-template control tokens, requests to reveal the system
+// prompt, and invisible characters used to hide text.
+//
+// To add rules of your own, append to the result.
+func DefaultRules() []Rule {
+	return []Rule{
+		{"ignore-instructions", regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override|skip)\b[\w\s,]{0,30}?\b(previous|prior|above|earlier|preceding|all|any|your|the|system)\b[\w\s]{0,20}?\b(instructions?|prompts?|directions?|rules|guidelines|context)\b`)},
+		{"new-instructions", regexp.MustCompile(`(?i)\b(new|updated|real|actual|revised)\s+(system\s+)?(instructions?|prompt|directives?)\s*:`)},
+		{"role-reassignment", regexp.MustCompile(`(?i)\b(you are now|from now on,? you (are|will|must)|pretend (to be|you are)|act as (if you are|an? unrestricted)|enter (developer|dan|jailbreak) mode)\b`)},
+		{"role-marker", regexp.MustCompile(`(?im)^\s*(system|assistant|developer)\s*:|<\|?(im_start|im_end|system|endoftext)\|?>|\[/?INST\]|<</?SYS>>`)},
+		{"prompt-exfiltration", regexp.MustCompile(`(?i)\b(reveal|print|show|repeat|output|leak)\b[\w\s]{0,20}?\b(system prompt|initial prompt|hidden instructions|your instructions)\b`)},
+		{"conceal-from-user", regexp.MustCompile(`(?i)\b(do not|don't|never)\s+(tell|inform|mention (this )?to|alert|show)\s+the\s+user\b`)},
+		{"hidden-characters", regexp.MustCompile(`[\x{E0000}-\x{E007F}]|[\x{200B}-\x{200D}\x{2060}\x{FEFF}]{3,}`)},
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit keeps calls to a model within the provider's quota.
+//
+// A [Limiter] holds two token buckets, one for requests per minute and one
+// for tokens per minute. Each call waits, in arrival order, until both
+// buckets have room for it. The token cost of a call is estimated before
+// it is made and corrected with the reported usage afterwards.
+//
+// When the provider rejects a call for exceeding its quota anyway, the
+// limiter stops all callers for the time the provider asks (for instance
+// with a Retry-After header) and then retries the call.
+//
+// Model plugins such as googleai and vertexai accept [Limits] for each model
+// in their configuration, and install the limiter in the model action so that
+// it is shared by all callers of the model.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+)
+
+// Limits describes a model's quota.
+type Limits struct {
+	// RequestsPerMinute is the number of calls allowed per minute.
+	// Zero means no limit.
+	RequestsPerMinute int
+	// TokensPerMinute is the number of input and output tokens allowed
+	// per minute. Zero means no limit.
+	TokensPerMinute int
+	// EstimateTokens estimates the tokens a request will use.
+	// If nil, [EstimateTokens] is used.
+	EstimateTokens func(*ai.ModelRequest) int
+	// MaxRetries is the number of times a call rejected for exceeding
+	// quota is retried. The default is 3. Use a negative value to disable
+	// retries.
+	MaxRetries int
+}
+
+// A ModelFunc generates a response to a model request.
+// It has the signature of the function passed to [genkit.DefineModel].
+type ModelFunc = func(context.Context, *ai.ModelRequest, ai.ModelStreamingCallback) (*ai.ModelResponse, error)
+
+// A Limiter limits the rate of calls to a model.
+// It is safe for concurrent use.
+type Limiter struct {
+	limits Limits
+
+	mu       sync.Mutex
+	requests *bucket // nil if unlimited
+	tokens   *bucket // nil if unlimited
+	// pausedUntil is when the provider said to resume after a quota error.
+	pausedUntil time.Time
+
+	// For testing.
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+}
+
+// New returns a Limiter for the given limits.
+func New(limits Limits) *Limiter {
+	if limits.EstimateTokens == nil {
+		limits.EstimateTokens = EstimateTokens
+	}
+	if limits.MaxRetries == 0 {
+		limits.MaxRetries = 3
+	}
+	l := &Limiter{limits: limits, now: time.Now, after: time.After}
+	now := l.now()
+	if n := limits.RequestsPerMinute; n > 0 {
+		l.requests = newBucket(n, now)
+	}
+	if n := limits.TokensPerMinute; n > 0 {
+		l.tokens = newBucket(n, now)
+	}
+	return l
+}
+
+// ForModel returns generate limited by limits[name], or generate itself if
+// limits has no entry for name. Plugins call it once, when they define the
+// model, so that all calls of the model's action share one [Limiter].
+func ForModel(limits map[string]Limits, name string, generate ModelFunc) ModelFunc {
+	l, ok := limits[name]
+	if !ok {
+		return generate
+	}
+	return New(l).Wrap(generate)
+}
+
+// Wrap returns a model function that calls generate within l's limits.
+//
+// If generate fails because the provider's quota was exceeded, the
+// returned function waits as long as the provider asks and calls generate
+// again, up to the configured number of retries. A call that has already
+// streamed chunks is not retried.
+func (l *Limiter) Wrap(generate ModelFunc) ModelFunc {
+	return func(ctx context.Context, req *ai.ModelRequest, cb ai.ModelStreamingCallback) (*ai.ModelResponse, error) {
+		estimate := l.estimate(req)
+		for attempt := 0; ; attempt++ {
+			if err := l.wait(ctx, estimate); err != nil {
+				return nil, err
+			}
+			streamed := false
+			scb := cb
+			if cb != nil {
+				scb = func(ctx context.Context, c *ai.ModelResponseChunk) error {
+					streamed = true
+					return cb(ctx, c)
+				}
+			}
+			resp, err := generate(ctx, req, scb)
+			l.reconcile(estimate, resp)
+			if err == nil {
+				return resp, nil
+			}
+			delay, ok := quotaError(err)
+			if !ok || streamed || l.limits.MaxRetries < 0 || attempt >= l.limits.MaxRetries {
+				return nil, err
+			}
+			l.pause(delay, attempt)
+		}
+	}
+}
+
+// estimate returns the estimated tokens for req, capped at the tokens per
+// minute so that a large request can still run.
+func (l *Limiter) estimate(req *ai.ModelRequest) int {
+	n := l.limits.EstimateTokens(req)
+	if l.tokens != nil {
+		n = min(n, l.limits.TokensPerMinute)
+	}
+	return n
+}
+
+// wait reserves one request and estimate tokens, and waits until they are
+// available. If ctx is done first, the reservation is returned.
+func (l *Limiter) wait(ctx context.Context, estimate int) error {
+	l.mu.Lock()
+	now := l.now()
+	var d time.Duration
+	if l.requests != nil {
+		d = max(d, l.requests.take(now, 1))
+	}
+	if l.tokens != nil {
+		d = max(d, l.tokens.take(now, float64(estimate)))
+	}
+	d = max(d, l.pausedUntil.Sub(now))
+	l.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-l.after(d):
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		now := l.now()
+		if l.requests != nil {
+			l.requests.put(now, 1)
+		}
+		if l.tokens != nil {
+			l.tokens.put(now, float64(estimate))
+		}
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// reconcile corrects the token bucket with the usage reported in resp.
+func (l *Limiter) reconcile(estimate int, resp *ai.ModelResponse) {
+	if l.tokens == nil || resp == nil || resp.Usage == nil {
+		return
+	}
+	used := resp.Usage.TotalTokens
+	if used == 0 {
+		used = resp.Usage.InputTokens + resp.Usage.OutputTokens
+	}
+	if used == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// A positive difference returns unused tokens; a negative one takes more.
+	l.tokens.put(l.now(), float64(estimate-used))
+}
+
+// pause stops all calls for delay, or for an exponentially growing
+// interval if the provider did not say how long to wait.
+func (l *Limiter) pause(delay time.Duration, attempt int) {
+	if delay <= 0 {
+		delay = time.Second << attempt
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t := l.now().Add(delay); t.After(l.pausedUntil) {
+		l.pausedUntil = t
+	}
+}
+
+// EstimateTokens estimates the tokens used by req: about one token for
+// every four characters of text, plus the maximum number of output tokens
+// if the request's config sets one.
+func EstimateTokens(req *ai.ModelRequest) int {
+	chars := 0
+	for _, m := range req.Messages {
+		for _, p := range m.Content {
+			chars += len(p.Text)
+		}
+	}
+	n := (chars + 3) / 4
+	switch c := req.Config.(type) {
+	case *ai.GenerationCommonConfig:
+		if c != nil {
+			n += c.MaxOutputTokens
+		}
+	case ai.GenerationCommonConfig:
+		n += c.MaxOutputTokens
+	}
+	return max(n, 1)
+}
+
+// quotaError reports whether err means that a quota was exceeded, and how
+// long the provider asked the caller to wait, if it said.
+func quotaError(err error) (time.Duration, bool) {
+	var ra interface{ RetryAfter() time.Duration }
+	if errors.As(err, &ra) {
+		return ra.RetryAfter(), true
+	}
+	var herr *googleapi.Error
+	if errors.As(err, &herr) && herr.Code == http.StatusTooManyRequests {
+		if d, ok := parseRetryAfter(herr.Header.Get("Retry-After"), time.Now()); ok {
+			return d, true
+		}
+	}
+	ae, ok := apierror.FromError(err)
+	if !ok || (ae.HTTPCode() != http.StatusTooManyRequests && ae.GRPCStatus().Code() != codes.ResourceExhausted) {
+		return 0, false
+	}
+	if ri := ae.Details().RetryInfo; ri != nil {
+		return ri.GetRetryDelay().AsDuration(), true
+	}
+	return 0, true
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either
+// a number of seconds or an HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// A bucket is a token bucket that refills its capacity every minute.
+// Its level may go negative: takes are granted in order, and each
+// taker waits until the level would have returned to zero.
+type bucket struct {
+	capacity float64
+	level    float64
+	last     time.Time
+}
+
+func newBucket(perMinute int, now time.Time) *bucket {
+	return &bucket{capacity: float64(perMinute), level: float64(perMinute), last: now}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.level = min(b.capacity, b.level+b.capacity*now.Sub(b.last).Minutes())
+		b.last = now
+	}
+}
+
+// take removes n tokens and returns how long until the bucket is
+// no longer in debt.
+func (b *bucket) take(now time.Time, n float64) time.Duration {
+	b.refill(now)
+	b.level -= n
+	if b.level >= 0 {
+		return 0
+	}
+	return time.Duration(-b.level / b.capacity * float64(time.Minute))
+}
+
+// put returns n tokens to the bucket. A negative n takes tokens.
+func (b *bucket) put(now time.Time, n float64) {
+	b.refill(now)
+	b.level = min(b.capacity, b.level+n)
+}
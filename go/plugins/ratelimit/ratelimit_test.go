@@ -0,0 +1,254 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// fakeClock makes waits return at once, advancing the time and
+// recording how long each wait was.
+type fakeClock struct {
+	t     time.Time
+	waits []time.Duration
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) after(d time.Duration) <-chan time.Time {
+	c.waits = append(c.waits, d.Round(time.Millisecond))
+	c.t = c.t.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.t
+	return ch
+}
+
+func newTestLimiter(limits Limits) (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := New(limits)
+	l.now, l.after = clock.now, clock.after
+	l.requests, l.tokens = nil, nil
+	if n := limits.RequestsPerMinute; n > 0 {
+		l.requests = newBucket(n, clock.t)
+	}
+	if n := limits.TokensPerMinute; n > 0 {
+		l.tokens = newBucket(n, clock.t)
+	}
+	return l, clock
+}
+
+func textRequest(chars int) *ai.ModelRequest {
+	return &ai.ModelRequest{Messages: []*ai.Message{ai.NewUserTextMessage(fmt.Sprintf("%*s", chars, ""))}}
+}
+
+func respond(usage int) ModelFunc {
+	return func(_ context.Context, req *ai.ModelRequest, _ ai.ModelStreamingCallback) (*ai.ModelResponse, error) {
+		return &ai.ModelResponse{Request: req, Usage: &ai.GenerationUsage{TotalTokens: usage}}, nil
+	}
+}
+
+func TestRequestsPerMinute(t *testing.T) {
+	ctx := context.Background()
+	l, clock := newTestLimiter(Limits{RequestsPerMinute: 2})
+	gen := l.Wrap(respond(0))
+	for range 4 {
+		if _, err := gen(ctx, textRequest(4), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Two calls are allowed at once; then one every 30 seconds.
+	if want := []time.Duration{30 * time.Second, 30 * time.Second}; !slices.Equal(clock.waits, want) {
+		t.Errorf("got waits %v, want %v", clock.waits, want)
+	}
+}
+
+func TestTokensPerMinute(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name  string
+		usage int
+		calls int // calls before one has to wait
+	}{
+		// Each request is estimated at 50 tokens.
+		{"as estimated", 50, 2},
+		{"more than estimated", 75, 1},
+		{"less than estimated", 25, 3},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			l, clock := newTestLimiter(Limits{TokensPerMinute: 100})
+			gen := l.Wrap(respond(test.usage))
+			for i := 0; len(clock.waits) == 0; i++ {
+				if i > test.calls {
+					t.Fatalf("no wait after %d calls", i)
+				}
+				if _, err := gen(ctx, textRequest(200), nil); err != nil {
+					t.Fatal(err)
+				}
+				if len(clock.waits) > 0 && i != test.calls {
+					t.Fatalf("call %d waited, want call %d to be the first", i, test.calls)
+				}
+			}
+		})
+	}
+
+	// A request larger than the limit waits for a full bucket rather than forever.
+	l, clock := newTestLimiter(Limits{TokensPerMinute: 100})
+	gen := l.Wrap(respond(0))
+	for range 2 {
+		if _, err := gen(ctx, textRequest(4000), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []time.Duration{time.Minute}; !slices.Equal(clock.waits, want) {
+		t.Errorf("got waits %v, want %v", clock.waits, want)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	ctx := context.Background()
+	l, clock := newTestLimiter(Limits{RequestsPerMinute: 600})
+	calls := 0
+	gen := l.Wrap(func(ctx context.Context, req *ai.ModelRequest, cb ai.ModelStreamingCallback) (*ai.ModelResponse, error) {
+		calls++
+		if calls == 1 {
+			return nil, &googleapi.Error{Code: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"7"}}}
+		}
+		return respond(0)(ctx, req, cb)
+	})
+	if _, err := gen(ctx, textRequest(4), nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls, want 2", calls)
+	}
+	if want := []time.Duration{7 * time.Second}; !slices.Equal(clock.waits, want) {
+		t.Errorf("got waits %v, want %v", clock.waits, want)
+	}
+
+	// Other errors, and quota errors after streaming, are not retried.
+	errOther := errors.New("other")
+	calls = 0
+	gen = l.Wrap(func(ctx context.Context, _ *ai.ModelRequest, cb ai.ModelStreamingCallback) (*ai.ModelResponse, error) {
+		calls++
+		if cb != nil {
+			cb(ctx, &ai.ModelResponseChunk{})
+			return nil, &googleapi.Error{Code: http.StatusTooManyRequests}
+		}
+		return nil, errOther
+	})
+	if _, err := gen(ctx, textRequest(4), nil); err != errOther {
+		t.Errorf("got %v, want %v", err, errOther)
+	}
+	if _, err := gen(ctx, textRequest(4), func(context.Context, *ai.ModelResponseChunk) error { return nil }); err == nil {
+		t.Error("got nil error after streaming")
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls, want 2", calls)
+	}
+}
+
+func TestForModel(t *testing.T) {
+	ctx := context.Background()
+	estimates := 0
+	limits := map[string]Limits{"limited": {EstimateTokens: func(*ai.ModelRequest) int {
+		estimates++
+		return 0
+	}}}
+	for _, name := range []string{"limited", "unlimited"} {
+		if _, err := ForModel(limits, name, respond(0))(ctx, textRequest(4), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Only the limited model's calls go through a Limiter.
+	if estimates != 1 {
+		t.Errorf("got %d token estimates, want 1", estimates)
+	}
+}
+
+func TestWaitCanceled(t *testing.T) {
+	l := New(Limits{RequestsPerMinute: 1})
+	gen := l.Wrap(respond(0))
+	if _, err := gen(context.Background(), textRequest(4), nil); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := gen(ctx, textRequest(4), nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want DeadlineExceeded", err)
+	}
+	// The canceled call's reservation was returned.
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if got := l.requests.level; got < -0.01 || got > 0.01 {
+		t.Errorf("got bucket level %g, want about 0", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"", 0, false},
+		{"12", 12 * time.Second, true},
+		{"Mon, 01 Jan 2024 00:00:30 GMT", 30 * time.Second, true},
+		{"soon", 0, false},
+	} {
+		got, ok := parseRetryAfter(test.in, now)
+		if got != test.want || ok != test.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %t; want %v, %t", test.in, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestQuotaError(t *testing.T) {
+	st, err := status.New(codes.ResourceExhausted, "quota").WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(5 * time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name  string
+		err   error
+		delay time.Duration
+		ok    bool
+	}{
+		{"other", errors.New("x"), 0, false},
+		{"http 429", &googleapi.Error{Code: http.StatusTooManyRequests}, 0, true},
+		{"http 500", &googleapi.Error{Code: http.StatusInternalServerError}, 0, false},
+		{"grpc", fmt.Errorf("wrapped: %w", st.Err()), 5 * time.Second, true},
+		{"grpc no details", status.Error(codes.ResourceExhausted, "quota"), 0, true},
+		{"grpc other", status.Error(codes.Internal, "x"), 0, false},
+	} {
+		delay, ok := quotaError(test.err)
+		if delay != test.delay || ok != test.ok {
+			t.Errorf("%s: got %v, %t; want %v, %t", test.name, delay, ok, test.delay, test.ok)
+		}
+	}
+}
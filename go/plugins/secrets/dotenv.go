@@ -0,0 +1,123 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Dotenv returns a provider that reads secrets from a .env file.
+// The file is read on each lookup, so edits to it are seen by a [Refresher].
+//
+// Each line has the form NAME=VALUE, optionally preceded by "export".
+// Blank lines and lines starting with '#' are ignored.
+// A value may be enclosed in double quotes, in which case Go escape
+// sequences such as \n are interpreted, or in single quotes, in which case
+// it is used as is. An unquoted value ends at a " #" comment.
+// If the file does not exist, the provider has no secrets.
+func Dotenv(path string) Provider { return dotenvProvider(path) }
+
+type dotenvProvider string
+
+func (p dotenvProvider) Secret(_ context.Context, name string) (string, error) {
+	vars, err := parseDotenv(string(p))
+	if errors.Is(err, os.ErrNotExist) {
+		// A missing file has no secrets.
+		return "", notFound(name)
+	}
+	if err != nil {
+		return "", err
+	}
+	if v, ok := vars[name]; ok {
+		return v, nil
+	}
+	return "", notFound(name)
+}
+
+// parseDotenv returns the variables defined in the .env file at path.
+func parseDotenv(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	vars := map[string]string{}
+	s := bufio.NewScanner(f)
+	for lineno := 1; s.Scan(); lineno++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%s:%d: expected NAME=VALUE", path, lineno)
+		}
+		value, err := parseDotenvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineno, err)
+		}
+		vars[name] = value
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+func parseDotenvValue(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	switch q := v[0]; q {
+	case '"', '\'':
+		end := closingQuote(v)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted value")
+		}
+		if rest := strings.TrimSpace(v[end+1:]); rest != "" && rest[0] != '#' {
+			return "", fmt.Errorf("unexpected text after quoted value")
+		}
+		if q == '\'' {
+			return v[1:end], nil
+		}
+		return strconv.Unquote(v[:end+1])
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = strings.TrimSpace(v[:i])
+	}
+	return v, nil
+}
+
+// closingQuote returns the index of the quote that closes the one at v[0],
+// or -1 if there is none. Backslashes escape characters only within double quotes.
+func closingQuote(v string) int {
+	for i := 1; i < len(v); i++ {
+		switch {
+		case v[i] == v[0]:
+			return i
+		case v[i] == '\\' && v[0] == '"':
+			i++
+		}
+	}
+	return -1
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/firebase/genkit/go/core/logger"
+)
+
+// A Refresher caches the secrets of another provider and periodically
+// reads them again, so that rotated secrets are picked up without a
+// restart. It implements [Watcher].
+//
+// Only secrets that have been looked up are refreshed.
+// If a refresh fails, the previous value is kept.
+type Refresher struct {
+	p    Provider
+	stop chan struct{}
+	once sync.Once
+
+	mu       sync.Mutex
+	values   map[string]string
+	watchers map[string][]func(string)
+}
+
+// NewRefresher returns a Refresher that reads secrets from p and refreshes
+// them every interval. If interval is not positive, secrets are refreshed
+// only when [Refresher.Refresh] is called.
+// Call [Refresher.Close] to stop refreshing.
+func NewRefresher(p Provider, interval time.Duration) *Refresher {
+	r := &Refresher{
+		p:        p,
+		stop:     make(chan struct{}),
+		values:   map[string]string{},
+		watchers: map[string][]func(string){},
+	}
+	if interval > 0 {
+		go r.loop(interval)
+	}
+	return r
+}
+
+func (r *Refresher) loop(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-t.C:
+			ctx := context.Background()
+			if err := r.Refresh(ctx); err != nil {
+				logger.FromContext(ctx).Error("secrets: refresh failed", "err", err)
+			}
+		}
+	}
+}
+
+// Secret returns the cached value of the named secret, reading it from the
+// underlying provider the first time.
+func (r *Refresher) Secret(ctx context.Context, name string) (string, error) {
+	r.mu.Lock()
+	v, ok := r.values[name]
+	r.mu.Unlock()
+	if ok {
+		return v, nil
+	}
+	v, err := r.p.Secret(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Keep a value stored by a concurrent lookup or refresh.
+	if cur, ok := r.values[name]; ok {
+		return cur, nil
+	}
+	r.values[name] = v
+	return v, nil
+}
+
+// Watch arranges for f to be called with the new value of the named secret
+// whenever a refresh finds that it has changed. The secret is refreshed
+// from then on even if it has not been looked up.
+func (r *Refresher) Watch(name string, f func(value string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.watchers[name] = append(r.watchers[name], f)
+}
+
+// Refresh reads all cached and watched secrets from the underlying provider
+// and calls the watchers of those that changed. It returns the errors of
+// any reads that failed.
+func (r *Refresher) Refresh(ctx context.Context) error {
+	r.mu.Lock()
+	names := map[string]bool{}
+	for name := range r.values {
+		names[name] = true
+	}
+	for name := range r.watchers {
+		names[name] = true
+	}
+	r.mu.Unlock()
+
+	var errs []error
+	for name := range names {
+		v, err := r.p.Secret(ctx, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		r.mu.Lock()
+		old, had := r.values[name]
+		r.values[name] = v
+		watchers := r.watchers[name]
+		r.mu.Unlock()
+		if had && old != v {
+			for _, f := range watchers {
+				f(v)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close stops the periodic refresh.
+func (r *Refresher) Close() {
+	r.once.Do(func() { close(r.stop) })
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	secretmanager "google.golang.org/api/secretmanager/v1"
+)
+
+// SecretManager returns a provider that reads secrets from Google Cloud
+// Secret Manager in the given project, using Application Default
+// Credentials unless opts say otherwise.
+//
+// A name that begins with "projects/" is used as the full resource name of
+// a secret or secret version. Any other name is the ID of a secret in
+// projectID. If no version is given, the latest version is read.
+func SecretManager(ctx context.Context, projectID string, opts ...option.ClientOption) (Provider, error) {
+	svc, err := secretmanager.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("secrets.SecretManager: %w", err)
+	}
+	return &secretManager{projectID: projectID, versions: svc.Projects.Secrets.Versions}, nil
+}
+
+type secretManager struct {
+	projectID string
+	versions  *secretmanager.ProjectsSecretsVersionsService
+}
+
+func (s *secretManager) Secret(ctx context.Context, name string) (string, error) {
+	res, err := s.versions.Access(s.resourceName(name)).Context(ctx).Do()
+	if err != nil {
+		var gerr *googleapi.Error
+		if errors.As(err, &gerr) && gerr.Code == http.StatusNotFound {
+			return "", notFound(name)
+		}
+		return "", fmt.Errorf("secret %q: %w", name, err)
+	}
+	if res.Payload == nil {
+		return "", fmt.Errorf("secret %q: response has no payload", name)
+	}
+	data, err := base64.StdEncoding.DecodeString(res.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("secret %q: %w", name, err)
+	}
+	if c := res.Payload.DataCrc32c; c != 0 && int64(crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))) != c {
+		return "", fmt.Errorf("secret %q: payload checksum mismatch", name)
+	}
+	return string(data), nil
+}
+
+func (s *secretManager) resourceName(name string) string {
+	if !strings.HasPrefix(name, "projects/") {
+		name = fmt.Sprintf("projects/%s/secrets/%s", s.projectID, name)
+	}
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	return name
+}
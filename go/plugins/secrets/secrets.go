@@ -0,0 +1,132 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secrets provides the API keys and other secrets that plugins
+// need to reach their services.
+//
+// Plugins that take a [Provider] in their configuration look up their
+// secrets by the names of the environment variables they have always used,
+// such as GOOGLE_GENAI_API_KEY, so the same names work with every provider.
+// The default is [Env]. Other providers read mounted secret files ([Dir]),
+// .env files ([Dotenv]) or Google Cloud Secret Manager ([SecretManager]),
+// and [Chain] combines several of them.
+//
+// The googleai plugin takes a provider in Config.Secrets, and the pinecone
+// and weaviate plugins in ClientConfig.Secrets. The vertexai and ollama
+// plugins use no API keys: Vertex AI authenticates with Application Default
+// Credentials or the client options in its Config, and Ollama servers are
+// unauthenticated.
+//
+// Wrap a provider in a [Refresher] to pick up rotated secrets while the
+// program runs.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned, possibly wrapped, by providers that do not have
+// the requested secret.
+var ErrNotFound = errors.New("secret not found")
+
+// A Provider looks up secrets by name.
+type Provider interface {
+	// Secret returns the current value of the named secret.
+	// If there is no such secret, the error wraps [ErrNotFound].
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// A Watcher is a [Provider] that reports changes to secrets.
+// Plugins use it to switch to a rotated key without restarting.
+type Watcher interface {
+	Provider
+	// Watch arranges for f to be called with the new value of the named
+	// secret each time it changes.
+	Watch(name string, f func(value string))
+}
+
+func notFound(name string) error {
+	return fmt.Errorf("secret %q: %w", name, ErrNotFound)
+}
+
+// Lookup returns the first of the named secrets that p has, and its name.
+// It is a convenience for plugins that accept a secret under several names.
+// If p has none of them, the error wraps [ErrNotFound].
+func Lookup(ctx context.Context, p Provider, names ...string) (name, value string, err error) {
+	for _, name := range names {
+		value, err := p.Secret(ctx, name)
+		if err == nil {
+			return name, value, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return "", "", err
+		}
+	}
+	return "", "", fmt.Errorf("none of %s: %w", strings.Join(names, ", "), ErrNotFound)
+}
+
+// Env returns a provider that reads secrets from environment variables.
+// An empty variable counts as missing.
+func Env() Provider { return envProvider{} }
+
+type envProvider struct{}
+
+func (envProvider) Secret(_ context.Context, name string) (string, error) {
+	if v := os.Getenv(name); v != "" {
+		return v, nil
+	}
+	return "", notFound(name)
+}
+
+// Dir returns a provider that reads each secret from the file of the same
+// name in dir, as in the secret volumes mounted by Kubernetes, Docker and
+// Cloud Run. Trailing newlines are removed.
+func Dir(dir string) Provider { return dirProvider(dir) }
+
+type dirProvider string
+
+func (d dirProvider) Secret(_ context.Context, name string) (string, error) {
+	if name == "" || !filepath.IsLocal(name) || filepath.Base(name) != name {
+		return "", fmt.Errorf("secret %q: invalid name", name)
+	}
+	b, err := os.ReadFile(filepath.Join(string(d), name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", notFound(name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// Chain returns a provider that tries each of ps in order, returning the
+// first value found.
+func Chain(ps ...Provider) Provider { return chain(ps) }
+
+type chain []Provider
+
+func (c chain) Secret(ctx context.Context, name string) (string, error) {
+	for _, p := range c {
+		v, err := p.Secret(ctx, name)
+		if !errors.Is(err, ErrNotFound) {
+			return v, err
+		}
+	}
+	return "", notFound(name)
+}
@@ -0,0 +1,198 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/option"
+)
+
+func TestProviders(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("SECRETS_TEST_ENV", "from-env")
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "FILE_KEY"), "from-file\n")
+
+	dotenv := filepath.Join(t.TempDir(), ".env")
+	writeFile(t, dotenv, `
+# comment
+PLAIN=plain value # trailing comment
+export EXPORTED=exported
+DOUBLE="line1\nline2 # not a comment"
+SINGLE='raw\n'
+EMPTY=
+`)
+	missing := filepath.Join(t.TempDir(), ".env")
+
+	for _, test := range []struct {
+		p    Provider
+		name string
+		want string // empty means not found
+	}{
+		{Env(), "SECRETS_TEST_ENV", "from-env"},
+		{Env(), "SECRETS_TEST_MISSING", ""},
+		{Dir(dir), "FILE_KEY", "from-file"},
+		{Dir(dir), "MISSING", ""},
+		{Dotenv(dotenv), "PLAIN", "plain value"},
+		{Dotenv(dotenv), "EXPORTED", "exported"},
+		{Dotenv(dotenv), "DOUBLE", "line1\nline2 # not a comment"},
+		{Dotenv(dotenv), "SINGLE", `raw\n`},
+		{Dotenv(dotenv), "EMPTY", ""},
+		{Dotenv(dotenv), "MISSING", ""},
+		{Chain(Dir(dir), Env()), "SECRETS_TEST_ENV", "from-env"},
+		{Chain(Dir(dir), Env()), "FILE_KEY", "from-file"},
+		{Dotenv(missing), "SECRETS_TEST_ENV", ""},
+		{Chain(Dotenv(missing), Env()), "SECRETS_TEST_ENV", "from-env"},
+	} {
+		t.Run(fmt.Sprintf("%T/%s", test.p, test.name), func(t *testing.T) {
+			got, err := test.p.Secret(ctx, test.name)
+			if test.want == "" && test.name != "EMPTY" {
+				if !errors.Is(err, ErrNotFound) {
+					t.Fatalf("got (%q, %v), want ErrNotFound", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	if _, err := Dir(dir).Secret(ctx, "../FILE_KEY"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Dir with path outside dir: got %v, want invalid name error", err)
+	}
+	writeFile(t, dotenv, "NOVALUE\n")
+	if _, err := Dotenv(dotenv).Secret(ctx, "NOVALUE"); err == nil || !strings.Contains(err.Error(), ":1: expected NAME=VALUE") {
+		t.Errorf("got %v, want parse error", err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	t.Setenv("SECRETS_TEST_SECOND", "v2")
+	name, value, err := Lookup(context.Background(), Env(), "SECRETS_TEST_FIRST", "SECRETS_TEST_SECOND")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "SECRETS_TEST_SECOND" || value != "v2" {
+		t.Errorf("got (%q, %q), want (SECRETS_TEST_SECOND, v2)", name, value)
+	}
+	if _, _, err := Lookup(context.Background(), Env(), "SECRETS_TEST_FIRST"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestSecretManager(t *testing.T) {
+	// A fake Secret Manager service.
+	secrets := map[string]string{
+		"projects/proj/secrets/API_KEY/versions/latest": "s3cret",
+		"projects/other/secrets/KEY/versions/2":         "other",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/"), ":access")
+		v, ok := secrets[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": 404, "message": "not found"}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"name": %q, "payload": {"data": %q, "dataCrc32c": "%d"}}`,
+			name, base64.StdEncoding.EncodeToString([]byte(v)), crc32.Checksum([]byte(v), crc32.MakeTable(crc32.Castagnoli)))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	p, err := SecretManager(ctx, "proj", option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"API_KEY":                               "s3cret",
+		"projects/other/secrets/KEY/versions/2": "other",
+	} {
+		got, err := p.Secret(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+	if _, err := p.Secret(ctx, "MISSING"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestRefresher(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), ".env")
+	writeFile(t, path, "KEY=v1\n")
+	r := NewRefresher(Dotenv(path), 0)
+	defer r.Close()
+
+	var changes []string
+	r.Watch("KEY", func(v string) { changes = append(changes, v) })
+	if got, err := r.Secret(ctx, "KEY"); err != nil || got != "v1" {
+		t.Fatalf("got (%q, %v), want v1", got, err)
+	}
+
+	writeFile(t, path, "KEY=v2\n")
+	// Cached until refreshed.
+	if got, _ := r.Secret(ctx, "KEY"); got != "v1" {
+		t.Errorf("before refresh, got %q, want v1", got)
+	}
+	if err := r.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := r.Secret(ctx, "KEY"); got != "v2" {
+		t.Errorf("after refresh, got %q, want v2", got)
+	}
+	if err := r.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"v2"}; strings.Join(changes, ",") != strings.Join(want, ",") {
+		t.Errorf("got changes %v, want %v", changes, want)
+	}
+
+	// A failed refresh keeps the old value.
+	writeFile(t, path, "OTHER=x\n")
+	if err := r.Refresh(ctx); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+	if got, _ := r.Secret(ctx, "KEY"); got != "v2" {
+		t.Errorf("after failed refresh, got %q, want v2", got)
+	}
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
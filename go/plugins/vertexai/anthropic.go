@@ -0,0 +1,222 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vertexai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/plugins/internal/toolcalls"
+)
+
+// anthropicVersion is the version of the Anthropic messages API
+// that Vertex AI serves.
+const anthropicVersion = "vertex-2023-10-16"
+
+// The default maximum number of output tokens, which the Anthropic API requires.
+const anthropicMaxTokens = 4096
+
+type anthropicRequest struct {
+	AnthropicVersion string              `json:"anthropic_version"`
+	System           string              `json:"system,omitempty"`
+	Messages         []*anthropicMessage `json:"messages"`
+	Tools            []*anthropicTool    `json:"tools,omitempty"`
+	MaxTokens        int                 `json:"max_tokens"`
+	Temperature      float64             `json:"temperature,omitempty"`
+	TopP             float64             `json:"top_p,omitempty"`
+	TopK             int                 `json:"top_k,omitempty"`
+	StopSequences    []string            `json:"stop_sequences,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string            `json:"role"` // "user" or "assistant"
+	Content []*anthropicBlock `json:"content"`
+}
+
+// An anthropicBlock is a content block of a message.
+type anthropicBlock struct {
+	// One of "text", "image", "tool_use" or "tool_result".
+	Type   string           `json:"type"`
+	Text   string           `json:"text,omitempty"`
+	Source *anthropicSource `json:"source,omitempty"` // for images
+	// For tool_use.
+	ID    string `json:"id,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Input any    `json:"input,omitempty"` // a JSON object, which may be empty
+	// For tool_result.
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
+type anthropicSource struct {
+	Type      string `json:"type"` // "base64"
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type anthropicResponse struct {
+	Content    []*anthropicBlock `json:"content"`
+	StopReason string            `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+func (gm *gardenModel) generateAnthropic(ctx context.Context, input *ai.ModelRequest, cfg *ai.GenerationCommonConfig) (*ai.ModelResponse, error) {
+	// The Anthropic messages API has no way to set a seed.
+	if cfg.Seed != 0 {
+		return nil, &ai.UnsupportedConfigError{Model: provider + "/" + gm.name, Option: "seed"}
+	}
+	req, err := newAnthropicRequest(input, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("vertexai: %s: %w", gm.name, err)
+	}
+	var resp anthropicResponse
+	if err := gm.post(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	msg := &ai.Message{Role: ai.RoleModel}
+	for _, b := range resp.Content {
+		switch b.Type {
+		case "text":
+			msg.Content = append(msg.Content, ai.NewTextPart(b.Text))
+		case "tool_use":
+			input, _ := b.Input.(map[string]any)
+			msg.Content = append(msg.Content, ai.NewToolRequestPart(&ai.ToolRequest{Name: b.Name, Input: input}))
+		}
+	}
+	r := &ai.ModelResponse{
+		Message: msg,
+		Usage: &ai.GenerationUsage{
+			InputTokens:  resp.Usage.InputTokens,
+			OutputTokens: resp.Usage.OutputTokens,
+			TotalTokens:  resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		},
+	}
+	switch resp.StopReason {
+	case "end_turn", "stop_sequence", "tool_use":
+		r.FinishReason = ai.FinishReasonStop
+	case "max_tokens":
+		r.FinishReason = ai.FinishReasonLength
+	case "":
+		r.FinishReason = ai.FinishReasonUnknown
+	default:
+		r.FinishReason = ai.FinishReasonOther
+	}
+	return r, nil
+}
+
+// newAnthropicRequest converts a ModelRequest to an Anthropic messages request.
+// System messages become the system prompt. Tool requests are given IDs,
+// and tool responses are matched to them by tool name, in order.
+func newAnthropicRequest(input *ai.ModelRequest, cfg *ai.GenerationCommonConfig) (*anthropicRequest, error) {
+	req := &anthropicRequest{
+		AnthropicVersion: anthropicVersion,
+		MaxTokens:        anthropicMaxTokens,
+		Temperature:      cfg.Temperature,
+		TopP:             cfg.TopP,
+		TopK:             cfg.TopK,
+		StopSequences:    cfg.StopSequences,
+	}
+	if cfg.MaxOutputTokens != 0 {
+		req.MaxTokens = cfg.MaxOutputTokens
+	}
+	var system []string
+	calls := toolcalls.Pending{Prefix: "toolu_"}
+	for i, m := range input.Messages {
+		if m.Role == ai.RoleSystem {
+			system = append(system, m.Text())
+			continue
+		}
+		am := &anthropicMessage{Role: "user"}
+		if m.Role == ai.RoleModel {
+			am.Role = "assistant"
+		}
+		for _, p := range m.Content {
+			switch {
+			case p.IsText() || p.IsData():
+				am.Content = append(am.Content, &anthropicBlock{Type: "text", Text: p.Text})
+			case p.IsMedia():
+				mediaType, data, ok := strings.Cut(strings.TrimPrefix(p.Text, "data:"), ";base64,")
+				if !ok || !strings.HasPrefix(p.Text, "data:") {
+					return nil, fmt.Errorf("message %d: media must be a base64 data URL", i)
+				}
+				if p.ContentType != "" {
+					mediaType = p.ContentType
+				}
+				am.Content = append(am.Content, &anthropicBlock{
+					Type:   "image",
+					Source: &anthropicSource{Type: "base64", MediaType: mediaType, Data: data},
+				})
+			case p.IsToolRequest():
+				b := &anthropicBlock{
+					Type:  "tool_use",
+					ID:    calls.Add("", p.ToolRequest.Name),
+					Name:  p.ToolRequest.Name,
+					Input: map[string]any{},
+				}
+				if p.ToolRequest.Input != nil {
+					b.Input = p.ToolRequest.Input
+				}
+				am.Content = append(am.Content, b)
+			case p.IsToolResponse():
+				id, ok := calls.Answer(p.ToolResponse.Name)
+				if !ok {
+					return nil, fmt.Errorf("message %d: response from tool %q, which was not called", i, p.ToolResponse.Name)
+				}
+				output, err := json.Marshal(p.ToolResponse.Output)
+				if err != nil {
+					return nil, fmt.Errorf("message %d: %w", i, err)
+				}
+				am.Content = append(am.Content, &anthropicBlock{
+					Type:      "tool_result",
+					ToolUseID: id,
+					Content:   string(output),
+				})
+			}
+		}
+		// The API requires roles to alternate, so merge consecutive
+		// messages with the same role, like tool responses and a
+		// following user message.
+		if n := len(req.Messages); n > 0 && req.Messages[n-1].Role == am.Role {
+			req.Messages[n-1].Content = append(req.Messages[n-1].Content, am.Content...)
+		} else {
+			req.Messages = append(req.Messages, am)
+		}
+	}
+	req.System = strings.Join(system, "\n")
+	for _, t := range input.Tools {
+		schema := t.InputSchema
+		if schema == nil {
+			schema = map[string]any{"type": "object"}
+		}
+		req.Tools = append(req.Tools, &anthropicTool{
+			Name:        t.Name,
+			Description: t.Description,
+			InputSchema: schema,
+		})
+	}
+	return req, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vertexai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/vertexai/genai"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/plugins/internal/clientcache"
+	"google.golang.org/api/option"
+)
+
+func TestClientFor(t *testing.T) {
+	var gotAuth, gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":"hi"}]},"finishReason":"STOP"}]}`))
+	}))
+	defer srv.Close()
+
+	state.projectID = "default-project"
+	state.location = "us-central1"
+	state.clients = clientcache.New(0, closeClient)
+	state.clientOpts = []option.ClientOption{genai.WithREST()}
+	defer func() {
+		state.projectID, state.location = "", ""
+		state.clients, state.clientOpts = nil, nil
+	}()
+
+	ctx := ai.WithCredentialProvider(context.Background(), func(context.Context, string) (*ai.Credentials, error) {
+		return &ai.Credentials{AccessToken: "tenant-token", Endpoint: srv.URL, ProjectID: "tenant-project"}, nil
+	})
+	client, release, err := clientFor(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	resp, err := client.GenerativeModel("gemini-1.5-flash").GenerateContent(ctx, genai.Text("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Candidates[0].Content.Parts[0]; got != genai.Text("hi") {
+		t.Errorf("got response %v, want hi", got)
+	}
+	if want := "Bearer tenant-token"; gotAuth != want {
+		t.Errorf("got Authorization %q, want %q", gotAuth, want)
+	}
+	if want := "/projects/tenant-project/locations/us-central1/"; !strings.Contains(gotPath, want) {
+		t.Errorf("got path %q, want it to contain %q", gotPath, want)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vertexai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	aiplatform "cloud.google.com/go/aiplatform/apiv1"
+	"cloud.google.com/go/aiplatform/apiv1/aiplatformpb"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/firebase/genkit/go/plugins/ratelimit"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var knownImagenModels = []string{
+	"imagen-3.0-generate-001",
+	"imagen-3.0-fast-generate-001",
+	"imagegeneration@006",
+}
+
+// ImagenConfig is the configuration for an Imagen model.
+// Set [ai.ModelRequest.Config] to a value of type *[ImagenConfig].
+type ImagenConfig struct {
+	// The aspect ratio of the images: "1:1", "9:16", "16:9", "3:4" or "4:3".
+	// The default is "1:1".
+	AspectRatio string `json:"aspectRatio,omitempty"`
+	// The number of images to generate, from 1 to 4. The default is 1.
+	NumberOfImages int `json:"numberOfImages,omitempty"`
+	// A description of what to discourage in the images.
+	NegativePrompt string `json:"negativePrompt,omitempty"`
+	// How aggressively to filter unsafe images: "block_most",
+	// "block_some", "block_few" or "block_fewest".
+	SafetyFilterLevel string `json:"safetyFilterLevel,omitempty"`
+}
+
+// DefineImagenModel defines an Imagen model with the given name, such as
+// "imagen-3.0-generate-001". The model generates images from the text of
+// the request and returns them as media parts with base64 data URLs.
+// Use [IsDefinedModel] to determine if a model is already defined.
+// After [Init] is called, the known Imagen models are defined.
+func DefineImagenModel(g *genkit.Genkit, name string) ai.Model {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if !state.initted {
+		panic(provider + ".Init not called")
+	}
+	return defineImagenModel(g, name)
+}
+
+// requires state.mu
+func defineImagenModel(g *genkit.Genkit, name string) ai.Model {
+	meta := &ai.ModelMetadata{
+		Label: labelPrefix + " - " + name,
+	}
+	gen := func(ctx context.Context, input *ai.ModelRequest, _ ai.ModelStreamingCallback) (*ai.ModelResponse, error) {
+		client, projectID, location, release, err := predictionClientFor(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		endpoint := fmt.Sprintf("projects/%s/locations/%s/publishers/google/models/%s", projectID, location, name)
+		return generateImages(ctx, client, endpoint, input)
+	}
+	return genkit.DefineModel(g, provider, name, meta, ratelimit.ForModel(state.limits, name, gen))
+}
+
+func generateImages(ctx context.Context, client *aiplatform.PredictionClient, endpoint string, input *ai.ModelRequest) (*ai.ModelResponse, error) {
+	preq, err := newImagenRequest(endpoint, input)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Predict(ctx, preq)
+	if err != nil {
+		return nil, err
+	}
+	msg := &ai.Message{Role: ai.RoleModel}
+	var filtered []string
+	for _, pred := range resp.Predictions {
+		fields := pred.GetStructValue().GetFields()
+		data := fields["bytesBase64Encoded"].GetStringValue()
+		if data == "" {
+			if reason := fields["raiFilteredReason"].GetStringValue(); reason != "" {
+				filtered = append(filtered, reason)
+			}
+			continue
+		}
+		mimeType := fields["mimeType"].GetStringValue()
+		if mimeType == "" {
+			mimeType = "image/png"
+		}
+		msg.Content = append(msg.Content, ai.NewMediaPart(mimeType, "data:"+mimeType+";base64,"+data))
+	}
+	r := &ai.ModelResponse{
+		Request:      input,
+		Message:      msg,
+		FinishReason: ai.FinishReasonStop,
+	}
+	if len(msg.Content) == 0 {
+		r.FinishReason = ai.FinishReasonBlocked
+		r.FinishMessage = strings.Join(filtered, "; ")
+	}
+	return r, nil
+}
+
+// newImagenRequest creates a PredictRequest from a ModelRequest.
+// The prompt is the text of the request's messages.
+func newImagenRequest(endpoint string, input *ai.ModelRequest) (*aiplatformpb.PredictRequest, error) {
+	cfg := &ImagenConfig{}
+	if c, ok := input.Config.(*ImagenConfig); ok && c != nil {
+		cfg = c
+	} else if !ok && input.Config != nil {
+		return nil, fmt.Errorf("vertexai: Imagen config has type %T, want %T", input.Config, &ImagenConfig{})
+	}
+	var prompt strings.Builder
+	for _, m := range input.Messages {
+		prompt.WriteString(m.Text())
+	}
+	if prompt.Len() == 0 {
+		return nil, fmt.Errorf("vertexai: Imagen request has no prompt")
+	}
+	instance, err := structpb.NewValue(map[string]any{"prompt": prompt.String()})
+	if err != nil {
+		return nil, err
+	}
+	params := map[string]any{"sampleCount": max(cfg.NumberOfImages, 1)}
+	if cfg.AspectRatio != "" {
+		params["aspectRatio"] = cfg.AspectRatio
+	}
+	if cfg.NegativePrompt != "" {
+		params["negativePrompt"] = cfg.NegativePrompt
+	}
+	if cfg.SafetyFilterLevel != "" {
+		params["safetySetting"] = cfg.SafetyFilterLevel
+	}
+	parameters, err := structpb.NewValue(params)
+	if err != nil {
+		return nil, err
+	}
+	return &aiplatformpb.PredictRequest{
+		Endpoint:   endpoint,
+		Instances:  []*structpb.Value{instance},
+		Parameters: parameters,
+	}, nil
+}
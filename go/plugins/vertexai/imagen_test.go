@@ -0,0 +1,170 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vertexai
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	aiplatform "cloud.google.com/go/aiplatform/apiv1"
+	"cloud.google.com/go/aiplatform/apiv1/aiplatformpb"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/firebase/genkit/go/plugins/internal/clientcache"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// fakePredictionServer returns one image per sample, or filters them all
+// if the prompt is "unsafe".
+type fakePredictionServer struct {
+	aiplatformpb.UnimplementedPredictionServiceServer
+	got *aiplatformpb.PredictRequest
+}
+
+func (s *fakePredictionServer) Predict(ctx context.Context, req *aiplatformpb.PredictRequest) (*aiplatformpb.PredictResponse, error) {
+	s.got = req
+	prompt := req.Instances[0].GetStructValue().Fields["prompt"].GetStringValue()
+	n := int(req.Parameters.GetStructValue().Fields["sampleCount"].GetNumberValue())
+	resp := &aiplatformpb.PredictResponse{}
+	for range n {
+		pred := map[string]any{"bytesBase64Encoded": "aW1n", "mimeType": "image/jpeg"}
+		if prompt == "unsafe" {
+			pred = map[string]any{"raiFilteredReason": "unsafe content"}
+		}
+		v, err := structpb.NewValue(pred)
+		if err != nil {
+			return nil, err
+		}
+		resp.Predictions = append(resp.Predictions, v)
+	}
+	return resp, nil
+}
+
+func TestImagen(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakePredictionServer{}
+	srv := grpc.NewServer()
+	aiplatformpb.RegisterPredictionServiceServer(srv, fake)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	ctx := context.Background()
+	pclient, err := aiplatform.NewPredictionClient(ctx,
+		option.WithEndpoint(lis.Addr().String()),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pclient.Close()
+	state.mu.Lock()
+	state.initted = true
+	state.projectID = "project"
+	state.location = "us-central1"
+	state.pclient = pclient
+	state.mu.Unlock()
+	defer func() {
+		state.initted = false
+		state.projectID, state.location = "", ""
+		state.pclient = nil
+	}()
+
+	g, err := genkit.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := DefineImagenModel(g, "imagen-3.0-generate-001")
+
+	resp, err := genkit.Generate(ctx, g,
+		ai.WithModel(m),
+		ai.WithTextPrompt("a cat"),
+		ai.WithConfig(&ImagenConfig{
+			AspectRatio:       "16:9",
+			NumberOfImages:    2,
+			NegativePrompt:    "dogs",
+			SafetyFilterLevel: "block_most",
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "projects/project/locations/us-central1/publishers/google/models/imagen-3.0-generate-001"; fake.got.Endpoint != want {
+		t.Errorf("got endpoint %q, want %q", fake.got.Endpoint, want)
+	}
+	wantParams := map[string]any{
+		"sampleCount":    2.0,
+		"aspectRatio":    "16:9",
+		"negativePrompt": "dogs",
+		"safetySetting":  "block_most",
+	}
+	if diff := cmp.Diff(wantParams, fake.got.Parameters.GetStructValue().AsMap()); diff != "" {
+		t.Errorf("parameters mismatch (-want, +got):\n%s", diff)
+	}
+	image := ai.NewMediaPart("image/jpeg", "data:image/jpeg;base64,aW1n")
+	if diff := cmp.Diff([]*ai.Part{image, image}, resp.Message.Content); diff != "" {
+		t.Errorf("content mismatch (-want, +got):\n%s", diff)
+	}
+
+	// When every image is filtered, the response is blocked.
+	resp, err = genkit.Generate(ctx, g, ai.WithModel(m), ai.WithTextPrompt("unsafe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.FinishReason != ai.FinishReasonBlocked || resp.FinishMessage != "unsafe content" {
+		t.Errorf("got finish reason %q and message %q, want blocked", resp.FinishReason, resp.FinishMessage)
+	}
+
+	// Per-request credentials select another client, project and location.
+	state.clientOpts = []option.ClientOption{
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	}
+	state.pclients = clientcache.New(0, closePredictionClient)
+	defer func() {
+		state.pclients.Purge()
+		state.clientOpts, state.pclients = nil, nil
+	}()
+	cctx := ai.WithCredentialProvider(ctx, func(context.Context, string) (*ai.Credentials, error) {
+		return &ai.Credentials{ProjectID: "tenant-project", Location: "europe-west1", Endpoint: lis.Addr().String()}, nil
+	})
+	if _, err := genkit.Generate(cctx, g, ai.WithModel(m), ai.WithTextPrompt("a cat")); err != nil {
+		t.Fatal(err)
+	}
+	if want := "projects/tenant-project/locations/europe-west1/publishers/google/models/imagen-3.0-generate-001"; fake.got.Endpoint != want {
+		t.Errorf("got endpoint %q, want %q", fake.got.Endpoint, want)
+	}
+}
+
+func TestNewImagenRequestConfig(t *testing.T) {
+	msgs := []*ai.Message{ai.NewUserTextMessage("a cat")}
+	// A nil *ImagenConfig is the same as no config.
+	req, err := newImagenRequest("endpoint", &ai.ModelRequest{Messages: msgs, Config: (*ImagenConfig)(nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Parameters.GetStructValue().Fields["sampleCount"].GetNumberValue(); got != 1 {
+		t.Errorf("got sampleCount %v, want 1", got)
+	}
+	if _, err := newImagenRequest("endpoint", &ai.ModelRequest{Messages: msgs, Config: &ai.GenerationCommonConfig{}}); err == nil {
+		t.Error("got nil error for a config of the wrong type")
+	}
+}
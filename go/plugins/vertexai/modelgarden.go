@@ -0,0 +1,336 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vertexai
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/firebase/genkit/go/plugins/chatformat"
+	"github.com/firebase/genkit/go/plugins/internal/gemini"
+	"github.com/firebase/genkit/go/plugins/ratelimit"
+	"google.golang.org/api/googleapi"
+)
+
+// ModelGardenConfig configures a model from the Vertex AI Model Garden.
+type ModelGardenConfig struct {
+	// The location the model is served from. The default is the
+	// location of the plugin. Partner models are only served from
+	// some locations; Claude models, for example, from "us-east5"
+	// and "europe-west1".
+	Location string
+	// The Vertex AI API endpoint. The default is
+	// "https://LOCATION-aiplatform.googleapis.com".
+	APIEndpoint string
+}
+
+// A gardenAPI is the API that serves a Model Garden model.
+type gardenAPI int
+
+const (
+	// OpenAI-compatible chat completions, served by the openapi endpoint.
+	gardenChatCompletions gardenAPI = iota
+	// OpenAI-compatible chat completions, served by rawPredict.
+	gardenRawChatCompletions
+	// The Anthropic messages API, served by rawPredict.
+	gardenAnthropicMessages
+)
+
+// A gardenFamily is a family of Model Garden models, recognized by
+// the prefix of their names.
+type gardenFamily struct {
+	prefix    string
+	publisher string
+	api       gardenAPI
+	caps      ai.ModelCapabilities
+}
+
+var gardenFamilies = []gardenFamily{
+	{"llama", "meta", gardenChatCompletions, gemini.BasicText},
+	{"mistral", "mistralai", gardenRawChatCompletions, gemini.BasicText},
+	{"codestral", "mistralai", gardenRawChatCompletions, gemini.BasicText},
+	{"claude", "anthropic", gardenAnthropicMessages, gemini.Multimodal},
+}
+
+// DefineModelGardenModel defines an open or partner model from the
+// Vertex AI Model Garden, selected by name: a Llama model such as
+// "llama3-405b-instruct-maas", a Mistral model such as "mistral-large@2407",
+// or a Claude model such as "claude-3-5-sonnet@20240620".
+// The model must be enabled in the project.
+// The model's config is an [ai.GenerationCommonConfig].
+func DefineModelGardenModel(ctx context.Context, g *genkit.Genkit, name string, cfg *ModelGardenConfig) (ai.Model, error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if !state.initted {
+		panic(provider + ".Init not called")
+	}
+	return defineModelGardenModel(ctx, g, name, cfg)
+}
+
+// requires state.mu
+func defineModelGardenModel(ctx context.Context, g *genkit.Genkit, name string, cfg *ModelGardenConfig) (ai.Model, error) {
+	if cfg == nil {
+		cfg = &ModelGardenConfig{}
+	}
+	i := -1
+	for j, f := range gardenFamilies {
+		if strings.HasPrefix(name, f.prefix) {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		return nil, fmt.Errorf("vertexai: unknown Model Garden model %q", name)
+	}
+	gm := &gardenModel{
+		name:     name,
+		family:   gardenFamilies[i],
+		location: cfg.Location,
+		endpoint: cfg.APIEndpoint,
+	}
+	client, err := newHTTPClient(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	gm.client = client
+	meta := &ai.ModelMetadata{
+		Label:    labelPrefix + " - " + name,
+		Supports: gm.family.caps,
+	}
+	return genkit.DefineModel(g, provider, name, meta, ratelimit.ForModel(state.limits, name, gm.generate)), nil
+}
+
+// A gardenModel is a model from the Model Garden.
+type gardenModel struct {
+	name   string
+	family gardenFamily
+	// From the ModelGardenConfig; empty for the plugin's defaults.
+	location string
+	endpoint string
+	client   *http.Client // for calls without per-request credentials
+}
+
+// clientFor returns the HTTP client and URL for a call in ctx, using
+// the per-request credentials in ctx, if there are any. Credentials may
+// set the project, location, endpoint, access token or API key.
+// The caller must call release when done with the client.
+func (gm *gardenModel) clientFor(ctx context.Context) (client *http.Client, url string, release func(), err error) {
+	creds, err := ai.CredentialsFor(ctx, provider)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	projectID := state.projectID
+	location := cmp.Or(gm.location, state.location)
+	endpoint := gm.endpoint
+	if creds == nil {
+		client, release = gm.client, func() {}
+	} else {
+		projectID = cmp.Or(creds.ProjectID, projectID)
+		location = cmp.Or(creds.Location, location)
+		endpoint = cmp.Or(creds.Endpoint, endpoint)
+		client, release, err = state.hclients.Get(ctx, creds.Key(), func(ctx context.Context) (*http.Client, error) {
+			return newHTTPClient(ctx, creds)
+		})
+		if err != nil {
+			return nil, "", nil, err
+		}
+	}
+	endpoint = cmp.Or(endpoint, fmt.Sprintf("https://%s-aiplatform.googleapis.com", location))
+	switch gm.family.api {
+	case gardenChatCompletions:
+		url = fmt.Sprintf("%s/v1beta1/projects/%s/locations/%s/endpoints/openapi/chat/completions",
+			endpoint, projectID, location)
+	default:
+		url = fmt.Sprintf("%s/v1/projects/%s/locations/%s/publishers/%s/models/%s:rawPredict",
+			endpoint, projectID, location, gm.family.publisher, gm.name)
+	}
+	return client, url, release, nil
+}
+
+func (gm *gardenModel) generate(ctx context.Context, input *ai.ModelRequest, cb ai.ModelStreamingCallback) (*ai.ModelResponse, error) {
+	cfg := &ai.GenerationCommonConfig{}
+	if c, ok := input.Config.(*ai.GenerationCommonConfig); ok && c != nil {
+		cfg = c
+	} else if !ok && input.Config != nil {
+		return nil, fmt.Errorf("vertexai: %s config has type %T, want %T", gm.name, input.Config, &ai.GenerationCommonConfig{})
+	}
+	var (
+		r   *ai.ModelResponse
+		err error
+	)
+	if gm.family.api == gardenAnthropicMessages {
+		r, err = gm.generateAnthropic(ctx, input, cfg)
+	} else {
+		r, err = gm.generateChatCompletion(ctx, input, cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.Request = input
+	// These APIs are not streamed, so the whole response is a single chunk.
+	if cb != nil {
+		if err := cb(ctx, &ai.ModelResponseChunk{Content: r.Message.Content}); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// A chatCompletionRequest is a request to an OpenAI-compatible
+// chat completions API.
+type chatCompletionRequest struct {
+	Model       string                      `json:"model"`
+	Messages    []*chatformat.OpenAIMessage `json:"messages"`
+	Tools       []*chatCompletionTool       `json:"tools,omitempty"`
+	MaxTokens   int                         `json:"max_tokens,omitempty"`
+	Temperature float64                     `json:"temperature,omitempty"`
+	TopP        float64                     `json:"top_p,omitempty"`
+	Stop        []string                    `json:"stop,omitempty"`
+	Seed        int                         `json:"seed,omitempty"`
+	Stream      bool                        `json:"stream"`
+}
+
+type chatCompletionTool struct {
+	Type     string                 `json:"type"`
+	Function chatCompletionFunction `json:"function"`
+}
+
+type chatCompletionFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message      *chatformat.OpenAIMessage `json:"message"`
+		FinishReason string                    `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+func (gm *gardenModel) generateChatCompletion(ctx context.Context, input *ai.ModelRequest, cfg *ai.GenerationCommonConfig) (*ai.ModelResponse, error) {
+	msgs, err := chatformat.ToOpenAI(input.Messages)
+	if err != nil {
+		return nil, fmt.Errorf("vertexai: %s: %w", gm.name, err)
+	}
+	req := &chatCompletionRequest{
+		Model:       gm.name,
+		Messages:    msgs,
+		MaxTokens:   cfg.MaxOutputTokens,
+		Temperature: cfg.Temperature,
+		TopP:        cfg.TopP,
+		Stop:        cfg.StopSequences,
+		Seed:        cfg.Seed,
+	}
+	switch gm.family.api {
+	case gardenChatCompletions:
+		// The openapi endpoint serves models of all publishers.
+		req.Model = gm.family.publisher + "/" + gm.name
+	case gardenRawChatCompletions:
+		// rawPredict wants the model name without its version.
+		req.Model, _, _ = strings.Cut(gm.name, "@")
+	}
+	for _, t := range input.Tools {
+		req.Tools = append(req.Tools, &chatCompletionTool{
+			Type: "function",
+			Function: chatCompletionFunction{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.InputSchema,
+			},
+		})
+	}
+	var resp chatCompletionResponse
+	if err := gm.post(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 || resp.Choices[0].Message == nil {
+		return nil, fmt.Errorf("vertexai: %s returned no choices", gm.name)
+	}
+	choice := resp.Choices[0]
+	out, err := chatformat.FromOpenAI([]*chatformat.OpenAIMessage{choice.Message})
+	if err != nil {
+		return nil, fmt.Errorf("vertexai: %s: %w", gm.name, err)
+	}
+	msg := out[0]
+	r := &ai.ModelResponse{
+		Message: msg,
+		Usage: &ai.GenerationUsage{
+			InputTokens:  resp.Usage.PromptTokens,
+			OutputTokens: resp.Usage.CompletionTokens,
+			TotalTokens:  resp.Usage.TotalTokens,
+		},
+	}
+	switch choice.FinishReason {
+	case "stop", "tool_calls":
+		r.FinishReason = ai.FinishReasonStop
+	case "length", "model_length":
+		r.FinishReason = ai.FinishReasonLength
+	case "content_filter":
+		r.FinishReason = ai.FinishReasonBlocked
+	case "":
+		r.FinishReason = ai.FinishReasonUnknown
+	default:
+		r.FinishReason = ai.FinishReasonOther
+	}
+	return r, nil
+}
+
+// post sends body as JSON to the model's URL and decodes the response into result.
+func (gm *gardenModel) post(ctx context.Context, body, result any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	client, url, release, err := gm.clientFor(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+		// A googleapi.Error lets the rate limiter recognize quota errors.
+		return fmt.Errorf("vertexai: %s request failed: %w", gm.name, &googleapi.Error{
+			Code:    resp.StatusCode,
+			Message: string(bytes.TrimSpace(msg)),
+			Body:    string(msg),
+			Header:  resp.Header,
+		})
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
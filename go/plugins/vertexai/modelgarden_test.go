@@ -0,0 +1,289 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vertexai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/firebase/genkit/go/plugins/internal/clientcache"
+	"github.com/firebase/genkit/go/plugins/ratelimit"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/option"
+)
+
+func TestModelGarden(t *testing.T) {
+	// Stand-ins for the openapi chat completions endpoint and the
+	// rawPredict endpoints of Mistral and Claude.
+	const (
+		llamaPath   = "/v1beta1/projects/project/locations/us-central1/endpoints/openapi/chat/completions"
+		mistralPath = "/v1/projects/project/locations/us-central1/publishers/mistralai/models/mistral-large@2407:rawPredict"
+		claudePath  = "/v1/projects/project/locations/us-east5/publishers/anthropic/models/claude-3-5-sonnet@20240620:rawPredict"
+	)
+	bodies := map[string]map[string]any{}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		bodies[r.URL.Path] = body
+		switch r.URL.Path {
+		case llamaPath:
+			w.Write([]byte(`{
+				"choices": [{"message": {"role": "assistant", "content": null, "tool_calls": [
+					{"id": "call_x", "type": "function", "function": {"name": "weather", "arguments": "{\"city\":\"Paris\"}"}}
+				]}, "finish_reason": "tool_calls"}],
+				"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+			}`))
+		case mistralPath:
+			w.Write([]byte(`{
+				"choices": [{"message": {"role": "assistant", "content": "Bonjour"}, "finish_reason": "length"}],
+				"usage": {"prompt_tokens": 3, "completion_tokens": 1, "total_tokens": 4}
+			}`))
+		case claudePath:
+			w.Write([]byte(`{
+				"content": [{"type": "text", "text": "It is sunny."}],
+				"stop_reason": "end_turn",
+				"usage": {"input_tokens": 20, "output_tokens": 4}
+			}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	state.mu.Lock()
+	state.initted = true
+	state.projectID = "project"
+	state.location = "us-central1"
+	state.clientOpts = []option.ClientOption{option.WithHTTPClient(srv.Client())}
+	state.mu.Unlock()
+	defer func() {
+		state.initted = false
+		state.projectID, state.location = "", ""
+		state.clientOpts = nil
+	}()
+
+	g, err := genkit.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	define := func(name, location string) ai.Model {
+		t.Helper()
+		m, err := DefineModelGardenModel(ctx, g, name, &ModelGardenConfig{Location: location, APIEndpoint: srv.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	weather := &ai.ToolDefinition{
+		Name:        "weather",
+		Description: "the weather in a city",
+		InputSchema: map[string]any{"type": "object"},
+	}
+
+	t.Run("llama", func(t *testing.T) {
+		m := define("llama3-405b-instruct-maas", "")
+		res, err := genkit.Generate(ctx, g,
+			ai.WithModel(m),
+			ai.WithTextPrompt("What is the weather in Paris?"),
+			ai.WithToolDefinitions(weather),
+			ai.WithReturnToolRequests(true),
+			ai.WithConfig(&ai.GenerationCommonConfig{Temperature: 0.5}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []*ai.Part{ai.NewToolRequestPart(&ai.ToolRequest{Name: "weather", Input: map[string]any{"city": "Paris"}})}
+		if diff := cmp.Diff(want, res.Message.Content); diff != "" {
+			t.Errorf("content mismatch (-want, +got):\n%s", diff)
+		}
+		if diff := cmp.Diff(&ai.GenerationUsage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15}, res.Usage); diff != "" {
+			t.Errorf("usage mismatch (-want, +got):\n%s", diff)
+		}
+		body := bodies[llamaPath]
+		if got, want := body["model"], "meta/llama3-405b-instruct-maas"; got != want {
+			t.Errorf("got model %v, want %q", got, want)
+		}
+		if got := body["tools"].([]any)[0].(map[string]any)["function"].(map[string]any)["name"]; got != "weather" {
+			t.Errorf("got tool %v, want weather", got)
+		}
+		if got := body["temperature"]; got != 0.5 {
+			t.Errorf("got temperature %v, want 0.5", got)
+		}
+	})
+
+	t.Run("mistral", func(t *testing.T) {
+		m := define("mistral-large@2407", "")
+		res, err := genkit.Generate(ctx, g,
+			ai.WithModel(m),
+			ai.WithTextPrompt("Say hello in French."),
+			ai.WithConfig(&ai.GenerationCommonConfig{MaxOutputTokens: 1}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := res.Text(), "Bonjour"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if res.FinishReason != ai.FinishReasonLength {
+			t.Errorf("got finish reason %q, want %q", res.FinishReason, ai.FinishReasonLength)
+		}
+		body := bodies[mistralPath]
+		if got, want := body["model"], "mistral-large"; got != want {
+			t.Errorf("got model %v, want %q", got, want)
+		}
+		if got := body["max_tokens"]; got != 1.0 {
+			t.Errorf("got max_tokens %v, want 1", got)
+		}
+
+		// A nil config is the same as no config.
+		if _, err := genkit.Generate(ctx, g,
+			ai.WithModel(m),
+			ai.WithTextPrompt("Say hello in French."),
+			ai.WithConfig((*ai.GenerationCommonConfig)(nil))); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("claude", func(t *testing.T) {
+		m := define("claude-3-5-sonnet@20240620", "us-east5")
+		res, err := genkit.Generate(ctx, g,
+			ai.WithModel(m),
+			ai.WithMessages(
+				ai.NewSystemTextMessage("Be brief."),
+				ai.NewUserTextMessage("What is the weather in Paris?"),
+				&ai.Message{Role: ai.RoleModel, Content: []*ai.Part{ai.NewToolRequestPart(&ai.ToolRequest{Name: "weather", Input: map[string]any{"city": "Paris"}})}},
+				&ai.Message{Role: ai.RoleTool, Content: []*ai.Part{ai.NewToolResponsePart(&ai.ToolResponse{Name: "weather", Output: map[string]any{"sky": "sunny"}})}},
+			),
+			ai.WithToolDefinitions(weather))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := res.Text(), "It is sunny."; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if diff := cmp.Diff(&ai.GenerationUsage{InputTokens: 20, OutputTokens: 4, TotalTokens: 24}, res.Usage); diff != "" {
+			t.Errorf("usage mismatch (-want, +got):\n%s", diff)
+		}
+		wantBody := map[string]any{
+			"anthropic_version": "vertex-2023-10-16",
+			"system":            "Be brief.",
+			"max_tokens":        4096.0,
+			"messages": []any{
+				map[string]any{"role": "user", "content": []any{
+					map[string]any{"type": "text", "text": "What is the weather in Paris?"},
+				}},
+				map[string]any{"role": "assistant", "content": []any{
+					map[string]any{"type": "tool_use", "id": "toolu_1", "name": "weather", "input": map[string]any{"city": "Paris"}},
+				}},
+				map[string]any{"role": "user", "content": []any{
+					map[string]any{"type": "tool_result", "tool_use_id": "toolu_1", "content": `{"sky":"sunny"}`},
+				}},
+			},
+			"tools": []any{
+				map[string]any{"name": "weather", "description": "the weather in a city", "input_schema": map[string]any{"type": "object"}},
+			},
+		}
+		if diff := cmp.Diff(wantBody, bodies[claudePath]); diff != "" {
+			t.Errorf("request mismatch (-want, +got):\n%s", diff)
+		}
+
+		_, err = genkit.Generate(ctx, g,
+			ai.WithModel(m),
+			ai.WithTextPrompt("Hello"),
+			ai.WithConfig(&ai.GenerationCommonConfig{Seed: 1}))
+		var uerr *ai.UnsupportedConfigError
+		if !errors.As(err, &uerr) || uerr.Option != "seed" {
+			t.Errorf("with a seed, got error %v, want an UnsupportedConfigError", err)
+		}
+	})
+
+	if _, err := DefineModelGardenModel(ctx, g, "unknown-model", nil); err == nil {
+		t.Error("got nil, want error for unknown model")
+	}
+}
+
+func TestModelGardenCredentialsAndLimits(t *testing.T) {
+	var (
+		auths, paths []string
+		fail         = true
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auths = append(auths, r.Header.Get("Authorization"))
+		paths = append(paths, r.URL.Path)
+		if fail {
+			fail = false
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "hi"}, "finish_reason": "stop"}]}`))
+	}))
+	defer srv.Close()
+
+	const name = "llama3-70b-instruct-maas"
+	state.mu.Lock()
+	state.initted = true
+	state.projectID = "project"
+	state.location = "us-central1"
+	state.clientOpts = []option.ClientOption{option.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "default-token"}))}
+	state.hclients = clientcache.New(0, (*http.Client).CloseIdleConnections)
+	state.limits = map[string]ratelimit.Limits{name: {MaxRetries: 1}}
+	state.mu.Unlock()
+	defer func() {
+		state.initted = false
+		state.projectID, state.location = "", ""
+		state.clientOpts, state.hclients, state.limits = nil, nil, nil
+	}()
+
+	g, err := genkit.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := DefineModelGardenModel(context.Background(), g, name, &ModelGardenConfig{APIEndpoint: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := ai.WithCredentialProvider(context.Background(), func(context.Context, string) (*ai.Credentials, error) {
+		return &ai.Credentials{AccessToken: "tenant-token", ProjectID: "tenant-project", Location: "europe-west1"}, nil
+	})
+	// The first attempt fails for exceeding quota and is retried.
+	res, err := genkit.Generate(ctx, g, ai.WithModel(m), ai.WithTextPrompt("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Text(); got != "hi" {
+		t.Errorf("got %q, want hi", got)
+	}
+	if _, err := genkit.Generate(context.Background(), g, ai.WithModel(m), ai.WithTextPrompt("hello")); err != nil {
+		t.Fatal(err)
+	}
+	wantAuths := []string{"Bearer tenant-token", "Bearer tenant-token", "Bearer default-token"}
+	if diff := cmp.Diff(wantAuths, auths); diff != "" {
+		t.Errorf("Authorization mismatch (-want, +got):\n%s", diff)
+	}
+	tenantPath := "/v1beta1/projects/tenant-project/locations/europe-west1/endpoints/openapi/chat/completions"
+	defaultPath := "/v1beta1/projects/project/locations/us-central1/endpoints/openapi/chat/completions"
+	if diff := cmp.Diff([]string{tenantPath, tenantPath, defaultPath}, paths); diff != "" {
+		t.Errorf("path mismatch (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,361 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vertexai
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"sync"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// VectorSearchConfig configures an indexer and retriever for a
+// Vertex AI Vector Search index. See [DefineVectorSearchIndexer]
+// and [DefineVectorSearchRetriever].
+type VectorSearchConfig struct {
+	// The ID of the index. Required. It is also the name of the
+	// indexer and retriever.
+	IndexID string
+	// The ID of the index endpoint that the index is deployed to.
+	// Required for the retriever.
+	IndexEndpointID string
+	// The ID of the deployed index. Required for the retriever.
+	DeployedIndexID string
+	// The public domain name of the index endpoint, as shown in the
+	// Cloud console, for example "123.us-central1-456.vdb.vertexai.goog".
+	// Required for the retriever.
+	PublicDomainName string
+	// The Vertex AI API endpoint, used for upserts. The default is
+	// "https://LOCATION-aiplatform.googleapis.com".
+	APIEndpoint string
+	// Embedder to use. Required.
+	Embedder        ai.Embedder
+	EmbedderOptions any
+	// Documents holds the content of the indexed documents, which
+	// Vector Search does not store. Required.
+	Documents DocumentStore
+}
+
+// A DocumentStore stores documents by the ID of their datapoint
+// in a Vector Search index.
+type DocumentStore interface {
+	// Put stores docs under ids, which have the same length.
+	Put(ctx context.Context, ids []string, docs []*ai.Document) error
+	// Get returns the documents with the given ids, in the same order.
+	// The document of an unknown ID is nil.
+	Get(ctx context.Context, ids []string) ([]*ai.Document, error)
+}
+
+// A Restrict limits a query to datapoints with, or without, the given
+// tokens in a namespace. Restricts of a datapoint are put in the
+// "restricts" metadata of its document.
+type Restrict struct {
+	Namespace string   `json:"namespace"`
+	AllowList []string `json:"allowList,omitempty"`
+	DenyList  []string `json:"denyList,omitempty"`
+}
+
+// A NumericRestrict limits a query to datapoints whose value in a
+// namespace compares to the given value by Op. Exactly one value is set.
+// Op is one of "LESS", "LESS_EQUAL", "EQUAL", "GREATER_EQUAL",
+// "GREATER" or "NOT_EQUAL"; it is ignored for datapoints.
+// Numeric restricts of a datapoint are put in the "numericRestricts"
+// metadata of its document.
+type NumericRestrict struct {
+	Namespace   string   `json:"namespace"`
+	ValueInt    *int64   `json:"valueInt,omitempty"`
+	ValueFloat  *float32 `json:"valueFloat,omitempty"`
+	ValueDouble *float64 `json:"valueDouble,omitempty"`
+	Op          string   `json:"op,omitempty"`
+}
+
+// VectorSearchRetrieverOptions may be passed in the Options field of
+// [ai.RetrieverRequest] to a retriever defined by [DefineVectorSearchRetriever].
+type VectorSearchRetrieverOptions struct {
+	// The number of neighbors to return. The default is 10.
+	NeighborCount    int               `json:"neighborCount,omitempty"`
+	Restricts        []Restrict        `json:"restricts,omitempty"`
+	NumericRestricts []NumericRestrict `json:"numericRestricts,omitempty"`
+}
+
+const defaultNeighborCount = 10
+
+// DefineVectorSearchIndexer defines an indexer that embeds documents,
+// stores them in cfg.Documents and upserts them into the index.
+// The index must support streaming updates.
+func DefineVectorSearchIndexer(ctx context.Context, g *genkit.Genkit, cfg VectorSearchConfig) (ai.Indexer, error) {
+	vs, err := newVectorSearch(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("vertexai.DefineVectorSearchIndexer: %w", err)
+	}
+	return genkit.DefineIndexer(g, provider, cfg.IndexID, vs.index), nil
+}
+
+// DefineVectorSearchRetriever defines a retriever that finds the nearest
+// neighbors of the embedding of the request document and returns their
+// documents from cfg.Documents. The distance of each neighbor is put
+// in the "distance" metadata of its document.
+func DefineVectorSearchRetriever(ctx context.Context, g *genkit.Genkit, cfg VectorSearchConfig) (ai.Retriever, error) {
+	vs, err := newVectorSearch(ctx, cfg)
+	if err == nil && (cfg.IndexEndpointID == "" || cfg.DeployedIndexID == "" || cfg.PublicDomainName == "") {
+		err = errors.New("IndexEndpointID, DeployedIndexID and PublicDomainName required")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("vertexai.DefineVectorSearchRetriever: %w", err)
+	}
+	return genkit.DefineRetriever(g, provider, cfg.IndexID, vs.retrieve), nil
+}
+
+// vectorSearch calls the Vector Search REST API.
+type vectorSearch struct {
+	cfg       VectorSearchConfig
+	projectID string
+	location  string
+	client    *http.Client
+}
+
+func newVectorSearch(ctx context.Context, cfg VectorSearchConfig) (*vectorSearch, error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if !state.initted {
+		panic("vertexai.Init not called")
+	}
+	if cfg.IndexID == "" {
+		return nil, errors.New("IndexID required")
+	}
+	if cfg.Embedder == nil {
+		return nil, errors.New("Embedder required")
+	}
+	if cfg.Documents == nil {
+		return nil, errors.New("Documents required")
+	}
+	client, err := newHTTPClient(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	cfg.APIEndpoint = cmp.Or(cfg.APIEndpoint, fmt.Sprintf("https://%s-aiplatform.googleapis.com", state.location))
+	return &vectorSearch{
+		cfg:       cfg,
+		projectID: state.projectID,
+		location:  state.location,
+		client:    client,
+	}, nil
+}
+
+// A datapoint is a vector in a Vector Search index.
+type datapoint struct {
+	ID               string            `json:"datapointId"`
+	FeatureVector    []float32         `json:"featureVector,omitempty"`
+	Restricts        []Restrict        `json:"restricts,omitempty"`
+	NumericRestricts []NumericRestrict `json:"numericRestricts,omitempty"`
+}
+
+func (vs *vectorSearch) index(ctx context.Context, req *ai.IndexerRequest) error {
+	if len(req.Documents) == 0 {
+		return nil
+	}
+	eres, err := vs.cfg.Embedder.Embed(ctx, &ai.EmbedRequest{
+		Documents: req.Documents,
+		Options:   vs.cfg.EmbedderOptions,
+	})
+	if err != nil {
+		return fmt.Errorf("vector search index embedding failed: %v", err)
+	}
+	if len(eres.Embeddings) != len(req.Documents) {
+		return fmt.Errorf("vector search index: embedder returned %d embeddings for %d documents", len(eres.Embeddings), len(req.Documents))
+	}
+	ids := make([]string, len(req.Documents))
+	points := make([]datapoint, len(req.Documents))
+	for i, doc := range req.Documents {
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("vertexai: error marshaling document: %v", err)
+		}
+		ids[i] = fmt.Sprintf("%02x", md5.Sum(b))
+		points[i] = datapoint{ID: ids[i], FeatureVector: eres.Embeddings[i].Embedding}
+		if err := metadataValue(doc, "restricts", &points[i].Restricts); err != nil {
+			return err
+		}
+		if err := metadataValue(doc, "numericRestricts", &points[i].NumericRestricts); err != nil {
+			return err
+		}
+	}
+	// Store the documents first, so they can be found as soon as
+	// their datapoints are.
+	if err := vs.cfg.Documents.Put(ctx, ids, req.Documents); err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/v1/projects/%s/locations/%s/indexes/%s:upsertDatapoints",
+		vs.cfg.APIEndpoint, vs.projectID, vs.location, vs.cfg.IndexID)
+	return vs.post(ctx, url, map[string]any{"datapoints": points}, nil)
+}
+
+// metadataValue sets v from the metadata of doc under key, which may
+// hold v's type or its JSON form.
+func metadataValue(doc *ai.Document, key string, v any) error {
+	m, ok := doc.Metadata[key]
+	if !ok {
+		return nil
+	}
+	b, err := json.Marshal(m)
+	if err == nil {
+		err = json.Unmarshal(b, v)
+	}
+	if err != nil {
+		return fmt.Errorf("vertexai: bad %q metadata: %v", key, err)
+	}
+	return nil
+}
+
+type findNeighborsResponse struct {
+	NearestNeighbors []struct {
+		Neighbors []struct {
+			Datapoint datapoint `json:"datapoint"`
+			Distance  float64   `json:"distance"`
+		} `json:"neighbors"`
+	} `json:"nearestNeighbors"`
+}
+
+func (vs *vectorSearch) retrieve(ctx context.Context, req *ai.RetrieverRequest) (*ai.RetrieverResponse, error) {
+	opts := &VectorSearchRetrieverOptions{}
+	if o, ok := req.Options.(*VectorSearchRetrieverOptions); ok && o != nil {
+		opts = o
+	} else if !ok && req.Options != nil {
+		return nil, fmt.Errorf("vertexai.Retrieve options have type %T, want %T", req.Options, &VectorSearchRetrieverOptions{})
+	}
+	eres, err := vs.cfg.Embedder.Embed(ctx, &ai.EmbedRequest{
+		Documents: []*ai.Document{req.Document},
+		Options:   vs.cfg.EmbedderOptions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("vector search retrieve embedding failed: %v", err)
+	}
+	if len(eres.Embeddings) != 1 {
+		return nil, fmt.Errorf("vector search retrieve: embedder returned %d embeddings for 1 document", len(eres.Embeddings))
+	}
+	query := map[string]any{
+		"datapoint": datapoint{
+			FeatureVector:    eres.Embeddings[0].Embedding,
+			Restricts:        opts.Restricts,
+			NumericRestricts: opts.NumericRestricts,
+		},
+		"neighborCount": cmp.Or(opts.NeighborCount, defaultNeighborCount),
+	}
+	url := fmt.Sprintf("https://%s/v1/projects/%s/locations/%s/indexEndpoints/%s:findNeighbors",
+		vs.cfg.PublicDomainName, vs.projectID, vs.location, vs.cfg.IndexEndpointID)
+	var resp findNeighborsResponse
+	err = vs.post(ctx, url, map[string]any{
+		"deployedIndexId": vs.cfg.DeployedIndexID,
+		"queries":         []any{query},
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	ret := &ai.RetrieverResponse{}
+	if len(resp.NearestNeighbors) == 0 {
+		return ret, nil
+	}
+	neighbors := resp.NearestNeighbors[0].Neighbors
+	ids := make([]string, len(neighbors))
+	for i, n := range neighbors {
+		ids[i] = n.Datapoint.ID
+	}
+	docs, err := vs.cfg.Documents.Get(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i, doc := range docs {
+		if doc == nil {
+			return nil, fmt.Errorf("vertexai: no document for datapoint %q", ids[i])
+		}
+		d := *doc
+		d.Metadata = maps.Clone(doc.Metadata)
+		if d.Metadata == nil {
+			d.Metadata = map[string]any{}
+		}
+		d.Metadata["distance"] = neighbors[i].Distance
+		ret.Documents = append(ret.Documents, &d)
+	}
+	return ret, nil
+}
+
+// post sends body as JSON to url and decodes the response into result,
+// if it is not nil.
+func (vs *vectorSearch) post(ctx context.Context, url string, body, result any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := vs.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+		return fmt.Errorf("vertexai: vector search request failed: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// MemoryDocumentStore is a [DocumentStore] that keeps documents in
+// memory. It is suitable for tests and prototypes.
+// The zero value is ready to use.
+type MemoryDocumentStore struct {
+	mu   sync.Mutex
+	docs map[string]*ai.Document
+}
+
+// Put implements [DocumentStore.Put].
+func (s *MemoryDocumentStore) Put(ctx context.Context, ids []string, docs []*ai.Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.docs == nil {
+		s.docs = map[string]*ai.Document{}
+	}
+	for i, id := range ids {
+		s.docs[id] = docs[i]
+	}
+	return nil
+}
+
+// Get implements [DocumentStore.Get].
+func (s *MemoryDocumentStore) Get(ctx context.Context, ids []string) ([]*ai.Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	docs := make([]*ai.Document, len(ids))
+	for i, id := range ids {
+		docs[i] = s.docs[id]
+	}
+	return docs, nil
+}
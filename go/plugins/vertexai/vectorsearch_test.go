@@ -0,0 +1,212 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vertexai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/option"
+)
+
+func TestVectorSearch(t *testing.T) {
+	// A stand-in for the Vector Search REST API that returns the
+	// upserted datapoints, nearest first, for any query.
+	var (
+		upserted []datapoint
+		query    map[string]any
+		paths    []string
+	)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch {
+		case strings.HasSuffix(r.URL.Path, ":upsertDatapoints"):
+			var body struct{ Datapoints []datapoint }
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Error(err)
+			}
+			upserted = append(upserted, body.Datapoints...)
+			w.Write([]byte(`{}`))
+		case strings.HasSuffix(r.URL.Path, ":findNeighbors"):
+			var body struct {
+				DeployedIndexID string           `json:"deployedIndexId"`
+				Queries         []map[string]any `json:"queries"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Error(err)
+			}
+			if body.DeployedIndexID != "deployed" {
+				t.Errorf("got deployed index %q", body.DeployedIndexID)
+			}
+			query = body.Queries[0]
+			var neighbors []map[string]any
+			for i, p := range upserted {
+				neighbors = append(neighbors, map[string]any{
+					"datapoint": map[string]any{"datapointId": p.ID},
+					"distance":  float64(i) / 2,
+				})
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"nearestNeighbors": []any{map[string]any{"id": "0", "neighbors": neighbors}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	state.mu.Lock()
+	state.initted = true
+	state.projectID = "project"
+	state.location = "us-central1"
+	state.clientOpts = []option.ClientOption{option.WithHTTPClient(srv.Client())}
+	state.mu.Unlock()
+	defer func() {
+		state.initted = false
+		state.projectID, state.location = "", ""
+		state.clientOpts = nil
+	}()
+
+	g, err := genkit.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedder := genkit.DefineEmbedder(g, "test", "len", func(ctx context.Context, req *ai.EmbedRequest) (*ai.EmbedResponse, error) {
+		res := &ai.EmbedResponse{}
+		for _, d := range req.Documents {
+			res.Embeddings = append(res.Embeddings, &ai.DocumentEmbedding{Embedding: []float32{float32(len(text(d)))}})
+		}
+		return res, nil
+	})
+	store := &MemoryDocumentStore{}
+	cfg := VectorSearchConfig{
+		IndexID:          "index",
+		IndexEndpointID:  "endpoint",
+		DeployedIndexID:  "deployed",
+		PublicDomainName: strings.TrimPrefix(srv.URL, "https://"),
+		APIEndpoint:      srv.URL,
+		Embedder:         embedder,
+		Documents:        store,
+	}
+	ctx := context.Background()
+	indexer, err := DefineVectorSearchIndexer(ctx, g, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retriever, err := DefineVectorSearchRetriever(ctx, g, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	two := int64(2)
+	docs := []*ai.Document{
+		ai.DocumentFromText("apple", map[string]any{
+			"restricts":        []Restrict{{Namespace: "color", AllowList: []string{"red"}}},
+			"numericRestricts": []NumericRestrict{{Namespace: "price", ValueInt: &two}},
+		}),
+		// Restricts may also be in their JSON form.
+		ai.DocumentFromText("banana", map[string]any{
+			"restricts": []any{map[string]any{"namespace": "color", "allowList": []any{"yellow"}}},
+		}),
+	}
+	if err := indexer.Index(ctx, &ai.IndexerRequest{Documents: docs}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "/v1/projects/project/locations/us-central1/indexes/index:upsertDatapoints"; paths[0] != want {
+		t.Errorf("got path %q, want %q", paths[0], want)
+	}
+	wantPoints := []datapoint{
+		{
+			FeatureVector:    []float32{5},
+			Restricts:        []Restrict{{Namespace: "color", AllowList: []string{"red"}}},
+			NumericRestricts: []NumericRestrict{{Namespace: "price", ValueInt: &two}},
+		},
+		{
+			FeatureVector: []float32{6},
+			Restricts:     []Restrict{{Namespace: "color", AllowList: []string{"yellow"}}},
+		},
+	}
+	if diff := cmp.Diff(wantPoints, upserted, cmp.FilterPath(func(p cmp.Path) bool {
+		return p.Last().String() == ".ID"
+	}, cmp.Ignore())); diff != "" {
+		t.Errorf("upserted datapoints mismatch (-want, +got):\n%s", diff)
+	}
+
+	resp, err := retriever.Retrieve(ctx, &ai.RetrieverRequest{
+		Document: ai.DocumentFromText("fruit", nil),
+		Options: &VectorSearchRetrieverOptions{
+			NeighborCount: 2,
+			Restricts:     []Restrict{{Namespace: "color", DenyList: []string{"green"}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/v1/projects/project/locations/us-central1/indexEndpoints/endpoint:findNeighbors"; paths[1] != want {
+		t.Errorf("got path %q, want %q", paths[1], want)
+	}
+	wantQuery := map[string]any{
+		"datapoint": map[string]any{
+			"datapointId":   "",
+			"featureVector": []any{5.0},
+			"restricts":     []any{map[string]any{"namespace": "color", "denyList": []any{"green"}}},
+		},
+		"neighborCount": 2.0,
+	}
+	if diff := cmp.Diff(wantQuery, query); diff != "" {
+		t.Errorf("query mismatch (-want, +got):\n%s", diff)
+	}
+	var got []string
+	for _, d := range resp.Documents {
+		got = append(got, text(d))
+	}
+	if want := []string{"apple", "banana"}; !cmp.Equal(got, want) {
+		t.Errorf("got documents %q, want %q", got, want)
+	}
+	if d := resp.Documents[1].Metadata["distance"]; d != 0.5 {
+		t.Errorf("got distance %v, want 0.5", d)
+	}
+	if _, ok := docs[0].Metadata["distance"]; ok {
+		t.Error("retrieve modified the stored document")
+	}
+
+	// A nil options pointer is the same as no options.
+	if _, err := retriever.Retrieve(ctx, &ai.RetrieverRequest{
+		Document: ai.DocumentFromText("fruit", nil),
+		Options:  (*VectorSearchRetrieverOptions)(nil),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// An embedder that returns too few embeddings is an error.
+	short := genkit.DefineEmbedder(g, "test", "short", func(ctx context.Context, req *ai.EmbedRequest) (*ai.EmbedResponse, error) {
+		return &ai.EmbedResponse{}, nil
+	})
+	vs := &vectorSearch{cfg: cfg}
+	vs.cfg.Embedder = short
+	if err := vs.index(ctx, &ai.IndexerRequest{Documents: docs}); err == nil {
+		t.Error("index with too few embeddings: got nil error")
+	}
+	if _, err := vs.retrieve(ctx, &ai.RetrieverRequest{Document: docs[0]}); err == nil {
+		t.Error("retrieve with too few embeddings: got nil error")
+	}
+}
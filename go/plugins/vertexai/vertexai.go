@@ -15,10 +15,14 @@
 package vertexai
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 
@@ -27,10 +31,14 @@ import (
 	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/genkit"
 	"github.com/firebase/genkit/go/internal"
+	"github.com/firebase/genkit/go/plugins/internal/clientcache"
 	"github.com/firebase/genkit/go/plugins/internal/gemini"
 	"github.com/firebase/genkit/go/plugins/internal/uri"
+	"github.com/firebase/genkit/go/plugins/ratelimit"
+	"golang.org/x/oauth2"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
 )
 
 const (
@@ -63,6 +71,13 @@ var state struct {
 	location  string
 	gclient   *genai.Client
 	pclient   *aiplatform.PredictionClient
+	// Clients for per-request credentials, and the options used to make them.
+	clients    *clientcache.Cache[*genai.Client]
+	pclients   *clientcache.Cache[*aiplatform.PredictionClient]
+	hclients   *clientcache.Cache[*http.Client]
+	clientOpts []option.ClientOption
+	// Quotas of models, from Config.RateLimits.
+	limits map[string]ratelimit.Limits
 }
 
 // Config is the configuration for the plugin.
@@ -75,11 +90,24 @@ type Config struct {
 	Location string
 	// Options to the Vertex AI client.
 	ClientOptions []option.ClientOption
+	// The maximum number of clients kept for per-request credentials
+	// (see [ai.CredentialProvider]). The default is 16.
+	MaxClients int
+	// RateLimits holds the quota of each model, by model name.
+	// Calls to a model with limits wait until the quota allows them,
+	// and are retried when the service rejects them for exceeding it.
+	RateLimits map[string]ratelimit.Limits
+	// ModelGarden lists open and partner models from the Vertex AI
+	// Model Garden to define, such as "llama3-405b-instruct-maas" or
+	// "claude-3-5-sonnet@20240620". They are served from Location.
+	// To serve one from another location, use [DefineModelGardenModel].
+	ModelGarden []string
 }
 
 // Init initializes the plugin and all known models and embedders.
-// After calling Init, you may call [DefineModel] and [DefineEmbedder] to create
-// and register any additional generative models and embedders
+// After calling Init, you may call [DefineModel], [DefineImagenModel],
+// [DefineModelGardenModel] and [DefineEmbedder] to create and register
+// any additional generative models and embedders
 func Init(ctx context.Context, g *genkit.Genkit, cfg *Config) error {
 	if cfg == nil {
 		cfg = &Config{}
@@ -123,6 +151,11 @@ func Init(ctx context.Context, g *genkit.Genkit, cfg *Config) error {
 	if err != nil {
 		return err
 	}
+	state.clientOpts = cfg.ClientOptions
+	state.clients = clientcache.New(cfg.MaxClients, closeClient)
+	state.pclients = clientcache.New(cfg.MaxClients, closePredictionClient)
+	state.hclients = clientcache.New(cfg.MaxClients, (*http.Client).CloseIdleConnections)
+	state.limits = cfg.RateLimits
 	state.initted = true
 	for model, caps := range knownCaps {
 		defineModel(g, model, caps)
@@ -130,9 +163,101 @@ func Init(ctx context.Context, g *genkit.Genkit, cfg *Config) error {
 	for _, e := range knownEmbedders {
 		defineEmbedder(g, e)
 	}
+	for _, m := range knownImagenModels {
+		defineImagenModel(g, m)
+	}
+	for _, m := range cfg.ModelGarden {
+		if _, err := defineModelGardenModel(ctx, g, m, nil); err != nil {
+			return err
+		}
+	}
+	genkit.RegisterShutdownHook(g, provider, closeClients)
 	return nil
 }
 
+// closeClients closes the clients created by Init and the cached
+// clients for per-request credentials.
+func closeClients(context.Context) error {
+	state.clients.Purge()
+	state.pclients.Purge()
+	state.hclients.Purge()
+	return errors.Join(state.gclient.Close(), state.pclient.Close())
+}
+
+// clientFor returns the Gemini client for a call in ctx: a client for the
+// per-request credentials in ctx, if there are any, or else the client
+// created by [Init]. Credentials may set the project, location, endpoint,
+// access token or API key. The caller must call release when done with the client.
+func clientFor(ctx context.Context) (client *genai.Client, release func(), err error) {
+	creds, err := ai.CredentialsFor(ctx, provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	if creds == nil {
+		return state.gclient, func() {}, nil
+	}
+	return state.clients.Get(ctx, creds.Key(), func(ctx context.Context) (*genai.Client, error) {
+		opts := append([]option.ClientOption{genai.WithClientInfo("genkit-go", internal.Version)}, credentialOptions(creds)...)
+		if creds.Endpoint != "" {
+			opts = append(opts, option.WithEndpoint(creds.Endpoint))
+		}
+		projectID := cmp.Or(creds.ProjectID, state.projectID)
+		location := cmp.Or(creds.Location, state.location)
+		return genai.NewClient(ctx, projectID, location, opts...)
+	})
+}
+
+// predictionClientFor is like [clientFor], but returns a prediction client,
+// along with the project and location that the call uses.
+func predictionClientFor(ctx context.Context) (client *aiplatform.PredictionClient, projectID, location string, release func(), err error) {
+	creds, err := ai.CredentialsFor(ctx, provider)
+	if err != nil {
+		return nil, "", "", nil, err
+	}
+	if creds == nil {
+		return state.pclient, state.projectID, state.location, func() {}, nil
+	}
+	projectID = cmp.Or(creds.ProjectID, state.projectID)
+	location = cmp.Or(creds.Location, state.location)
+	client, release, err = state.pclients.Get(ctx, creds.Key(), func(ctx context.Context) (*aiplatform.PredictionClient, error) {
+		endpoint := cmp.Or(creds.Endpoint, location+"-aiplatform.googleapis.com:443")
+		opts := append(credentialOptions(creds), option.WithEndpoint(endpoint))
+		return aiplatform.NewPredictionClient(ctx, opts...)
+	})
+	return client, projectID, location, release, err
+}
+
+// newHTTPClient returns a client for the Vertex AI REST APIs that uses
+// creds, or the plugin's credentials if creds is nil. Like the clients in
+// a [clientcache.Cache], it outlives ctx, so it does not use ctx's deadline.
+func newHTTPClient(ctx context.Context, creds *ai.Credentials) (*http.Client, error) {
+	opts := []option.ClientOption{option.WithScopes("https://www.googleapis.com/auth/cloud-platform")}
+	if creds == nil {
+		opts = append(opts, state.clientOpts...)
+	} else {
+		opts = append(opts, credentialOptions(creds)...)
+	}
+	c, _, err := htransport.NewClient(context.WithoutCancel(ctx), opts...)
+	return c, err
+}
+
+// credentialOptions returns the options for a client that uses creds,
+// apart from its endpoint, which each kind of client interprets differently.
+func credentialOptions(creds *ai.Credentials) []option.ClientOption {
+	opts := slices.Clone(state.clientOpts)
+	if creds.AccessToken != "" {
+		opts = append(opts, option.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: creds.AccessToken})))
+	}
+	if creds.APIKey != "" {
+		opts = append(opts, option.WithAPIKey(creds.APIKey))
+	}
+	return opts
+}
+
+func closeClient(c *genai.Client) { c.Close() }
+
+func closePredictionClient(c *aiplatform.PredictionClient) { c.Close() }
+
 //copy:sink defineModel from ../googleai/googleai.go
 // DO NOT MODIFY below vvvv
 
@@ -165,13 +290,19 @@ func defineModel(g *genkit.Genkit, name string, caps ai.ModelCapabilities) ai.Mo
 		Label:    labelPrefix + " - " + name,
 		Supports: caps,
 	}
-	return genkit.DefineModel(g, provider, name, meta, func(
+	gen := func(
 		ctx context.Context,
 		input *ai.ModelRequest,
 		cb func(context.Context, *ai.ModelResponseChunk) error,
 	) (*ai.ModelResponse, error) {
-		return generate(ctx, state.gclient, name, input, cb)
-	})
+		client, release, err := clientFor(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return generate(ctx, client, name, input, cb)
+	}
+	return genkit.DefineModel(g, provider, name, meta, ratelimit.ForModel(state.limits, name, gen))
 }
 
 // IsDefinedModel reports whether the named [Model] is defined by this plugin.
@@ -211,6 +342,46 @@ func defineEmbedder(g *genkit.Genkit, name string) ai.Embedder {
 	})
 }
 
+//copy:sink defineTranscriber from ../googleai/googleai.go
+// DO NOT MODIFY below vvvv
+
+// DefineTranscriber defines a transcriber that uses the named Gemini model
+// to convert speech to text. The model must accept audio input.
+// The transcriber has the same name as the model.
+func DefineTranscriber(g *genkit.Genkit, name string) ai.Transcriber {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if !state.initted {
+		panic(provider + ".Init not called")
+	}
+	return genkit.DefineTranscriber(g, provider, name, func(ctx context.Context, req *ai.TranscribeRequest) (*ai.TranscribeResponse, error) {
+		client, release, err := clientFor(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		resp, err := generate(ctx, client, name, gemini.TranscribeModelRequest(req), nil)
+		if err != nil {
+			return nil, err
+		}
+		return gemini.TranscribeResponse(req, resp)
+	})
+}
+
+// IsDefinedTranscriber reports whether the named [Transcriber] is defined by this plugin.
+func IsDefinedTranscriber(g *genkit.Genkit, name string) bool {
+	return genkit.IsDefinedTranscriber(g, provider, name)
+}
+
+// Transcriber returns the [ai.Transcriber] with the given name.
+// It returns nil if the transcriber was not defined.
+func Transcriber(g *genkit.Genkit, name string) ai.Transcriber {
+	return genkit.LookupTranscriber(g, provider, name)
+}
+
+// DO NOT MODIFY above ^^^^
+//copy:endsink defineTranscriber
+
 //copy:sink lookups from ../googleai/googleai.go
 // DO NOT MODIFY below vvvv
 
@@ -313,6 +484,10 @@ func newModel(client *genai.Client, model string, input *ai.ModelRequest) (*gena
 	gm := client.GenerativeModel(model)
 	gm.SetCandidateCount(1)
 	if c, ok := input.Config.(*ai.GenerationCommonConfig); ok && c != nil {
+		// The Gemini SDK has no way to set a seed.
+		if c.Seed != 0 {
+			return nil, &ai.UnsupportedConfigError{Model: provider + "/" + model, Option: "seed"}
+		}
 		if c.MaxOutputTokens != 0 {
 			gm.SetMaxOutputTokens(int32(c.MaxOutputTokens))
 		}
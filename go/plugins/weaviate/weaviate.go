@@ -18,12 +18,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
 	"strings"
 	"sync"
 
 	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/genkit"
+	"github.com/firebase/genkit/go/plugins/secrets"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate/auth"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
@@ -65,6 +65,9 @@ type ClientConfig struct {
 	// The API key to use with the Weaviate database.
 	// If not set, the default is read from the WEAVIATE_API_KEY environment variable.
 	APIKey string
+	// Secrets, if set, is consulted instead of the environment for
+	// WEAVIATE_URL and WEAVIATE_API_KEY.
+	Secrets secrets.Provider
 }
 
 // Init initializes the Weaviate plugin.
@@ -78,28 +81,37 @@ func Init(ctx context.Context, cfg *ClientConfig) (*weaviate.Client, error) {
 		panic("weaviate.Init already called")
 	}
 
-	var host string
-	if cfg != nil {
-		host = cfg.Addr
+	if cfg == nil {
+		cfg = &ClientConfig{}
 	}
-	if host == "" {
-		host = os.Getenv("WEAVIATE_URL")
+	sp := cfg.Secrets
+	if sp == nil {
+		sp = secrets.Env()
+	}
+	secret := func(v, name string) (string, error) {
+		if v != "" {
+			return v, nil
+		}
+		v, err := sp.Secret(ctx, name)
+		if err != nil && !errors.Is(err, secrets.ErrNotFound) {
+			return "", fmt.Errorf("weaviate initialization failed: %v", err)
+		}
+		return v, nil
 	}
 
-	var scheme string
-	if cfg != nil {
-		scheme = cfg.Scheme
+	host, err := secret(cfg.Addr, "WEAVIATE_URL")
+	if err != nil {
+		return nil, err
 	}
+
+	scheme := cfg.Scheme
 	if scheme == "" {
 		scheme = "https"
 	}
 
-	var apiKey string
-	if cfg != nil {
-		apiKey = cfg.APIKey
-	}
-	if apiKey == "" {
-		apiKey = os.Getenv("WEAVIATE_API_KEY")
+	apiKey, err := secret(cfg.APIKey, "WEAVIATE_API_KEY")
+	if err != nil {
+		return nil, err
 	}
 
 	config := weaviate.Config{
@@ -158,6 +170,7 @@ func DefineIndexerAndRetriever(ctx context.Context, g *genkit.Genkit, cfg ClassC
 	}
 	indexer := genkit.DefineIndexer(g, provider, cfg.Class, ds.Index)
 	retriever := genkit.DefineRetriever(g, provider, cfg.Class, ds.Retrieve)
+	genkit.RegisterHealthChecker(g, provider+"/"+cfg.Class, genkit.HealthCheckerFunc(ds.checkHealth))
 	return indexer, retriever, nil
 }
 
@@ -200,6 +213,18 @@ func newDocStore(ctx context.Context, cfg *ClassConfig) (*docStore, error) {
 	return ds, nil
 }
 
+// checkHealth verifies that the database is reachable and has the class.
+func (ds *docStore) checkHealth(ctx context.Context) error {
+	exists, err := getClient().Schema().ClassExistenceChecker().WithClassName(ds.class).Do(ctx)
+	if err != nil {
+		return fmt.Errorf("weaviate class check failed for %q: %v", ds.class, err)
+	}
+	if !exists {
+		return fmt.Errorf("weaviate class %q does not exist", ds.class)
+	}
+	return nil
+}
+
 // Indexer returns the indexer for the given class.
 func Indexer(g *genkit.Genkit, class string) ai.Indexer {
 	return genkit.LookupIndexer(g, provider, class)